@@ -0,0 +1,152 @@
+// Package testserver runs an HTTP server whose endpoints behave like the
+// odd hosts livedom meets in scans: slow, redirecting, chunked, compressed
+// and outright broken ones. End-to-end tests probe it instead of the
+// internet.
+package testserver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+// Title is the title of the pages served by /ok and the endpoints that
+// end up serving it
+const Title = "livedom test page"
+
+// page is the HTML of /ok
+var page = "<html><head><title>" + Title + "</title></head><body><h1>ok</h1></body></html>"
+
+// Server is a running test server. Its URL has no trailing slash; append
+// an endpoint path to it.
+type Server struct {
+	*httptest.Server
+}
+
+// New starts a test server over plain HTTP
+func New() *Server {
+	return &Server{httptest.NewServer(Handler())}
+}
+
+// NewTLS starts a test server over HTTPS with a self-signed certificate
+// that clients don't trust
+func NewTLS() *Server {
+	return &Server{httptest.NewTLSServer(Handler())}
+}
+
+// Handler serves the test endpoints:
+//
+//	/ok                 200 with an HTML page titled Title
+//	/slow?delay=2s      /ok after the delay, or until the client gives up
+//	/redirect           302 to /ok
+//	/redirect-loop      302 to itself
+//	/redirect-lowercase 302 to /ok with a lowercase location header
+//	/chunked            /ok sent in chunks, with an X-Checksum trailer
+//	/gzip               /ok gzip-compressed whatever the client accepts
+//	/basic              401 asking for Basic auth
+//	/rate-limited       429 with a lowercase retry-after of one second
+//	/broken-chunked     chunked encoding that breaks off mid-body
+//	/bare-lf            /ok with LF instead of CRLF line endings
+//	/bad-header         a header line without a colon
+//	/reset              closes the connection without answering
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		delay, err := time.ParseDuration(r.URL.Query().Get("delay"))
+		if err != nil {
+			delay = 2 * time.Second
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+
+	mux.HandleFunc("/redirect-loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/redirect-loop", http.StatusFound)
+	})
+
+	mux.HandleFunc("/redirect-lowercase", func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["location"] = []string{"/ok"}
+		w.WriteHeader(http.StatusFound)
+	})
+
+	mux.HandleFunc("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Trailer", "X-Checksum")
+		flusher := w.(http.Flusher)
+		for i := 0; i < len(page); i += 16 {
+			fmt.Fprint(w, page[i:min(i+16, len(page))])
+			flusher.Flush()
+		}
+		w.Header().Set("X-Checksum", strconv.Itoa(len(page)))
+	})
+
+	mux.HandleFunc("/gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		fmt.Fprint(gz, page)
+		gz.Close()
+	})
+
+	mux.HandleFunc("/basic", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="testserver"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	mux.HandleFunc("/rate-limited", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header()["retry-after"] = []string{"1"}
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	mux.HandleFunc("/broken-chunked", raw("HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/html\r\n"+
+		"Transfer-Encoding: chunked\r\n\r\n"+
+		"10\r\n<title>partial</XX"))
+
+	mux.HandleFunc("/bare-lf", raw(fmt.Sprintf("HTTP/1.1 200 OK\n"+
+		"Content-Type: text/html\n"+
+		"Content-Length: %d\n\n%s", len(page), page)))
+
+	body := "<title>bad header</title>"
+	mux.HandleFunc("/bad-header", raw(fmt.Sprintf("HTTP/1.1 200 OK\r\n"+
+		"Content-Type: text/html\r\n"+
+		"Content-Length: %d\r\n"+
+		"X-Broken-Header\r\n\r\n%s", len(body), body)))
+
+	mux.HandleFunc("/reset", raw(""))
+
+	return mux
+}
+
+// raw answers with response as is, bypassing net/http's response writer,
+// and closes the connection
+func raw(response string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, response)
+	}
+}
@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// apexBudget caps how many targets of the same apex domain are probed, so
+// millions of wildcard-generated names for one domain can't swamp a scan.
+// Input targets are sampled with a seeded reservoir, so the probed ones
+// are spread over the whole input rather than its first lines, and the
+// same seed picks the same sample of the same input. Targets are held
+// until the input ends.
+type apexBudget struct {
+	max int
+	rng *rand.Rand
+
+	mu      sync.Mutex
+	added   int
+	counts  map[string]int
+	samples map[string][]apexSample
+	apexes  []string // in order of first appearance
+	skipped int
+}
+
+// apexSample is a target in the reservoir of its apex
+type apexSample struct {
+	index  int // position in the input, to keep input order
+	target Target
+}
+
+func newApexBudget(max int, seed uint64) *apexBudget {
+	return &apexBudget{
+		max:     max,
+		rng:     rand.New(rand.NewPCG(seed, 0)),
+		counts:  make(map[string]int),
+		samples: make(map[string][]apexSample),
+	}
+}
+
+// apexOf returns the apex domain a host counts against. IPs and other hosts
+// without an apex domain count on their own.
+func apexOf(host string) string {
+	apex := strings.ToLower(host)
+	if net.ParseIP(host) == nil {
+		if domain := apexDomain(host); domain != "" {
+			apex = domain
+		}
+	}
+	return apex
+}
+
+// Add offers an input target to the reservoir of its apex
+func (b *apexBudget) Add(target Target) {
+	apex := apexOf(extractDomain(target.Input))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.added++
+	b.counts[apex]++
+	n := b.counts[apex]
+	sample := apexSample{index: b.added, target: target}
+	switch {
+	case n == 1:
+		b.apexes = append(b.apexes, apex)
+		fallthrough
+	case n <= b.max:
+		b.samples[apex] = append(b.samples[apex], sample)
+	default:
+		if n == b.max+1 {
+			slog.Warn("apex over -max-per-apex, probing a sample of its targets", "apex", apex, "max", b.max)
+		}
+		// Algorithm R: the nth target replaces a kept one with
+		// probability max/n
+		if i := b.rng.IntN(n); i < b.max {
+			b.samples[apex][i] = sample
+		}
+	}
+}
+
+// Drain returns the sampled targets once the input has been read, apex by
+// apex in input order
+func (b *apexBudget) Drain() []Target {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var targets []Target
+	for _, apex := range b.apexes {
+		samples := b.samples[apex]
+		slices.SortFunc(samples, func(a, b apexSample) int { return a.index - b.index })
+		for _, sample := range samples {
+			targets = append(targets, sample.target)
+		}
+		delete(b.samples, apex)
+	}
+	b.skipped += b.added - len(targets)
+	b.added = 0
+	return targets
+}
+
+// Allow reports whether a target found after the input ended, such as a
+// certificate SAN, still fits its apex's budget
+func (b *apexBudget) Allow(host string) bool {
+	apex := apexOf(host)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.counts[apex] >= b.max {
+		b.skipped++
+		return false
+	}
+	b.counts[apex]++
+	return true
+}
+
+// Skipped is how many targets were left out of the samples or over budget
+func (b *apexBudget) Skipped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.skipped
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// sampleApex runs n targets of example.com plus two of example.org
+// through a budget of max and returns what it keeps
+func sampleApex(n, max int, seed uint64) []string {
+	budget := newApexBudget(max, seed)
+	budget.Add(Target{Input: "www.example.org"})
+	for i := range n {
+		budget.Add(Target{Input: fmt.Sprintf("h%05d.example.com", i)})
+	}
+	budget.Add(Target{Input: "api.example.org"})
+
+	var kept []string
+	for _, target := range budget.Drain() {
+		kept = append(kept, target.Input)
+	}
+	return kept
+}
+
+func TestApexBudgetSample(t *testing.T) {
+	kept := sampleApex(10000, 100, 1)
+	if len(kept) != 102 {
+		t.Fatalf("kept %d targets, want 100 of example.com and both of example.org", len(kept))
+	}
+	if kept[0] != "www.example.org" || kept[1] != "api.example.org" {
+		t.Errorf("example.org targets = %q", kept[:2])
+	}
+	sample := kept[2:]
+	if !slices.IsSorted(sample) {
+		t.Error("sample isn't in input order")
+	}
+
+	// A sample of the whole input, not its first lines
+	late := 0
+	for _, host := range sample {
+		if host >= "h05000" {
+			late++
+		}
+	}
+	if late < 30 || late > 70 {
+		t.Errorf("%d of 100 kept targets from the second half of the input", late)
+	}
+
+	if again := sampleApex(10000, 100, 1); !slices.Equal(again, kept) {
+		t.Error("same seed picked another sample")
+	}
+	if other := sampleApex(10000, 100, 2); slices.Equal(other, kept) {
+		t.Error("another seed picked the same sample")
+	}
+}
+
+func TestApexBudgetSkipped(t *testing.T) {
+	budget := newApexBudget(2, 0)
+	for _, host := range []string{"a.example.com", "b.example.com", "c.example.com", "192.0.2.1", "x.example.net"} {
+		budget.Add(Target{Input: host})
+	}
+	if kept := budget.Drain(); len(kept) != 4 {
+		t.Errorf("kept %d targets, want 4", len(kept))
+	}
+
+	// Hosts found during the scan take what's left of the budget
+	if budget.Allow("d.example.com") {
+		t.Error("example.com is over budget")
+	}
+	if !budget.Allow("y.example.net") || budget.Allow("z.example.net") {
+		t.Error("example.net has room for one more host")
+	}
+	if got := budget.Skipped(); got != 3 {
+		t.Errorf("skipped %d, want 3", got)
+	}
+}
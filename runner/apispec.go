@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxSpecSize caps how much of an API spec is read; specs of large APIs
+// run to megabytes, but the info block comes first
+const maxSpecSize = 4 * 1024 * 1024
+
+// apiSpecPaths are where frameworks serve their OpenAPI or Swagger spec by
+// default
+var apiSpecPaths = []string{
+	"/swagger.json",
+	"/openapi.json",
+	"/v2/api-docs",
+	"/v3/api-docs",
+	"/swagger/v1/swagger.json",
+	"/api/swagger.json",
+	"/api-docs",
+}
+
+// APISpec is an OpenAPI or Swagger spec found by -api-detect
+type APISpec struct {
+	URL     string `json:"url"`
+	Spec    string `json:"spec"` // openapi 3.0.1, swagger 2.0
+	Title   string `json:"title,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// detectAPISpecs requests the common spec paths on the host of targetURL
+// and returns the ones that answer with a spec
+func detectAPISpecs(client *fasthttp.Client, target Target, targetURL string, config *Config) []APISpec {
+	base, err := url.Parse(targetURL)
+	if err != nil {
+		return nil
+	}
+
+	var specs []APISpec
+	for _, path := range apiSpecPaths {
+		specURL := base.ResolveReference(&url.URL{Path: path}).String()
+		if spec, ok := fetchAPISpec(client, target, specURL, config); ok {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// fetchAPISpec requests one spec URL. SPAs answer every path with their
+// index page, so only a 200 that parses as a spec counts.
+func fetchAPISpec(client *fasthttp.Client, target Target, specURL string, config *Config) (APISpec, bool) {
+	req := newFollowUpRequest(specURL, target, config)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.Set("Accept", "application/json")
+	err := doFollowUp(client, req, resp, config)
+	if err != nil || resp.StatusCode() != fasthttp.StatusOK {
+		return APISpec{}, false
+	}
+
+	body := resp.Body()
+	if len(body) > maxSpecSize {
+		return APISpec{}, false
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Swagger string `json:"swagger"`
+		Info    struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if json.Unmarshal(body, &doc) != nil {
+		return APISpec{}, false
+	}
+
+	spec := APISpec{URL: specURL, Title: strings.TrimSpace(doc.Info.Title), Version: strings.TrimSpace(doc.Info.Version)}
+	switch {
+	case doc.OpenAPI != "":
+		spec.Spec = "openapi " + doc.OpenAPI
+	case doc.Swagger != "":
+		spec.Spec = "swagger " + doc.Swagger
+	default:
+		return APISpec{}, false
+	}
+	return spec, true
+}
+
+// formatAPISpecs renders specs for the api column as path(title version)
+func formatAPISpecs(specs []APISpec) string {
+	parts := make([]string, len(specs))
+	for i, spec := range specs {
+		path := spec.URL
+		if u, err := url.Parse(spec.URL); err == nil {
+			path = u.Path
+		}
+		if about := strings.TrimSpace(spec.Title + " " + spec.Version); about != "" {
+			path = fmt.Sprintf("%s(%s)", path, about)
+		}
+		parts[i] = path
+	}
+	return "api:" + strings.Join(parts, ",")
+}
@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// asnRateLimiter spaces out requests to targets in the same autonomous
+// system, so a scan covering thousands of customers of one small hosting
+// provider doesn't hit its network with the full -t concurrency
+type asnRateLimiter struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	next     map[string]time.Time // earliest time of the next request per ASN
+	prefixes []asnPrefix          // announced prefixes seen so far
+}
+
+// asnPrefix is a prefix announced by an ASN
+type asnPrefix struct {
+	network *net.IPNet
+	asn     string
+}
+
+func newASNRateLimiter(perSecond int, timeout time.Duration) *asnRateLimiter {
+	return &asnRateLimiter{
+		interval: time.Second / time.Duration(perSecond),
+		timeout:  timeout,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// Wait blocks until a request to host fits its ASN's rate. Hosts that
+// don't resolve are left to fail on their own.
+func (l *asnRateLimiter) Wait(host string) {
+	if l == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+	ips, err := dialAddresses.Lookup(ctx, host)
+	if err != nil || len(ips) == 0 {
+		return
+	}
+	asn := l.lookup(ctx, ips[0])
+
+	// Each request takes the next free slot of its ASN
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[asn]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[asn] = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
+
+// lookup returns the ASN announcing ip. Known prefixes answer without a
+// query; IPs whose ASN can't be found are grouped by their /24 or /48.
+func (l *asnRateLimiter) lookup(ctx context.Context, ip net.IP) string {
+	l.mu.Lock()
+	for _, prefix := range l.prefixes {
+		if prefix.network.Contains(ip) {
+			l.mu.Unlock()
+			return prefix.asn
+		}
+	}
+	l.mu.Unlock()
+
+	asn, network, err := cymruOrigin(ctx, ip)
+	if err != nil {
+		if ip.To4() != nil {
+			return ip.Mask(net.CIDRMask(24, 32)).String() + "/24"
+		}
+		return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+	}
+
+	l.mu.Lock()
+	l.prefixes = append(l.prefixes, asnPrefix{network: network, asn: asn})
+	l.mu.Unlock()
+	return asn
+}
+
+// cymruOrigin looks up the origin ASN and prefix of ip in Team Cymru's
+// IP to ASN DNS zone, e.g. for 1.1.1.1:
+//
+//	1.1.1.1.origin.asn.cymru.com. TXT "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"
+func cymruOrigin(ctx context.Context, ip net.IP) (string, *net.IPNet, error) {
+	var name string
+	if v4 := ip.To4(); v4 != nil {
+		name = fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", v4[3], v4[2], v4[1], v4[0])
+	} else {
+		var nibbles []string
+		for i := len(ip) - 1; i >= 0; i-- {
+			nibbles = append(nibbles, fmt.Sprintf("%x.%x", ip[i]&0xf, ip[i]>>4))
+		}
+		name = strings.Join(nibbles, ".") + ".origin6.asn.cymru.com"
+	}
+
+	records, err := net.DefaultResolver.LookupTXT(ctx, name)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, record := range records {
+		fields := strings.Split(record, "|")
+		if len(fields) < 2 {
+			continue
+		}
+		// Prefixes announced by several ASNs list them all, the first will do
+		asn := strings.Fields(fields[0])
+		_, network, err := net.ParseCIDR(strings.TrimSpace(fields[1]))
+		if len(asn) == 0 || err != nil {
+			continue
+		}
+		return "AS" + asn[0], network, nil
+	}
+	return "", nil, fmt.Errorf("no origin for %s", ip)
+}
@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestASNLookup(t *testing.T) {
+	l := newASNRateLimiter(10, time.Second)
+	_, network, _ := net.ParseCIDR("192.0.2.0/24")
+	l.prefixes = []asnPrefix{{network: network, asn: "AS64496"}}
+
+	// A cancelled context fails the Team Cymru query, which leaves the
+	// fallback grouping
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"192.0.2.1", "AS64496"},
+		{"192.0.2.254", "AS64496"},
+		{"198.51.100.7", "198.51.100.0/24"},
+		{"2001:db8:1:2::1", "2001:db8:1::/48"},
+	}
+	for _, tt := range tests {
+		if got := l.lookup(ctx, net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("lookup(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestASNRateLimiterWait(t *testing.T) {
+	var nilLimiter *asnRateLimiter
+	nilLimiter.Wait("192.0.2.1")
+
+	l := newASNRateLimiter(20, time.Second)
+	_, network, _ := net.ParseCIDR("192.0.2.0/24")
+	l.prefixes = []asnPrefix{{network: network, asn: "AS64496"}}
+
+	// Hosts in one ASN take turns 50ms apart, hosts that don't resolve
+	// aren't limited
+	start := time.Now()
+	for _, host := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		l.Wait(host)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("three requests to one ASN took %v, want at least 100ms", elapsed)
+	}
+	l.Wait("no-such-host.invalid")
+	if len(l.next) != 1 {
+		t.Errorf("rate limited %d ASNs, want 1", len(l.next))
+	}
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// AuthChallenge is one scheme offered in a WWW-Authenticate header
+type AuthChallenge struct {
+	Scheme string `json:"scheme"`
+	Realm  string `json:"realm,omitempty"`
+}
+
+// authChallenges returns the challenges of every WWW-Authenticate header
+// in a response, e.g. Basic realm="Admin", NTLM and Negotiate
+func authChallenges(resp *fasthttp.Response) []AuthChallenge {
+	var challenges []AuthChallenge
+	for _, value := range headerValues(resp, "WWW-Authenticate") {
+		challenges = append(challenges, parseWWWAuthenticate(value)...)
+	}
+	return challenges
+}
+
+// parseWWWAuthenticate splits a header value into its challenges. A header
+// may hold several, separated by commas like their parameters are:
+//
+//	Basic realm="Admin, Staff", Bearer realm="api", error="invalid_token"
+func parseWWWAuthenticate(value string) []AuthChallenge {
+	var challenges []AuthChallenge
+	for _, item := range splitQuoted(value, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		// "Scheme param=value" or a bare "Scheme" starts a new challenge,
+		// "param=value" continues the current one
+		param := item
+		space := strings.IndexAny(item, " \t")
+		equals := strings.Index(item, "=")
+		if equals == -1 || (space != -1 && space < equals) {
+			scheme := item
+			param = ""
+			if space != -1 {
+				scheme, param = item[:space], strings.TrimSpace(item[space+1:])
+			}
+			challenges = append(challenges, AuthChallenge{Scheme: scheme})
+		}
+		if len(challenges) == 0 {
+			continue
+		}
+
+		name, val, ok := strings.Cut(param, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "realm") {
+			challenges[len(challenges)-1].Realm = strings.Trim(strings.TrimSpace(val), `"`)
+		}
+	}
+	return challenges
+}
+
+// splitQuoted splits s at sep, except inside double quotes
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// formatAuth renders challenges for the auth column, e.g. Basic:Admin,NTLM
+func formatAuth(challenges []AuthChallenge) string {
+	parts := make([]string, 0, len(challenges))
+	for _, challenge := range challenges {
+		if challenge.Realm != "" {
+			parts = append(parts, challenge.Scheme+":"+challenge.Realm)
+		} else {
+			parts = append(parts, challenge.Scheme)
+		}
+	}
+	return strings.Join(parts, ",")
+}
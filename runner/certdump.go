@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// dumpCertificates writes a chain as PEM to dir/host_port.pem, leaf first,
+// and returns the file's path
+func dumpCertificates(dir, targetURL string, chain []*x509.Certificate) (string, error) {
+	port := extractPort(targetURL)
+	if port == 0 {
+		port = 443
+	}
+
+	var buf bytes.Buffer
+	for _, cert := range chain {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return "", err
+		}
+	}
+
+	path := filepath.Join(dir, safeFileName(extractDomain(targetURL)+"_"+strconv.Itoa(port))+".pem")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxChallengeScan bounds how much of a body is searched for challenge
+// markers. Challenge pages are small and put them near the top.
+const maxChallengeScan = 64 * 1024
+
+// challengeSignature identifies the bot challenge or block page of a
+// protection vendor. A response matches when it has one of the statuses
+// (any status if none are listed) and one of the headers or body markers.
+type challengeSignature struct {
+	vendor   string
+	statuses []int
+	headers  map[string]string // header name -> value substring, "" = any value
+	markers  []string
+}
+
+var challengeSignatures = []challengeSignature{
+	{
+		vendor:   "cloudflare",
+		statuses: []int{403, 429, 503},
+		headers:  map[string]string{"Cf-Mitigated": "challenge"},
+		markers:  []string{"_cf_chl_opt", "challenge-platform", "cf-browser-verification", "<title>Just a moment...</title>", "Attention Required! | Cloudflare"},
+	},
+	{
+		vendor:   "akamai",
+		statuses: []int{403, 428, 429},
+		markers:  []string{"sec-if-cpt-container", "bm-verify", "_sec/cp_challenge", "errors.edgesuite.net"},
+	},
+	{
+		vendor:  "imperva",
+		markers: []string{"_Incapsula_Resource", "Incapsula incident ID"},
+	},
+	{
+		vendor:   "datadome",
+		statuses: []int{403, 405},
+		headers:  map[string]string{"X-Datadome": ""},
+		markers:  []string{"captcha-delivery.com"},
+	},
+	{
+		vendor:   "perimeterx",
+		statuses: []int{403, 429},
+		markers:  []string{"_pxCaptcha", "px-captcha", "captcha.px-cdn.net"},
+	},
+	{
+		vendor:  "aws-waf",
+		headers: map[string]string{"X-Amzn-Waf-Action": ""},
+		markers: []string{"awswaf.com", "AwsWafIntegration"},
+	},
+	{
+		vendor:   "sucuri",
+		statuses: []int{403},
+		markers:  []string{"sucuri_cloudproxy_js", "Sucuri WebSite Firewall - Access Denied"},
+	},
+}
+
+// detectChallenge returns the vendor whose challenge or block page a
+// response is, or "" if the real application answered
+func detectChallenge(resp *fasthttp.Response) string {
+	body := resp.Body()
+	if len(body) > maxChallengeScan {
+		body = body[:maxChallengeScan]
+	}
+
+	for _, signature := range challengeSignatures {
+		if len(signature.statuses) > 0 && !slices.Contains(signature.statuses, resp.StatusCode()) {
+			continue
+		}
+		if signature.matches(resp, body) {
+			return signature.vendor
+		}
+	}
+	return ""
+}
+
+func (s challengeSignature) matches(resp *fasthttp.Response, body []byte) bool {
+	// Header names are matched case-insensitively since fasthttp keeps
+	// them as received
+	for key, header := range resp.Header.All() {
+		for name, value := range s.headers {
+			if strings.EqualFold(string(key), name) && strings.Contains(strings.ToLower(string(header)), value) {
+				return true
+			}
+		}
+	}
+	for _, marker := range s.markers {
+		if bytes.Contains(body, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
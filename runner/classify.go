@@ -0,0 +1,218 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+// Input kinds, see classifyInput
+const (
+	inputFQDN    = "fqdn"
+	inputURL     = "url"
+	inputIPv4    = "ipv4"
+	inputIPv6    = "ipv6"
+	inputCIDR    = "cidr"
+	inputInvalid = "invalid"
+)
+
+// maxCIDRAddresses is the largest range a CIDR input line may expand to
+const maxCIDRAddresses = 1 << 16
+
+// invalidInput is why classifyInput rejected a line, counted by reason
+type invalidInput struct {
+	reason string
+	detail string
+}
+
+func (e *invalidInput) Error() string {
+	return e.reason + ": " + e.detail
+}
+
+// classifyInput tells what an input line is: a URL, a host name, an IP
+// (each with an optional port, and host names and IPs with an optional
+// path) or a CIDR range. Anything else is invalid, so strings like
+// http://http://x are reported instead of probed.
+func classifyInput(input string) (string, error) {
+	if scheme, rest, ok := strings.Cut(input, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "http", "https":
+		default:
+			return inputInvalid, &invalidInput{"unsupported scheme", scheme}
+		}
+		lower := strings.ToLower(rest)
+		if strings.HasPrefix(lower, "http:") || strings.HasPrefix(lower, "https:") {
+			return inputInvalid, &invalidInput{"nested scheme", input}
+		}
+		u, err := url.Parse(input)
+		if err != nil {
+			return inputInvalid, &invalidInput{"bad url", err.Error()}
+		}
+		if _, err := classifyHost(u.Host); err != nil {
+			return inputInvalid, err
+		}
+		return inputURL, nil
+	}
+
+	if prefix, err := netip.ParsePrefix(input); err == nil {
+		if bits := prefix.Addr().BitLen() - prefix.Bits(); bits > 16 {
+			return inputInvalid, &invalidInput{"cidr too large", fmt.Sprintf("%s has more than %d addresses", input, maxCIDRAddresses)}
+		}
+		return inputCIDR, nil
+	}
+
+	// Without a scheme, example.com/admin/, //example.com?x=1 and
+	// user@example.com:8443/x are probed like URLs; classify their host
+	host := strings.TrimPrefix(input, "//")
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	return classifyHost(host)
+}
+
+// classifyHost classifies a host with an optional port, as in a URL
+func classifyHost(hostport string) (string, error) {
+	host := hostport
+	if h, port, err := net.SplitHostPort(hostport); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return inputInvalid, &invalidInput{"bad port", hostport}
+		}
+		host = h
+	} else if strings.HasSuffix(hostport, ":") {
+		return inputInvalid, &invalidInput{"bad port", hostport}
+	}
+
+	if ip, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		if ip.Is4() {
+			return inputIPv4, nil
+		}
+		return inputIPv6, nil
+	}
+	if !validHostname(host) {
+		return inputInvalid, &invalidInput{"bad hostname", hostport}
+	}
+	return inputFQDN, nil
+}
+
+// validHostname checks a DNS name: dot-separated labels of letters,
+// digits, hyphens and underscores, 63 bytes each and 253 in total.
+// Internationalized names are checked in their ASCII form.
+func validHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	ascii, err := idna.Punycode.ToASCII(host)
+	if err != nil || ascii == "" || len(ascii) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// expandCIDR returns every address of a CIDR range, IPv6 ones in brackets
+// so they can take a port or scheme
+func expandCIDR(cidr string) []string {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil
+	}
+	var addresses []string
+	for addr := prefix.Masked().Addr(); prefix.Contains(addr); addr = addr.Next() {
+		if addr.Is6() {
+			addresses = append(addresses, "["+addr.String()+"]")
+		} else {
+			addresses = append(addresses, addr.String())
+		}
+		if !addr.Next().IsValid() {
+			break
+		}
+	}
+	return addresses
+}
+
+// inputCounts counts input lines by kind, and invalid ones by reason
+type inputCounts struct {
+	mu      sync.Mutex
+	kinds   map[string]int64
+	reasons map[string]int64
+}
+
+func newInputCounts() *inputCounts {
+	return &inputCounts{kinds: make(map[string]int64), reasons: make(map[string]int64)}
+}
+
+// Add counts a line of kind. err is the reason of invalid lines.
+func (c *inputCounts) Add(kind string, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.kinds[kind]++
+	if kind == inputInvalid {
+		reason := "unparsable"
+		if invalid, ok := err.(*invalidInput); ok {
+			reason = invalid.reason
+		}
+		c.reasons[reason]++
+	}
+}
+
+// Invalid is the number of lines skipped as invalid
+func (c *inputCounts) Invalid() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.kinds[inputInvalid]
+}
+
+// Print writes the counts to w, e.g.
+//
+//	Input: 980 fqdn, 12 url, 3 ipv4, 1 cidr, 4 invalid (3 nested scheme, 1 bad hostname)
+func (c *inputCounts) Print(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var parts []string
+	for _, kind := range []string{inputFQDN, inputURL, inputIPv4, inputIPv6, inputCIDR, inputInvalid} {
+		if n := c.kinds[kind]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, kind))
+		}
+	}
+	line := "Input: " + strings.Join(parts, ", ")
+
+	if len(c.reasons) > 0 {
+		reasons := make([]string, 0, len(c.reasons))
+		for reason := range c.reasons {
+			reasons = append(reasons, reason)
+		}
+		sort.Slice(reasons, func(i, j int) bool {
+			if c.reasons[reasons[i]] != c.reasons[reasons[j]] {
+				return c.reasons[reasons[i]] > c.reasons[reasons[j]]
+			}
+			return reasons[i] < reasons[j]
+		})
+		for i, reason := range reasons {
+			reasons[i] = fmt.Sprintf("%d %s", c.reasons[reason], reason)
+		}
+		line += " (" + strings.Join(reasons, ", ") + ")"
+	}
+	fmt.Fprintln(w, line)
+}
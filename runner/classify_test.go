@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestClassifyInput(t *testing.T) {
+	tests := []struct {
+		input  string
+		kind   string
+		reason string
+	}{
+		{"example.com", inputFQDN, ""},
+		{"example.com:8443", inputFQDN, ""},
+		{"example.com/admin/", inputFQDN, ""},
+		{"example.com:8443/x", inputFQDN, ""},
+		{"//example.com/a", inputFQDN, ""},
+		{"example.com?x=1", inputFQDN, ""},
+		{"example.com#top", inputFQDN, ""},
+		{"user@example.com/x", inputFQDN, ""},
+		{"https://example.com/a?b=c", inputURL, ""},
+		{"HTTP://example.com", inputURL, ""},
+		{"192.0.2.1", inputIPv4, ""},
+		{"192.0.2.1:8080/status", inputIPv4, ""},
+		{"2001:db8::1", inputIPv6, ""},
+		{"[2001:db8::1]:8443/x", inputIPv6, ""},
+		{"192.0.2.0/24", inputCIDR, ""},
+		{"http://http://example.com", inputInvalid, "nested scheme"},
+		{"ftp://example.com", inputInvalid, "unsupported scheme"},
+		{"10.0.0.0/8", inputInvalid, "cidr too large"},
+		{"exa mple.com", inputInvalid, "bad hostname"},
+		{"exa$mple.com/x", inputInvalid, "bad hostname"},
+		{"/admin", inputInvalid, "bad hostname"},
+		{"example.com:0/x", inputInvalid, "bad port"},
+		{"example.com:/x", inputInvalid, "bad port"},
+		{"example.com:99999", inputInvalid, "bad port"},
+	}
+	for _, tt := range tests {
+		kind, err := classifyInput(tt.input)
+		if kind != tt.kind {
+			t.Errorf("classifyInput(%q) = %s (%v), want %s", tt.input, kind, err, tt.kind)
+			continue
+		}
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("classifyInput(%q): unexpected error %v", tt.input, err)
+			}
+			continue
+		}
+		if invalid, ok := err.(*invalidInput); !ok || invalid.reason != tt.reason {
+			t.Errorf("classifyInput(%q) error = %v, want reason %q", tt.input, err, tt.reason)
+		}
+	}
+}
+
+// Inputs with a path but no scheme must reach the URL builder
+func TestReadTargetsPaths(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "targets.txt")
+	lines := []string{"example.com/admin/", "example.com:8443/x", "//example.com/a", "example.com?x=1", "2001:db8::1"}
+	if err := os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{InputFile: input, inputCounts: newInputCounts()}
+	var urls []string
+	err := readTargets(config, func(target Target) {
+		addr, err := parseTargetAddr(target.Input)
+		if err != nil {
+			t.Errorf("%s: %v", target.Input, err)
+			return
+		}
+		urls = append(urls, addr.URLs()[0])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"https://example.com/admin/",
+		"https://example.com:8443/x",
+		"https://example.com/a",
+		"https://example.com/?x=1",
+		"https://[2001:db8::1]",
+	}
+	if !slices.Equal(urls, want) {
+		t.Errorf("probed %q, want %q", urls, want)
+	}
+	if invalid := config.inputCounts.Invalid(); invalid != 0 {
+		t.Errorf("%d lines skipped as invalid", invalid)
+	}
+}
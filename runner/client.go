@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// keepAliveConnWait is how long a -keep-alive probe waits for its host's
+// connection to become free before giving up, unless -max-conn-wait is set
+const keepAliveConnWait = time.Minute
+
+// newHTTPClient creates the fasthttp client shared by all probes
+func newHTTPClient(config *Config) *fasthttp.Client {
+	client := &fasthttp.Client{
+		MaxConnsPerHost:               config.MaxConnsPerHost,
+		MaxIdleConnDuration:           config.IdleConnTimeout,
+		MaxConnWaitTimeout:            config.MaxConnWait,
+		ReadTimeout:                   config.Timeout,
+		WriteTimeout:                  config.Timeout,
+		MaxIdemponentCallAttempts:     1,
+		DisableHeaderNamesNormalizing: true,
+		DisablePathNormalizing:        true,
+	}
+
+	// Send every probe of a host over a single keep-alive connection, one
+	// after another, instead of a new connection and TLS handshake each.
+	// An explicit -max-conns-per-host still wins.
+	if config.KeepAlive {
+		if !config.maxConnsPerHostSet {
+			client.MaxConnsPerHost = 1
+		}
+		if client.MaxConnWaitTimeout == 0 {
+			client.MaxConnWaitTimeout = keepAliveConnWait
+		}
+	}
+
+	// Bodies over the -max-memory budget are streamed rather than read
+	// whole, so they can be cut short
+	if config.memory != nil {
+		client.StreamResponseBody = true
+		client.MaxResponseBodySize = int(config.memory.BodyBudget(config.Threads))
+	}
+
+	client.DialTimeout = config.dialTarget
+	if config.Stats {
+		client.DialTimeout = config.stats.Wrap(client.DialTimeout)
+	}
+	if config.conns != nil {
+		client.DialTimeout = config.conns.Wrap(client.DialTimeout)
+	}
+
+	// Look like a browser to JA3-based bot filters
+	if config.tlsImpersonate != nil {
+		impersonateTLS(client, *config.tlsImpersonate)
+	}
+
+	return client
+}
+
+// poolStats counts requests for the scan summary and connection pool
+// activity for -stats
+type poolStats struct {
+	requests    atomic.Int64
+	failed      atomic.Int64
+	noFreeConns atomic.Int64
+	dials       atomic.Int64
+	dialErrors  atomic.Int64
+	open        atomic.Int64
+	peakOpen    atomic.Int64
+	started     time.Time
+}
+
+func newPoolStats() *poolStats {
+	return &poolStats{started: time.Now()}
+}
+
+// Wrap counts the connections dial opens for the client
+func (s *poolStats) Wrap(dial fasthttp.DialFuncWithTimeout) fasthttp.DialFuncWithTimeout {
+	return func(addr string, timeout time.Duration) (net.Conn, error) {
+		// Requests without a deadline pass no timeout
+		conn, err := dial(addr, timeout)
+		if err != nil {
+			s.dialErrors.Add(1)
+			return nil, err
+		}
+
+		s.dials.Add(1)
+		open := s.open.Add(1)
+		for {
+			peak := s.peakOpen.Load()
+			if open <= peak || s.peakOpen.CompareAndSwap(peak, open) {
+				break
+			}
+		}
+		return &countedConn{Conn: conn, stats: s}, nil
+	}
+}
+
+// recordRequest counts a request sent through the client. It is a no-op
+// when -stats is off.
+func (s *poolStats) recordRequest(err error) {
+	if s == nil {
+		return
+	}
+	s.requests.Add(1)
+	if err != nil {
+		s.failed.Add(1)
+		if errors.Is(err, fasthttp.ErrNoFreeConns) {
+			s.noFreeConns.Add(1)
+		}
+	}
+}
+
+// Print writes the statistics to stderr so they don't mix with results
+func (s *poolStats) Print() {
+	elapsed := time.Since(s.started)
+	requests := s.requests.Load()
+	dials := s.dials.Load()
+
+	reused := requests - s.failed.Load() - dials
+	if reused < 0 {
+		reused = 0
+	}
+
+	fmt.Fprintf(os.Stderr, "Requests: %d (%d failed) in %s, %.1f/s\n",
+		requests, s.failed.Load(), elapsed.Round(time.Millisecond), float64(requests)/elapsed.Seconds())
+	fmt.Fprintf(os.Stderr, "Connections: %d opened, %d reused requests, %d peak open, %d dial errors\n",
+		dials, reused, s.peakOpen.Load(), s.dialErrors.Load())
+	fmt.Fprintf(os.Stderr, "Pool: %d requests got no free connection (raise -max-conns-per-host or -max-conn-wait)\n",
+		s.noFreeConns.Load())
+}
+
+// countedConn decrements the open connection count when closed
+type countedConn struct {
+	net.Conn
+	stats *poolStats
+	once  sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() { c.stats.open.Add(-1) })
+	return c.Conn.Close()
+}
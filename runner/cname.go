@@ -0,0 +1,266 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxCNAMEHops bounds the length of a followed CNAME chain
+const maxCNAMEHops = 10
+
+// resolvConfPath lists the nameservers queried for CNAME chains
+const resolvConfPath = "/etc/resolv.conf"
+
+// dnsAnswers is the answer section of a response: the CNAME records by
+// lower-cased owner name, and the names that have address records
+type dnsAnswers struct {
+	cnames    map[string]string
+	addresses map[string]bool
+}
+
+// resolveCNAMEChain returns every CNAME hop of domain in order, e.g.
+// shop.example.com -> shop.example.com.cdn.net -> edge1.cdn.net. net's
+// LookupCNAME only reports the final name, but takeover checks and CDN
+// identification need the intermediate ones. When no nameserver can be
+// queried directly it falls back to the final name from the system resolver.
+func resolveCNAMEChain(domain string, timeout time.Duration) []string {
+	servers := systemNameservers()
+	if len(servers) == 0 {
+		return lookupCNAMEFallback(domain)
+	}
+
+	var chain []string
+	seen := map[string]bool{strings.ToLower(domain): true}
+	name := domain
+
+	for len(chain) < maxCNAMEHops {
+		answers, err := queryNameservers(servers, name, timeout)
+		if err != nil {
+			if len(chain) == 0 {
+				return lookupCNAMEFallback(domain)
+			}
+			break
+		}
+
+		// Recursive resolvers usually return the whole chain at once, but
+		// some stop after the first hop, so keep querying the last name
+		name = followCNAMEs(name, answers, seen, &chain)
+		if name == "" {
+			break
+		}
+	}
+
+	return chain
+}
+
+// followCNAMEs appends the hops in answers starting at name to chain. It
+// returns the last name if the response ended on a CNAME target that it
+// didn't resolve, so the caller can continue from there.
+func followCNAMEs(name string, answers dnsAnswers, seen map[string]bool, chain *[]string) string {
+	for len(*chain) < maxCNAMEHops {
+		target, ok := answers.cnames[strings.ToLower(name)]
+		if !ok {
+			return ""
+		}
+
+		key := strings.ToLower(target)
+		if seen[key] {
+			// CNAME loop
+			return ""
+		}
+		seen[key] = true
+		*chain = append(*chain, target)
+
+		if _, ok := answers.cnames[key]; !ok {
+			if answers.addresses[key] {
+				return ""
+			}
+			return target
+		}
+		name = target
+	}
+	return ""
+}
+
+// lookupCNAMEFallback returns the final CNAME from the system resolver as a
+// one-hop chain
+func lookupCNAMEFallback(domain string) []string {
+	cname, err := net.LookupCNAME(domain)
+	if err != nil {
+		return nil
+	}
+	cname = strings.TrimSuffix(cname, ".")
+	if cname == "" || strings.EqualFold(cname, domain) {
+		return nil
+	}
+	return []string{cname}
+}
+
+// systemNameservers reads the nameservers from resolv.conf
+func systemNameservers() []string {
+	file, err := os.Open(resolvConfPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			// Drop IPv6 zones like fe80::1%eth0
+			ip, _, _ := strings.Cut(fields[1], "%")
+			if net.ParseIP(ip) != nil {
+				servers = append(servers, net.JoinHostPort(ip, "53"))
+			}
+		}
+	}
+	return servers
+}
+
+// queryNameservers asks each server in turn for the A records of name and
+// returns the answers of the first one that responds
+func queryNameservers(servers []string, name string, timeout time.Duration) (dnsAnswers, error) {
+	var lastErr error
+	for _, server := range servers {
+		answers, err := queryA(server, name, timeout)
+		if err == nil {
+			return answers, nil
+		}
+		lastErr = err
+	}
+	return dnsAnswers{}, lastErr
+}
+
+// queryA sends a recursive A query for name over UDP, retrying over TCP if
+// the response was truncated
+func queryA(server, name string, timeout time.Duration) (dnsAnswers, error) {
+	fqdn, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return dnsAnswers{}, err
+	}
+
+	id := uint16(rand.IntN(1 << 16))
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: fqdn, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return dnsAnswers{}, err
+	}
+
+	response, err := exchangeUDP(server, packed, timeout)
+	if err != nil {
+		return dnsAnswers{}, err
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return dnsAnswers{}, err
+	}
+	if header.Truncated {
+		if response, err = exchangeTCP(server, packed, timeout); err != nil {
+			return dnsAnswers{}, err
+		}
+		if header, err = parser.Start(response); err != nil {
+			return dnsAnswers{}, err
+		}
+	}
+	if header.ID != id {
+		return dnsAnswers{}, errors.New("mismatched DNS response ID")
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return dnsAnswers{}, err
+	}
+
+	return parseAnswers(&parser)
+}
+
+// parseAnswers collects CNAME and address records from the answer section
+func parseAnswers(parser *dnsmessage.Parser) (dnsAnswers, error) {
+	answers := dnsAnswers{cnames: make(map[string]string), addresses: make(map[string]bool)}
+	for {
+		header, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return answers, nil
+		}
+		if err != nil {
+			return answers, err
+		}
+
+		owner := strings.ToLower(strings.TrimSuffix(header.Name.String(), "."))
+		switch header.Type {
+		case dnsmessage.TypeCNAME:
+			cname, err := parser.CNAMEResource()
+			if err != nil {
+				return answers, err
+			}
+			answers.cnames[owner] = strings.TrimSuffix(cname.CNAME.String(), ".")
+		case dnsmessage.TypeA, dnsmessage.TypeAAAA:
+			answers.addresses[owner] = true
+			if err := parser.SkipAnswer(); err != nil {
+				return answers, err
+			}
+		default:
+			if err := parser.SkipAnswer(); err != nil {
+				return answers, err
+			}
+		}
+	}
+}
+
+func exchangeUDP(server string, query []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1232)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// exchangeTCP sends a query with the two-byte length prefix DNS over TCP uses
+func exchangeTCP(server string, query []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// command is a livedom subcommand, e.g. "livedom probe -sc"
+type command struct {
+	name        string
+	description string
+	run         func(args []string)
+}
+
+// commands lists the available subcommands. Running livedom with only flags
+// (or no arguments at all) runs probe, so existing pipelines keep working.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"probe", "Probe targets for live HTTP/HTTPS services (default)", runProbe},
+		{"dns", "Resolve A, AAAA, CNAME and NS records without HTTP probing", runDNS},
+		{"import", "Convert httpx JSON output into livedom JSON Lines", runImport},
+		{"replay", "Re-run response analyzers over a HAR file without network traffic", runReplay},
+		{"report", "Summarize -json results as text, Markdown or HTML", runReport},
+		{"server", "Serve an HTTP API that probes submitted targets", runServer},
+		{"monitor", "Scan at an interval and report only what changed", runMonitor},
+		{"schema", "Print the JSON Schema of -json output", runSchema},
+		{"version", "Show version and build info", func(args []string) { printVersion() }},
+		{"update", "Update livedom to the latest release", func(args []string) { updateTool() }},
+		{"help", "Show this help", func(args []string) { printUsage() }},
+	}
+}
+
+// Main runs the livedom command line with args, without the program name
+func Main(args []string) {
+	runCommand(args)
+}
+
+func runCommand(args []string) {
+	// Bare flags mean the default probe command
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runProbe(args)
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			cmd.run(args[1:])
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
+	printUsage()
+	os.Exit(2)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: livedom [command] [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'livedom <command> -h' for the flags of a command.")
+}
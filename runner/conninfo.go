@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ConnectionInfo is the connection a probe's response came over, to tell
+// which load balancer node or split-horizon DNS answer served it
+type ConnectionInfo struct {
+	Reused     bool   `json:"reused"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	LocalAddr  string `json:"local_addr,omitempty"`
+}
+
+// connTracker remembers which of the client's open connections already
+// carried a response. Connections are keyed by both ends, and forgotten
+// when closed, so a later connection on the same ephemeral port isn't
+// taken for a reused one.
+type connTracker struct {
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{used: make(map[string]bool)}
+}
+
+func connKey(local, remote net.Addr) string {
+	return local.String() + "->" + remote.String()
+}
+
+// Wrap registers every connection dial opens
+func (t *connTracker) Wrap(dial fasthttp.DialFuncWithTimeout) fasthttp.DialFuncWithTimeout {
+	return func(addr string, timeout time.Duration) (net.Conn, error) {
+		conn, err := dial(addr, timeout)
+		if err != nil {
+			return nil, err
+		}
+		key := connKey(conn.LocalAddr(), conn.RemoteAddr())
+		t.mu.Lock()
+		t.used[key] = false
+		t.mu.Unlock()
+		return &trackedConn{Conn: conn, tracker: t, key: key}, nil
+	}
+}
+
+// Info describes the connection resp was read from, or returns nil when
+// it didn't come through the client (raw requests, net/http fallback)
+func (t *connTracker) Info(resp *fasthttp.Response) *ConnectionInfo {
+	local, remote := resp.LocalAddr(), resp.RemoteAddr()
+	if t == nil || local == nil || remote == nil {
+		return nil
+	}
+
+	key := connKey(local, remote)
+	t.mu.Lock()
+	reused, ok := t.used[key]
+	if ok {
+		t.used[key] = true
+	}
+	t.mu.Unlock()
+
+	return &ConnectionInfo{Reused: reused, RemoteAddr: remote.String(), LocalAddr: local.String()}
+}
+
+// trackedConn drops its connection from the tracker when closed
+type trackedConn struct {
+	net.Conn
+	tracker *connTracker
+	key     string
+	once    sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.tracker.mu.Lock()
+		delete(c.tracker.used, c.key)
+		c.tracker.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
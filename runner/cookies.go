@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CookieInfo is a cookie a response sets, with its security attributes
+type CookieInfo struct {
+	Name     string `json:"name"`
+	Secure   bool   `json:"secure"`
+	HTTPOnly bool   `json:"httponly"`
+	SameSite string `json:"samesite,omitempty"`
+}
+
+// sameSiteNames are the SameSite values as written in Set-Cookie. A bare
+// SameSite attribute means Lax to browsers.
+var sameSiteNames = map[fasthttp.CookieSameSite]string{
+	fasthttp.CookieSameSiteDefaultMode: "Lax",
+	fasthttp.CookieSameSiteLaxMode:     "Lax",
+	fasthttp.CookieSameSiteStrictMode:  "Strict",
+	fasthttp.CookieSameSiteNoneMode:    "None",
+}
+
+// responseCookies lists the cookies of every Set-Cookie header in resp
+func responseCookies(resp *fasthttp.Response) []CookieInfo {
+	var cookies []CookieInfo
+	for _, value := range resp.Header.Cookies() {
+		var cookie fasthttp.Cookie
+		if cookie.ParseBytes(value) != nil {
+			continue
+		}
+		cookies = append(cookies, CookieInfo{
+			Name:     string(cookie.Key()),
+			Secure:   cookie.Secure(),
+			HTTPOnly: cookie.HTTPOnly(),
+			SameSite: sameSiteNames[cookie.SameSite()],
+		})
+	}
+	return cookies
+}
+
+// formatCookies renders cookies for the cookies column, each name with
+// its attributes, e.g. sid(Secure,HttpOnly,Lax),lang
+func formatCookies(cookies []CookieInfo) string {
+	parts := make([]string, len(cookies))
+	for i, cookie := range cookies {
+		var flags []string
+		if cookie.Secure {
+			flags = append(flags, "Secure")
+		}
+		if cookie.HTTPOnly {
+			flags = append(flags, "HttpOnly")
+		}
+		if cookie.SameSite != "" {
+			flags = append(flags, cookie.SameSite)
+		}
+		parts[i] = cookie.Name
+		if len(flags) > 0 {
+			parts[i] += "(" + strings.Join(flags, ",") + ")"
+		}
+	}
+	return strings.Join(parts, ",")
+}
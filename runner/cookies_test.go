@@ -0,0 +1,24 @@
+package runner
+
+import "testing"
+
+func TestResponseCookies(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		want    string // formatCookies of the result
+	}{
+		{"attributes", "Set-Cookie: sid=abc; Path=/; Secure; HttpOnly; SameSite=Strict\r\n", "sid(Secure,HttpOnly,Strict)"},
+		{"no attributes", "Set-Cookie: lang=en\r\n", "lang"},
+		{"bare samesite", "Set-Cookie: a=1; SameSite\r\n", "a(Lax)"},
+		{"samesite none", "Set-Cookie: track=1; Secure; SameSite=None\r\n", "track(Secure,None)"},
+		{"several", "Set-Cookie: sid=abc; HttpOnly\r\nSet-Cookie: lang=en\r\n", "sid(HttpOnly),lang"},
+		{"none", "", ""},
+	}
+	for _, tt := range tests {
+		resp := rawResponse(t, "HTTP/1.1 200 OK\r\n"+tt.headers+"\r\n")
+		if got := formatCookies(responseCookies(resp)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CredentialCheck is the outcome of sending the -try-creds credential to a
+// page asking for Basic authentication
+type CredentialCheck struct {
+	Accepted   bool `json:"accepted"`
+	StatusCode int  `json:"status_code"`
+}
+
+// credentialChecker sends one credential to each URL at most once, however
+// often the URL comes up (retries, duplicates in the input), so a check
+// never turns into a guessing attack or locks out the account
+type credentialChecker struct {
+	authorization string
+	tried         sync.Map
+}
+
+// newCredentialChecker parses a user:pass credential. The password may
+// contain colons, the user may not.
+func newCredentialChecker(credential string) (*credentialChecker, error) {
+	user, _, ok := strings.Cut(credential, ":")
+	if !ok || user == "" {
+		return nil, fmt.Errorf("%q is not user:pass", credential)
+	}
+	return &credentialChecker{authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte(credential))}, nil
+}
+
+// Check re-sends the probe of a URL that answered 401 with Basic among its
+// challenges, this time with the credential. Any answer but another 401
+// means it was accepted: a 403 is an account without access to the page.
+func (c *credentialChecker) Check(client *fasthttp.Client, target Target, url string, challenges []AuthChallenge, config *Config) *CredentialCheck {
+	basic := false
+	for _, challenge := range challenges {
+		if strings.EqualFold(challenge.Scheme, "Basic") {
+			basic = true
+		}
+	}
+	if !basic {
+		return nil
+	}
+	if _, tried := c.tried.LoadOrStore(url, true); tried {
+		return nil
+	}
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(url)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	applyTargetSettings(req, target, config)
+	req.Header.Set("Authorization", c.authorization)
+
+	err := doFollowUp(client, req, resp, config)
+	if err != nil {
+		return nil
+	}
+	status := resp.StatusCode()
+	return &CredentialCheck{Accepted: status != fasthttp.StatusUnauthorized, StatusCode: status}
+}
+
+// formatCredentialCheck renders a check for the creds column
+func formatCredentialCheck(check *CredentialCheck) string {
+	if check.Accepted {
+		return fmt.Sprintf("creds:accepted(%d)", check.StatusCode)
+	}
+	return "creds:rejected"
+}
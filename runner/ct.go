@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	crtshURL = "https://crt.sh/?q=%s&output=json"
+	chaosURL = "https://dns.projectdiscovery.io/dns/%s/subdomains"
+)
+
+// sourceTimeout bounds each request to a passive source; crt.sh in
+// particular can be very slow for large domains
+const sourceTimeout = 2 * time.Minute
+
+// readCTTargets collects hostnames for each -ct-domain from certificate
+// transparency logs (crt.sh) and, with an API key, the Chaos dataset
+func readCTTargets(config *Config, submit func(target Target)) error {
+	client := &http.Client{Timeout: sourceTimeout}
+	chaosKey := config.ChaosKey
+	if chaosKey == "" {
+		chaosKey = os.Getenv("CHAOS_KEY")
+	}
+
+	seen := make(map[string]bool)
+	add := func(domain, host string) {
+		host = strings.ToLower(strings.TrimSpace(host))
+		host = strings.TrimPrefix(host, "*.")
+		if host == "" || seen[host] {
+			return
+		}
+		// crt.sh also matches names that merely contain the domain
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return
+		}
+		seen[host] = true
+		submit(Target{Input: host})
+	}
+
+	for _, domain := range parseScope(config.CTDomain) {
+		hosts, err := fetchCrtsh(client, domain)
+		if err != nil {
+			slog.Warn("fetching crt.sh results", "domain", domain, "error", err)
+		}
+		for _, host := range hosts {
+			add(domain, host)
+		}
+
+		if chaosKey != "" {
+			hosts, err := fetchChaos(client, domain, chaosKey)
+			if err != nil {
+				slog.Warn("fetching Chaos results", "domain", domain, "error", err)
+			}
+			for _, host := range hosts {
+				add(domain, host)
+			}
+		}
+	}
+
+	return nil
+}
+
+func fetchCrtsh(client *http.Client, domain string) ([]string, error) {
+	resp, err := client.Get(fmt.Sprintf(crtshURL, url.QueryEscape("%."+domain)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crt.sh returned %s", resp.Status)
+	}
+
+	var entries []struct {
+		NameValue  string `json:"name_value"`
+		CommonName string `json:"common_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, entry := range entries {
+		// name_value holds every SAN of the certificate, one per line
+		hosts = append(hosts, strings.Split(entry.NameValue, "\n")...)
+		hosts = append(hosts, entry.CommonName)
+	}
+	return hosts, nil
+}
+
+func fetchChaos(client *http.Client, domain, key string) ([]string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf(chaosURL, domain), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", key)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Chaos returned %s", resp.Status)
+	}
+
+	var data struct {
+		Subdomains []string `json:"subdomains"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	// Chaos returns labels relative to the domain, "" for the apex itself
+	hosts := make([]string, 0, len(data.Subdomains))
+	for _, sub := range data.Subdomains {
+		if sub == "" {
+			hosts = append(hosts, domain)
+		} else {
+			hosts = append(hosts, sub+"."+domain)
+		}
+	}
+	return hosts, nil
+}
@@ -0,0 +1,286 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dbBatchSize     = 500 // results per insert
+	dbFlushInterval = 2 * time.Second
+	dbMaxBacklog    = 50000 // results kept while the database is unavailable
+	dbTimeout       = time.Minute
+)
+
+// dbRetention is how many runs -db keeps: the newest keepRuns and those
+// younger than pruneOlder. Zero keeps everything.
+type dbRetention struct {
+	keepRuns   int
+	pruneOlder time.Duration
+}
+
+// dbStore is a database -db writes to. A run is one scan; its results are
+// keyed by host, port and path, so storing a URL again updates its row and
+// a rerun under the same -run-id doesn't add duplicates.
+type dbStore interface {
+	// migrate brings the schema of the database up to date
+	migrate(ctx context.Context) error
+	// startRun records the start of a run
+	startRun(ctx context.Context, runID string, started time.Time, scanner string, args []byte) error
+	// insert stores rows of a run
+	insert(ctx context.Context, runID string, rows []dbRow) error
+	// finishRun records the end of a run
+	finishRun(ctx context.Context, runID string, finished time.Time) error
+	// prune deletes the runs retention doesn't keep and their results,
+	// except runID, and returns how many runs it deleted
+	prune(ctx context.Context, runID string, retention dbRetention) (int64, error)
+	// rejected tells whether err means the database refused rows for good,
+	// rather than being unavailable for now
+	rejected(err error) bool
+	Close() error
+}
+
+// checkSchemaVersion refuses databases migrated by a newer livedom
+func checkSchemaVersion(version, known int) error {
+	if version > known {
+		return fmt.Errorf("the database schema is version %d, newer than this livedom knows (%d): update livedom", version, known)
+	}
+	return nil
+}
+
+// resultDB stores the results of a scan. Workers only queue results; a
+// background loop inserts them in batches, so a slow or busy database
+// doesn't hold up probes.
+type resultDB struct {
+	store     dbStore
+	runID     string
+	retention dbRetention
+
+	mu      sync.Mutex
+	pending []dbRow
+	dropped int
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// dbRow is a result as -db stores it
+type dbRow struct {
+	host   string
+	port   int
+	path   string // with the query, if any
+	url    string
+	status int
+	title  string
+	result []byte
+	seen   time.Time
+}
+
+// newRunID names a run after its start time, with a random suffix for
+// scans started at the same second on different machines
+func newRunID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// openDBStore opens the database of -db: PostgreSQL for postgres:// URLs,
+// a SQLite file otherwise
+func openDBStore(ctx context.Context, target string) (dbStore, error) {
+	if strings.HasPrefix(target, "postgres://") || strings.HasPrefix(target, "postgresql://") {
+		return openPostgres(ctx, target)
+	}
+	return openSQLite(target)
+}
+
+// newResultDB opens the database, migrates its schema if needed and
+// records the start of the run
+func newResultDB(ctx context.Context, target, runID string, args []string, retention dbRetention) (*resultDB, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+	store, err := openDBStore(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.migrate(ctx); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	scanner, _ := os.Hostname()
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if err := store.startRun(ctx, runID, time.Now(), scanner, argsJSON); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("recording the run: %w", err)
+	}
+
+	db := &resultDB{
+		store:     store,
+		runID:     runID,
+		retention: retention,
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go db.loop()
+	return db, nil
+}
+
+// Write queues a result for the next batch
+func (db *resultDB) Write(result Result) {
+	row, err := newDBRow(result)
+	if err != nil {
+		slog.Warn("skipping result for -db", "url", result.URL, "error", err)
+		return
+	}
+
+	db.mu.Lock()
+	db.pending = append(db.pending, row)
+	db.trim()
+	full := len(db.pending) >= dbBatchSize
+	db.mu.Unlock()
+
+	if full {
+		select {
+		case db.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// trim drops the oldest results beyond the backlog. Called with mu held.
+func (db *resultDB) trim() {
+	if over := len(db.pending) - dbMaxBacklog; over > 0 {
+		db.dropped += over
+		db.pending = db.pending[over:]
+	}
+}
+
+// newDBRow splits the URL of a result into the key columns
+func newDBRow(result Result) (dbRow, error) {
+	u, err := url.Parse(result.URL)
+	if err != nil {
+		return dbRow{}, err
+	}
+	port, _ := strconv.Atoi(u.Port())
+	if port == 0 {
+		port = defaultPort(u.Scheme)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return dbRow{}, err
+	}
+
+	return dbRow{
+		host:   strings.ToLower(u.Hostname()),
+		port:   port,
+		path:   u.RequestURI(),
+		url:    result.URL,
+		status: result.StatusCode,
+		title:  result.Title,
+		result: withSchema(data),
+		seen:   time.Now(),
+	}, nil
+}
+
+// defaultPort is the port URLs of scheme have without one
+func defaultPort(scheme string) int {
+	if scheme == "https" {
+		return 443
+	}
+	return 80
+}
+
+// loop inserts queued results when a batch is full, every flush interval
+// and once more when the scan is done
+func (db *resultDB) loop() {
+	defer close(db.done)
+	ticker := time.NewTicker(dbFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.wake:
+		case <-ticker.C:
+		case <-db.stop:
+			db.flush()
+			return
+		}
+		db.flush()
+	}
+}
+
+// flush inserts the queued results in batches. A batch the database
+// rejects is dropped; on other errors it stays queued for the next try.
+func (db *resultDB) flush() {
+	for {
+		db.mu.Lock()
+		n := min(len(db.pending), dbBatchSize)
+		batch := db.pending[:n]
+		db.pending = db.pending[n:]
+		db.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+		err := db.store.insert(ctx, db.runID, batch)
+		cancel()
+		switch {
+		case err == nil:
+		case db.store.rejected(err):
+			slog.Error("storing results", "error", err, "dropped", len(batch))
+			db.mu.Lock()
+			db.dropped += len(batch)
+			db.mu.Unlock()
+		default:
+			slog.Error("storing results, will retry", "error", err)
+			db.mu.Lock()
+			db.pending = slices.Concat(batch, db.pending)
+			db.trim()
+			db.mu.Unlock()
+			return
+		}
+	}
+}
+
+// Close stores what is still queued and the end time of the run, then
+// prunes old runs
+func (db *resultDB) Close() error {
+	close(db.stop)
+	<-db.done
+	defer db.store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	err := db.store.finishRun(ctx, db.runID, time.Now())
+	if err == nil {
+		var pruned int64
+		pruned, err = db.store.prune(ctx, db.runID, db.retention)
+		if err != nil {
+			err = fmt.Errorf("pruning runs: %w", err)
+		} else if pruned > 0 {
+			slog.Info("pruned old runs from the database", "runs", pruned)
+		}
+	}
+	if lost := len(db.pending) + db.dropped; lost > 0 {
+		err = errors.Join(fmt.Errorf("%d results couldn't be stored", lost), err)
+	}
+	return err
+}
@@ -0,0 +1,332 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hackruler/livedom/internal/testserver"
+)
+
+// openTestDB opens the SQLite file of a test to check what -db stored
+func openTestDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestResultDB(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "results.db")
+
+	err := Scan(context.Background(), Options{
+		Args:    []string{"-title", "-db", path},
+		Targets: []string{server.URL + "/ok", server.URL + "/gzip"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openTestDB(t, path)
+
+	// A new database gets every migration
+	var version int
+	if err := db.QueryRow("SELECT max(version) FROM livedom_migrations").Scan(&version); err != nil || version != len(sqliteMigrations) {
+		t.Errorf("schema version %d: %v", version, err)
+	}
+
+	var runID, args string
+	var finished sql.NullString
+	if err := db.QueryRow("SELECT run_id, finished, args FROM livedom_runs").Scan(&runID, &finished, &args); err != nil {
+		t.Fatal(err)
+	}
+	if !finished.Valid || !strings.Contains(args, path) {
+		t.Errorf("run %s finished %v with args %s", runID, finished, args)
+	}
+
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+	rows, err := db.Query("SELECT run_id, host, port, path, url, title, result FROM livedom_results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	titles := map[string]string{}
+	for rows.Next() {
+		var rowRun, host, rowPort, rowPath, url, title, data string
+		if err := rows.Scan(&rowRun, &host, &rowPort, &rowPath, &url, &title, &data); err != nil {
+			t.Fatal(err)
+		}
+		if rowRun != runID || host != "127.0.0.1" || rowPort != port {
+			t.Errorf("row key %s %s %s", rowRun, host, rowPort)
+		}
+		var result Result
+		if err := json.Unmarshal([]byte(data), &result); err != nil || result.URL != url {
+			t.Errorf("row result %s: %v", data, err)
+		}
+		titles[rowPath] = title
+	}
+	if titles["/ok"] != testserver.Title || len(titles) != 2 {
+		t.Errorf("paths and titles %q", titles)
+	}
+}
+
+func TestResultDBRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	store, err := openSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := store.migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for id, age := range map[string]time.Duration{"current": 100 * 24 * time.Hour, "older": 2 * time.Hour, "recent": time.Hour, "newer": time.Minute, "newest": time.Second} {
+		if err := store.startRun(ctx, id, time.Now().Add(-age), "scanner", []byte("[]")); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.insert(ctx, id, []dbRow{{host: "a.example", port: 443, path: "/", url: "https://a.example/", result: []byte("{}"), seen: time.Now()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// -keep-runs drops "older", -prune-older "recent", and the current run
+	// stays even though it's the oldest
+	db, err := newResultDB(ctx, path, "current", nil, dbRetention{keepRuns: 3, pruneOlder: 30 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	check := openTestDB(t, path)
+	var runs []string
+	rows, err := check.Query("SELECT run_id FROM livedom_runs ORDER BY run_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		runs = append(runs, id)
+	}
+	if strings.Join(runs, ",") != "current,newer,newest" {
+		t.Errorf("kept runs %q", runs)
+	}
+	// Results go with their runs
+	var results int
+	if err := check.QueryRow("SELECT count(*) FROM livedom_results").Scan(&results); err != nil || results != 3 {
+		t.Errorf("%d results left: %v", results, err)
+	}
+}
+
+func TestResultDBNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	store, err := openSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.db.Exec("INSERT INTO livedom_migrations (version, applied) VALUES (?, '')", len(sqliteMigrations)+1); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	_, err = newResultDB(context.Background(), path, "run", nil, dbRetention{})
+	if err == nil || !strings.Contains(err.Error(), "update livedom") {
+		t.Errorf("newer schema: %v", err)
+	}
+}
+
+func TestResultDBRunID(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "results.db")
+
+	// A retried scan updates the rows of its run instead of adding more
+	for range 2 {
+		err := Scan(context.Background(), Options{
+			Args:    []string{"-db", path, "-run-id", "weekly-1"},
+			Targets: []string{server.URL + "/ok"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := openTestDB(t, path)
+	var runs, results int
+	if err := db.QueryRow("SELECT count(*) FROM livedom_runs WHERE run_id = 'weekly-1'").Scan(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT count(*) FROM livedom_results WHERE run_id = 'weekly-1'").Scan(&results); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 || results != 1 {
+		t.Errorf("%d runs and %d results, want 1 of each", runs, results)
+	}
+}
+
+// TestPostgresStore needs a database to create the tables in, e.g.
+// LIVEDOM_TEST_POSTGRES=postgres://postgres@localhost/livedom_test
+func TestPostgresStore(t *testing.T) {
+	target := os.Getenv("LIVEDOM_TEST_POSTGRES")
+	if target == "" {
+		t.Skip("LIVEDOM_TEST_POSTGRES isn't set")
+	}
+	ctx := context.Background()
+	store, err := openDBStore(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	pg, ok := store.(*postgresStore)
+	if !ok {
+		t.Fatalf("%s opened a %T", target, store)
+	}
+
+	// Migrating an up-to-date database does nothing
+	for range 2 {
+		if err := store.migrate(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runID := newRunID()
+	defer pg.pool.Exec(ctx, "DELETE FROM livedom_runs WHERE run_id = $1", runID)
+	if err := store.startRun(ctx, runID, time.Now(), "scanner", []byte(`["-db"]`)); err != nil {
+		t.Fatal(err)
+	}
+	row := dbRow{host: "a.example", port: 443, path: "/", url: "https://a.example/", status: 200, title: "before", result: []byte(`{"title":"nul\u0000"}`), seen: time.Now()}
+	other := dbRow{host: "b.example", port: 80, path: "/?q=1", url: "http://b.example/?q=1", result: []byte(`{}`), seen: time.Now()}
+	if err := store.insert(ctx, runID, []dbRow{row, other}); err != nil {
+		t.Fatal(err)
+	}
+	row.title = "after"
+	if err := store.insert(ctx, runID, []dbRow{row}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.finishRun(ctx, runID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var results int
+	var title string
+	err = pg.pool.QueryRow(ctx, "SELECT count(*), max(title) FILTER (WHERE host = 'a.example') FROM livedom_results WHERE run_id = $1", runID).Scan(&results, &title)
+	if err != nil || results != 2 || title != "after" {
+		t.Errorf("%d results, title %q: %v", results, title, err)
+	}
+
+	// A batch with a row PostgreSQL refuses is rejected as a whole
+	bad := row
+	bad.result = []byte("not json")
+	if err := store.insert(ctx, runID, []dbRow{other, bad}); err == nil || !store.rejected(err) {
+		t.Errorf("invalid row: %v", err)
+	}
+	if store.rejected(context.DeadlineExceeded) {
+		t.Error("a timeout counts as rejected")
+	}
+}
+
+var (
+	errUnavailable = errors.New("connection refused")
+	errRejected    = errors.New("invalid input")
+)
+
+// fakeStore is a dbStore failing inserts with the errors queued in fail
+type fakeStore struct {
+	mu       sync.Mutex
+	fail     []error
+	inserted int
+}
+
+func (s *fakeStore) migrate(ctx context.Context) error { return nil }
+func (s *fakeStore) startRun(ctx context.Context, runID string, started time.Time, scanner string, args []byte) error {
+	return nil
+}
+func (s *fakeStore) finishRun(ctx context.Context, runID string, finished time.Time) error {
+	return nil
+}
+func (s *fakeStore) prune(ctx context.Context, runID string, retention dbRetention) (int64, error) {
+	return 0, nil
+}
+func (s *fakeStore) rejected(err error) bool { return errors.Is(err, errRejected) }
+func (s *fakeStore) Close() error            { return nil }
+
+func (s *fakeStore) insert(ctx context.Context, runID string, rows []dbRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.fail) > 0 {
+		err := s.fail[0]
+		s.fail = s.fail[1:]
+		return err
+	}
+	s.inserted += len(rows)
+	return nil
+}
+
+func TestResultDBFlush(t *testing.T) {
+	tests := []struct {
+		name     string
+		fail     []error
+		inserted int
+		lost     string
+	}{
+		{"stored", nil, dbBatchSize + 1, ""},
+		// Batches wait while the database is away
+		{"unavailable", []error{errUnavailable, errUnavailable}, dbBatchSize + 1, ""},
+		// A rejected batch is dropped and counted, the next one goes on
+		{"rejected", []error{errRejected}, 1, "500 results couldn't be stored"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{fail: tt.fail}
+			db := &resultDB{
+				store: store,
+				wake:  make(chan struct{}, 1),
+				stop:  make(chan struct{}),
+				done:  make(chan struct{}),
+			}
+			for range dbBatchSize + 1 {
+				db.Write(Result{URL: "https://a.example/"})
+			}
+			for range tt.fail {
+				db.flush()
+			}
+			go db.loop()
+
+			err := db.Close()
+			if store.inserted != tt.inserted {
+				t.Errorf("%d results inserted, want %d", store.inserted, tt.inserted)
+			}
+			if tt.lost == "" && err != nil || tt.lost != "" && (err == nil || !strings.Contains(err.Error(), tt.lost)) {
+				t.Errorf("Close() = %v, want %q", err, tt.lost)
+			}
+		})
+	}
+}
+
+func TestResultDBBacklog(t *testing.T) {
+	db := &resultDB{}
+	for range dbMaxBacklog + 10 {
+		db.Write(Result{URL: "https://a.example/"})
+	}
+	if len(db.pending) != dbMaxBacklog || db.dropped != 10 {
+		t.Errorf("%d pending and %d dropped", len(db.pending), db.dropped)
+	}
+}
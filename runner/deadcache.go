@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// deadCache remembers hosts whose names didn't resolve, so runs within
+// -dead-cache-ttl skip them instead of waiting on DNS again. Monitoring a
+// mostly stable list spends most of its time on the same dead names.
+type deadCache struct {
+	path string
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]time.Time // when each host last failed DNS
+}
+
+// loadDeadCache reads the cache file. A missing file is an empty cache.
+func loadDeadCache(path string, ttl time.Duration) (*deadCache, error) {
+	cache := &deadCache{path: path, ttl: ttl, hosts: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.hosts); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Dead reports whether host failed DNS within the TTL
+func (c *deadCache) Dead(host string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	failed, ok := c.hosts[strings.ToLower(host)]
+	return ok && time.Since(failed) < c.ttl
+}
+
+// Record updates host after it was probed: a name that doesn't exist is
+// remembered, one that answered is forgotten. Timeouts and other errors
+// say nothing about the name and leave it alone.
+func (c *deadCache) Record(host string, err error) {
+	if c == nil || net.ParseIP(host) != nil {
+		return
+	}
+	host = strings.ToLower(host)
+
+	var dnsErr *net.DNSError
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err == nil:
+		delete(c.hosts, host)
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		c.hosts[host] = time.Now()
+	}
+}
+
+// Save writes the cache back, without the entries that expired. The file
+// is replaced at once, so an interrupted run can't leave half of it.
+func (c *deadCache) Save() error {
+	c.mu.Lock()
+	for host, failed := range c.hosts {
+		if time.Since(failed) >= c.ttl {
+			delete(c.hosts, host)
+		}
+	}
+	data, err := json.MarshalIndent(c.hosts, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".dead-cache-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
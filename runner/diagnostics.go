@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// debugInterval is how often -debug logs runtime statistics
+const debugInterval = 10 * time.Second
+
+// startPprof serves net/http/pprof on addr, e.g. ":6060", so a slow or
+// bloated scan can be profiled while it runs. Profiles give away a lot
+// about the scan, so a bare port listens on localhost only.
+func startPprof(addr string) error {
+	listener, err := net.Listen("tcp", pprofAddr(addr))
+	if err != nil {
+		return err
+	}
+	slog.Info("pprof listening", "url", fmt.Sprintf("http://%s/debug/pprof/", listener.Addr()))
+	go http.Serve(listener, nil)
+	return nil
+}
+
+// pprofAddr binds an address without a host to 127.0.0.1
+func pprofAddr(addr string) string {
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return addr
+}
+
+// logRuntimeStats logs goroutine, heap and GC figures plus the number of
+// running probes at debug level every debugInterval until stop is closed
+func logRuntimeStats(running func() int, stop <-chan struct{}) {
+	ticker := time.NewTicker(debugInterval)
+	defer ticker.Stop()
+
+	started := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			slog.Debug("runtime stats",
+				"elapsed", time.Since(started).Round(time.Second),
+				"running", running(),
+				"goroutines", runtime.NumGoroutine(),
+				"heap_in_use", formatBytes(mem.HeapInuse),
+				"sys", formatBytes(mem.Sys),
+				"gcs", mem.NumGC)
+		}
+	}
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. 1.5MB
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,21 @@
+package runner
+
+import "testing"
+
+func TestPprofAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{":6060", "127.0.0.1:6060"},
+		{"localhost:6060", "localhost:6060"},
+		{"0.0.0.0:6060", "0.0.0.0:6060"},
+		{"[::1]:6060", "[::1]:6060"},
+		{"6060", "6060"},
+	}
+	for _, tt := range tests {
+		if got := pprofAddr(tt.addr); got != tt.want {
+			t.Errorf("pprofAddr(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// connectionAttemptDelay is how long a connection attempt gets before the
+// next address is tried in parallel (RFC 8305 section 5)
+const connectionAttemptDelay = 250 * time.Millisecond
+
+// dnsCacheDuration is how long resolved addresses are reused, the same
+// as fasthttp's own dialer
+const dnsCacheDuration = time.Minute
+
+// dialHappyEyeballs connects to addr like RFC 8305: all IPv6 and IPv4
+// addresses of the host are tried alternately, starting a new attempt
+// every connectionAttemptDelay or as soon as one fails, and the first
+// connection wins. A host with a broken AAAA record costs 250ms instead
+// of a full timeout. Every address is checked against guard.
+func dialHappyEyeballs(addr string, timeout time.Duration, guard *addressGuard) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ips, err := dialAddresses.Lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips = interleaveFamilies(ips)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type attempt struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan attempt, len(ips))
+	dialer := guardedDialer(0, guard)
+	var next <-chan time.Time
+	started, failed := 0, 0
+	var lastErr error
+
+	for {
+		if started < len(ips) && next == nil {
+			ip := ips[started]
+			go func() {
+				conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+				results <- attempt{conn, err}
+			}()
+			started++
+			next = time.After(connectionAttemptDelay)
+		}
+
+		select {
+		case result := <-results:
+			if result.err == nil {
+				// Close connections of attempts that finish later
+				go func(pending int) {
+					for range pending {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(started - failed - 1)
+				return result.conn, nil
+			}
+			failed++
+			lastErr = result.err
+			if failed == len(ips) {
+				if errors.Is(lastErr, context.DeadlineExceeded) {
+					return nil, fasthttp.ErrDialTimeout
+				}
+				return nil, lastErr
+			}
+			next = nil // Start the next attempt now
+		case <-next:
+			next = nil
+		}
+	}
+}
+
+// interleaveFamilies orders addresses IPv6, IPv4, IPv6... starting with
+// the family of the first one, keeping the resolver's order within each
+func interleaveFamilies(ips []net.IP) []net.IP {
+	var first, second []net.IP
+	firstIsV4 := len(ips) > 0 && ips[0].To4() != nil
+	for _, ip := range ips {
+		if (ip.To4() != nil) == firstIsV4 {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+
+	ordered := make([]net.IP, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(second) {
+			ordered = append(ordered, second[i])
+		}
+	}
+	return ordered
+}
+
+// maxCachedHosts is when the cache starts dropping expired hosts
+const maxCachedHosts = 10000
+
+// dialAddresses caches host lookups for dialHappyEyeballs
+var dialAddresses = &addressCache{entries: make(map[string]addressEntry)}
+
+type addressCache struct {
+	mu      sync.Mutex
+	entries map[string]addressEntry
+}
+
+type addressEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// Lookup returns the IPv4 and IPv6 addresses of host
+func (c *addressCache) Lookup(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.ips, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxCachedHosts {
+		for cached, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, cached)
+			}
+		}
+	}
+	c.entries[host] = addressEntry{ips: ips, expires: now.Add(dnsCacheDuration)}
+	return ips, nil
+}
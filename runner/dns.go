@@ -0,0 +1,188 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// defaultDNSRecords are the record types shown in DNS-only mode unless
+// -dns-records says otherwise
+var defaultDNSRecords = []string{"A", "AAAA", "CNAME", "NS"}
+
+// supportedDNSRecords are the record types -dns-records accepts
+var supportedDNSRecords = map[string]bool{
+	"A": true, "AAAA": true, "CNAME": true, "TXT": true, "NS": true, "MX": true, "PTR": true,
+}
+
+// DNSResult holds the records found for a host in DNS-only mode
+type DNSResult struct {
+	Host        string              `json:"host"`
+	Records     map[string][]string `json:"records"`
+	Wildcard    bool                `json:"wildcard,omitempty"`
+	Passthrough []string            `json:"passthrough,omitempty"`
+	Labels      map[string]string   `json:"labels,omitempty"`
+}
+
+// runDNS implements "livedom dns", which is the same as "livedom -dns-only"
+func runDNS(args []string) {
+	config := parseFlags("dns", args)
+	config.DNSOnly = true
+	processSubdomainsStreaming(config)
+}
+
+// parseDNSRecords parses a comma-separated list of record types like "A,MX,TXT"
+func parseDNSRecords(s string) ([]string, error) {
+	var types []string
+	for _, part := range strings.Split(s, ",") {
+		recordType := strings.ToUpper(strings.TrimSpace(part))
+		if recordType == "" {
+			continue
+		}
+		if !supportedDNSRecords[recordType] {
+			return nil, fmt.Errorf("unsupported record type %q", part)
+		}
+		types = append(types, recordType)
+	}
+	return types, nil
+}
+
+// checkDNS resolves the configured record types for a target without
+// sending any HTTP traffic. ok is false when the host doesn't resolve at all.
+func checkDNS(target string, config *Config) (DNSResult, bool) {
+	domain := extractDomain(target)
+	result := DNSResult{Host: domain}
+	if domain == "" {
+		return result, false
+	}
+
+	types := config.dnsRecords
+	if len(types) == 0 {
+		types = defaultDNSRecords
+	}
+	result.Records = resolveRecords(domain, types, config)
+
+	for _, values := range result.Records {
+		if len(values) > 0 {
+			return result, true
+		}
+	}
+	return result, false
+}
+
+// resolveRecords looks up each record type for domain. Types without
+// records are present with an empty list.
+func resolveRecords(domain string, types []string, config *Config) map[string][]string {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+
+	resolver := net.DefaultResolver
+	records := make(map[string][]string, len(types))
+
+	var ips []net.IPAddr
+	ipsResolved := false
+
+	for _, recordType := range types {
+		var values []string
+
+		switch recordType {
+		case "A", "AAAA":
+			// One lookup serves both address types
+			if !ipsResolved {
+				ips, _ = resolver.LookupIPAddr(ctx, domain)
+				ipsResolved = true
+			}
+			for _, ip := range ips {
+				if (ip.IP.To4() != nil) == (recordType == "A") {
+					values = append(values, ip.IP.String())
+				}
+			}
+
+		case "CNAME":
+			// The whole chain, in order
+			values = resolveCNAMEChain(domain, config.Timeout)
+
+		case "TXT":
+			values, _ = resolver.LookupTXT(ctx, domain)
+
+		case "NS":
+			if nss, err := resolver.LookupNS(ctx, domain); err == nil {
+				for _, ns := range nss {
+					values = append(values, strings.TrimSuffix(ns.Host, "."))
+				}
+			}
+
+		case "MX":
+			if mxs, err := resolver.LookupMX(ctx, domain); err == nil {
+				sort.Slice(mxs, func(i, j int) bool { return mxs[i].Pref < mxs[j].Pref })
+				for _, mx := range mxs {
+					values = append(values, fmt.Sprintf("%d %s", mx.Pref, strings.TrimSuffix(mx.Host, ".")))
+				}
+			}
+
+		case "PTR":
+			// Reverse lookups only make sense for IP targets
+			if net.ParseIP(domain) != nil {
+				if names, err := resolver.LookupAddr(ctx, domain); err == nil {
+					for _, name := range names {
+						values = append(values, strings.TrimSuffix(name, "."))
+					}
+				}
+			}
+		}
+
+		if values == nil {
+			values = []string{}
+		}
+		records[recordType] = values
+	}
+
+	return records
+}
+
+// recordColumns returns one column per record type, in order
+func recordColumns(records map[string][]string, types []string) []column {
+	var columns []column
+	for _, recordType := range types {
+		var recordColor color.Attribute
+		switch recordType {
+		case "A", "AAAA":
+			recordColor = color.FgCyan
+		case "CNAME":
+			recordColor = color.FgYellow
+		case "NS", "MX":
+			recordColor = color.FgGreen
+		default:
+			recordColor = color.FgBlue
+		}
+		columns = append(columns, newColumn(recordType, strings.Join(records[recordType], ","), recordColor))
+	}
+	return columns
+}
+
+func displayDNSResult(result DNSResult, config *Config) {
+	if config.JSONOutput {
+		displayJSON(result)
+		return
+	}
+
+	types := config.dnsRecords
+	if len(types) == 0 {
+		types = defaultDNSRecords
+	}
+
+	columns := recordColumns(result.Records, types)
+	if result.Wildcard {
+		columns = append(columns, newColumn("wildcard", "wildcard", color.FgHiBlack))
+	}
+	columns = append(columns, passthroughColumns(result.Passthrough)...)
+	if config.table != nil {
+		config.table.Add(result.Host, columns)
+		return
+	}
+	fmt.Fprintln(color.Output, formatLine(result.Host, columns))
+}
@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseDNSRecords(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"A,MX,TXT", []string{"A", "MX", "TXT"}, false},
+		{" aaaa , ptr,", []string{"AAAA", "PTR"}, false},
+		{"", nil, false},
+		{"A,SRV", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDNSRecords(tt.value)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("parseDNSRecords(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestCheckDNS(t *testing.T) {
+	// IP targets resolve to themselves without a query
+	tests := []struct {
+		target string
+		types  []string
+		host   string
+		ok     bool
+		a      []string
+		aaaa   []string
+	}{
+		{"https://192.0.2.1:8443/path", []string{"A", "AAAA"}, "192.0.2.1", true, []string{"192.0.2.1"}, []string{}},
+		{"[2001:db8::1]:443", []string{"A", "AAAA"}, "2001:db8::1", true, []string{}, []string{"2001:db8::1"}},
+		{"192.0.2.1", []string{"AAAA"}, "192.0.2.1", false, nil, []string{}},
+	}
+	for _, tt := range tests {
+		result, ok := checkDNS(tt.target, &Config{Timeout: time.Second, dnsRecords: tt.types})
+		if result.Host != tt.host || ok != tt.ok {
+			t.Errorf("checkDNS(%q) = %q, %v", tt.target, result.Host, ok)
+		}
+		if !slices.Equal(result.Records["A"], tt.a) || !slices.Equal(result.Records["AAAA"], tt.aaaa) {
+			t.Errorf("checkDNS(%q) records %v", tt.target, result.Records)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// volatileAttributes change on every page load without the page changing
+var volatileAttributes = map[string]bool{
+	"nonce":     true,
+	"integrity": true,
+	"data-csrf": true,
+}
+
+// domHash hashes the structure and text of an HTML document, leaving out
+// what changes between loads of the same page: scripts, comments, nonces,
+// integrity hashes, CSRF tokens and whitespace. Bundle file names still
+// count, since <script> elements are dropped entirely.
+func domHash(document []byte) string {
+	doc, err := html.Parse(bytes.NewReader(document))
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	writeNormalizedNode(h, doc)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeNormalizedNode(h hash.Hash, n *html.Node) {
+	switch n.Type {
+	case html.CommentNode, html.DoctypeNode:
+		return
+	case html.TextNode:
+		if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+			io.WriteString(h, "\x00t"+text)
+		}
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "noscript", "template":
+			return
+		}
+		io.WriteString(h, "\x00<"+n.Data)
+		writeNormalizedAttributes(h, n)
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeNormalizedNode(h, c)
+	}
+	if n.Type == html.ElementNode {
+		io.WriteString(h, "\x00>")
+	}
+}
+
+// writeNormalizedAttributes writes the stable attributes of an element in
+// name order
+func writeNormalizedAttributes(h hash.Hash, n *html.Node) {
+	attrs := make(map[string]string, len(n.Attr))
+	for _, attr := range n.Attr {
+		attrs[attr.Key] = attr.Val
+	}
+
+	// Token values of hidden inputs and csrf meta tags differ per load
+	if (n.Data == "input" && strings.EqualFold(attrs["type"], "hidden")) ||
+		(n.Data == "meta" && strings.Contains(strings.ToLower(attrs["name"]), "csrf")) {
+		delete(attrs, "value")
+		delete(attrs, "content")
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		if !volatileAttributes[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		io.WriteString(h, " "+name+"="+attrs[name])
+	}
+}
@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// typicalRequestTime is the average probe time assumed for the dry-run
+// estimate; real scans vary a lot with the targets
+const typicalRequestTime = 500 * time.Millisecond
+
+// dryRun reads and counts the input like a real scan would, then prints
+// what the scan would do without sending any traffic
+func dryRun(config *Config) {
+	var targets, requests, outOfScope, skipped int
+	seen := newTargetSet()
+	unique := 0
+
+	if config.TCPOnly {
+		ports, err := parsePorts(config.Ports)
+		if err != nil {
+			fatal("parsing ports", err)
+		}
+		config.ports = ports
+	}
+	if config.UDPProbe {
+		ports, err := parsePorts(config.UDPPorts)
+		if err != nil {
+			fatal("parsing -udp-ports", err)
+		}
+		config.udpPorts = ports
+	}
+
+	config.inputCounts = newInputCounts()
+	err := readTargets(config, func(target Target) {
+		targets++
+		host := extractDomain(target.Input)
+		if seen.Add(target.Input) {
+			unique++
+		}
+		if len(config.scope) > 0 && !inScope(host, host, config.scope) {
+			outOfScope++
+		}
+		if config.apexBudget != nil {
+			config.apexBudget.Add(target)
+			return
+		}
+		requests += requestsPerTarget(target.Input, config)
+	})
+	if err != nil {
+		fatal("reading input", err)
+	}
+	if config.apexBudget != nil {
+		for _, target := range config.apexBudget.Drain() {
+			requests += requestsPerTarget(target.Input, config)
+		}
+		skipped = config.apexBudget.Skipped()
+	}
+
+	config.inputCounts.Print(os.Stdout)
+	fmt.Printf("Targets: %d (%d unique)\n", targets, unique)
+	if len(config.scope) > 0 {
+		fmt.Printf("Out of scope: %d\n", outOfScope)
+	}
+	if config.apexBudget != nil {
+		fmt.Printf("Over -max-per-apex: %d\n", skipped)
+	}
+	fmt.Printf("Requests: up to %d\n", requests)
+	fmt.Printf("Threads: %d, timeout: %s\n", config.Threads, config.Timeout)
+
+	rounds := (requests + config.Threads - 1) / max(config.Threads, 1)
+	typical := time.Duration(rounds) * typicalRequestTime
+	worst := time.Duration(rounds) * config.Timeout
+	fmt.Printf("Estimated duration: %s typical, up to %s if every request times out\n",
+		typical.Round(time.Second), worst.Round(time.Second))
+}
+
+// requestsPerTarget is how many connections or requests a target can take:
+// HTTPS and then HTTP for bare hosts, one per port in -tcp-only mode and
+// two in -udp-probe mode. Targets no URL can be built from take none.
+func requestsPerTarget(input string, config *Config) int {
+	switch {
+	case config.DNSOnly:
+		return 1
+	case config.TCPOnly:
+		return len(config.ports)
+	case config.UDPProbe:
+		// QUIC, then DTLS when QUIC got no answer
+		return 2 * len(config.udpPorts)
+	default:
+		addr, err := parseTargetAddr(input)
+		if err != nil {
+			return 0
+		}
+		return len(addr.URLs())
+	}
+}
@@ -0,0 +1,214 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/hackruler/livedom/internal/testserver"
+)
+
+// scan runs livedom in-process with args against targets and returns the
+// -json results. Output goes through the same pipeline as a real scan, so
+// scans share os.Stdout and can't run in parallel.
+func scan(t *testing.T, args []string, targets ...string) []Result {
+	t.Helper()
+
+	input := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(input, []byte(strings.Join(targets, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, output := os.Stdout, color.Output
+	os.Stdout, color.Output = writer, writer
+	defer func() {
+		os.Stdout, color.Output = stdout, output
+	}()
+
+	lines := make(chan []string)
+	go func() {
+		var read []string
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(nil, 1024*1024)
+		for scanner.Scan() {
+			read = append(read, scanner.Text())
+		}
+		io.Copy(io.Discard, reader)
+		lines <- read
+	}()
+
+	runCommand(append([]string{"probe", "-json", "-silent", "-f", input}, args...))
+	writer.Close()
+
+	var results []Result
+	for _, line := range <-lines {
+		var result Result
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("decoding %q: %v", line, err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// scanOne is scan for a single target that must produce one result
+func scanOne(t *testing.T, args []string, target string) Result {
+	t.Helper()
+	results := scan(t, args, target)
+	if len(results) != 1 {
+		t.Fatalf("scanning %s: got %d results, want 1", target, len(results))
+	}
+	return results[0]
+}
+
+func TestE2EPages(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	t.Run("live page", func(t *testing.T) {
+		result := scanOne(t, []string{"-sc", "-title", "-ct"}, server.URL+"/ok")
+		if result.StatusCode != 200 || result.Title != testserver.Title {
+			t.Errorf("got status %d, title %q", result.StatusCode, result.Title)
+		}
+		if !strings.HasPrefix(result.ContentType, "text/html") {
+			t.Errorf("got content type %q", result.ContentType)
+		}
+	})
+
+	t.Run("bare host falls back to HTTP", func(t *testing.T) {
+		host := strings.TrimPrefix(server.URL, "http://")
+		result := scanOne(t, []string{"-sc"}, host)
+		if result.URL != "http://"+host || result.StatusCode != 404 {
+			t.Errorf("got %s [%d], want http://%s [404]", result.URL, result.StatusCode, host)
+		}
+	})
+
+	t.Run("chunked with trailer", func(t *testing.T) {
+		result := scanOne(t, []string{"-title", "-te"}, server.URL+"/chunked")
+		if result.TransferEncoding != "chunked" {
+			t.Errorf("got transfer encoding %q, want chunked", result.TransferEncoding)
+		}
+		if len(result.Trailers) != 1 || result.Trailers[0] != "X-Checksum" {
+			t.Errorf("got trailers %v, want [X-Checksum]", result.Trailers)
+		}
+		if result.Title != testserver.Title {
+			t.Errorf("got title %q", result.Title)
+		}
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		result := scanOne(t, []string{"-title"}, server.URL+"/gzip")
+		if result.StatusCode != 200 || result.Title != testserver.Title {
+			t.Errorf("got status %d, title %q", result.StatusCode, result.Title)
+		}
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		result := scanOne(t, nil, server.URL+"/basic")
+		if result.StatusCode != 401 {
+			t.Errorf("got status %d, want 401", result.StatusCode)
+		}
+		if len(result.Auth) != 1 || result.Auth[0].Scheme != "Basic" || result.Auth[0].Realm != "testserver" {
+			t.Errorf("got auth %+v, want Basic realm testserver", result.Auth)
+		}
+	})
+
+	t.Run("many targets", func(t *testing.T) {
+		var targets []string
+		for i := 0; i < 50; i++ {
+			targets = append(targets, server.URL+"/ok?n="+strconv.Itoa(i))
+		}
+		results := scan(t, []string{"-t", "5"}, targets...)
+		if len(results) != len(targets) {
+			t.Errorf("got %d results for %d targets", len(results), len(targets))
+		}
+	})
+}
+
+func TestE2ERedirects(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	t.Run("redirect is reported, not followed", func(t *testing.T) {
+		result := scanOne(t, []string{"-sc"}, server.URL+"/redirect")
+		if result.StatusCode != 302 || result.URL != server.URL+"/redirect" {
+			t.Errorf("got %s [%d]", result.URL, result.StatusCode)
+		}
+	})
+
+	t.Run("redirect chain", func(t *testing.T) {
+		result := scanOne(t, []string{"-redirect-check"}, server.URL+"/redirect")
+		if len(result.RedirectChain) == 0 || result.RedirectChain[len(result.RedirectChain)-1] != server.URL+"/ok" {
+			t.Errorf("got chain %v, want it to end at /ok", result.RedirectChain)
+		}
+		if len(result.RedirectIssues) != 0 {
+			t.Errorf("got issues %v, want none", result.RedirectIssues)
+		}
+	})
+
+	t.Run("lowercase location header", func(t *testing.T) {
+		result := scanOne(t, []string{"-redirect-check"}, server.URL+"/redirect-lowercase")
+		if len(result.RedirectChain) == 0 || result.RedirectChain[len(result.RedirectChain)-1] != server.URL+"/ok" {
+			t.Errorf("got chain %v, want it to end at /ok", result.RedirectChain)
+		}
+	})
+
+	t.Run("redirect loop", func(t *testing.T) {
+		result := scanOne(t, []string{"-redirect-check"}, server.URL+"/redirect-loop")
+		if len(result.RedirectIssues) != 1 || result.RedirectIssues[0] != "loop" {
+			t.Errorf("got issues %v, want [loop]", result.RedirectIssues)
+		}
+	})
+}
+
+func TestE2EMisbehaving(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+	tlsServer := testserver.NewTLS()
+	defer tlsServer.Close()
+
+	// Responses fasthttp rejects but net/http can read
+	for _, path := range []string{"/broken-chunked", "/bare-lf"} {
+		t.Run(path, func(t *testing.T) {
+			result := scanOne(t, nil, server.URL+path)
+			if !result.NetHTTPFallback || result.StatusCode != 200 {
+				t.Errorf("got status %d, fallback %v, want 200 through the fallback", result.StatusCode, result.NetHTTPFallback)
+			}
+		})
+	}
+
+	t.Run("failures are dropped", func(t *testing.T) {
+		results := scan(t, []string{"-timeout", "500ms"}, server.URL+"/slow", server.URL+"/reset", server.URL+"/bad-header")
+		if len(results) != 0 {
+			t.Errorf("got %d results, want none", len(results))
+		}
+	})
+
+	failures := []struct {
+		target string
+		reason string
+	}{
+		{server.URL + "/slow", "timeout"},
+		{server.URL + "/reset", "reset"},
+		{server.URL + "/bad-header", "other"},
+		{tlsServer.URL + "/ok", "tls"},
+	}
+	for _, failure := range failures {
+		t.Run("failed "+failure.reason, func(t *testing.T) {
+			result := scanOne(t, []string{"-timeout", "500ms", "-include-failed"}, failure.target)
+			if result.Failed != failure.reason {
+				t.Errorf("got failure %q, want %q", result.Failed, failure.reason)
+			}
+		})
+	}
+}
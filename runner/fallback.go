@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxFallbackBodySize caps how much of a body the net/http fallback reads
+const maxFallbackBodySize = 10 * 1024 * 1024
+
+// needsFallback reports whether a fasthttp error is one of its strict
+// parsing failures that a more lenient client may get past: broken chunked
+// encoding, oversized headers, or headers it can't parse, which embedded
+// devices and IoT panels often send (bare LF line endings, odd status lines)
+func needsFallback(err error) bool {
+	var brokenChunk fasthttp.ErrBrokenChunk
+	var smallBuffer *fasthttp.ErrSmallBuffer
+	if errors.As(err, &brokenChunk) || errors.As(err, &smallBuffer) {
+		return true
+	}
+	// A bare EOF means the server sent nothing at all
+	if errors.Is(err, fasthttp.ErrTimeout) || err == io.EOF {
+		return false
+	}
+	// Header parse errors have no exported type
+	return strings.HasPrefix(err.Error(), "error when reading response headers")
+}
+
+// netHTTPFallback sends req again with net/http and stores the response in
+// resp as if fasthttp had received it. Such servers often work in curl and
+// browsers, which keep what they could read; the body read before a
+// decoding error is kept here too.
+func netHTTPFallback(req *fasthttp.Request, resp *fasthttp.Response, config *Config) error {
+	httpReq, err := http.NewRequest(string(req.Header.Method()), req.URI().String(), bytes.NewReader(req.Body()))
+	if err != nil {
+		return err
+	}
+	for name, value := range req.Header.All() {
+		if strings.EqualFold(string(name), "Host") {
+			httpReq.Host = string(value)
+			continue
+		}
+		httpReq.Header.Add(string(name), string(value))
+	}
+
+	transport := &http.Transport{
+		// Leave like the fasthttp client does: through the -via proxies
+		// in turn, or directly past the guard
+		DialContext: func(_ context.Context, _, addr string) (net.Conn, error) {
+			return config.dialTarget(addr, config.Timeout)
+		},
+		DisableCompression: true,
+		DisableKeepAlives:  true,
+	}
+
+	client := &http.Client{
+		Timeout: config.Timeout,
+		// Report redirects like fasthttp does instead of following them
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: transport,
+	}
+
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	// Keep the partial body on decoding errors
+	body, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxFallbackBodySize))
+
+	resp.Reset()
+	resp.SetStatusCode(httpResp.StatusCode)
+	for name, values := range httpResp.Header {
+		for _, value := range values {
+			resp.Header.Add(name, value)
+		}
+	}
+	// Trailers are only filled in once the body was read, and fasthttp
+	// merges them into the headers too
+	for name, values := range httpResp.Trailer {
+		for _, value := range values {
+			resp.Header.Add(name, value)
+		}
+	}
+	resp.SetBody(body)
+	// fasthttp represents chunked encoding as a Content-Length of -1
+	for _, encoding := range httpResp.TransferEncoding {
+		if encoding == "chunked" {
+			resp.Header.SetContentLength(-1)
+		}
+	}
+	return nil
+}
+
+// transferInfo returns the Transfer-Encoding of a response and the names
+// of the trailers it announced and actually sent
+func transferInfo(resp *fasthttp.Response) (string, []string) {
+	var trailers []string
+	for _, name := range strings.Split(headerValue(resp, "Trailer"), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && headerValue(resp, name) != "" {
+			trailers = append(trailers, name)
+		}
+	}
+	return string(resp.Header.Peek("Transfer-Encoding")), trailers
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hackruler/livedom/internal/testserver"
+	"github.com/valyala/fasthttp"
+)
+
+func TestTransferInfoTrailerCase(t *testing.T) {
+	// The trailer is announced in one case and sent in another
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	resp.Header.DisableNormalizing()
+	raw := "HTTP/1.1 200 OK\r\n" +
+		"transfer-encoding: chunked\r\n" +
+		"trailer: X-Checksum, X-Missing\r\n\r\n" +
+		"2\r\nok\r\n0\r\n" +
+		"x-checksum: abc\r\n\r\n"
+	if err := resp.Read(bufio.NewReader(strings.NewReader(raw))); err != nil {
+		t.Fatal(err)
+	}
+
+	encoding, trailers := transferInfo(resp)
+	if encoding != "chunked" {
+		t.Errorf("transfer encoding = %q, want chunked", encoding)
+	}
+	if !slices.Equal(trailers, []string{"X-Checksum"}) {
+		t.Errorf("trailers = %q, want [X-Checksum]", trailers)
+	}
+}
+
+// connectProxy tunnels CONNECT requests like a -via proxy, counting them
+func connectProxy(t *testing.T, tunnels *atomic.Int64) string {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream, err := net.Dial("tcp", r.Host)
+		if r.Method != http.MethodConnect || err != nil {
+			http.Error(w, "no tunnel", http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		tunnels.Add(1)
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy.URL
+}
+
+func TestNetHTTPFallbackEgress(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	var eu, us atomic.Int64
+	pool, err := parseVia("eu="+connectProxy(t, &eu)+",us="+connectProxy(t, &us), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard, err := newAddressGuard(true, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  *Config
+		blocked bool
+	}{
+		{"direct", &Config{}, false},
+		{"guarded", &Config{guard: guard}, true},
+		// The proxies resolve and connect, out of the guard's sight
+		{"via", &Config{guard: guard, via: pool}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Timeout = time.Second
+			for range 2 {
+				req := fasthttp.AcquireRequest()
+				resp := fasthttp.AcquireResponse()
+				req.SetRequestURI(server.URL + "/bare-lf")
+				err := netHTTPFallback(req, resp, tt.config)
+				if tt.blocked {
+					if !errors.Is(err, errAddressBlocked) {
+						t.Errorf("got %v, want the address blocked", err)
+					}
+				} else if err != nil || !strings.Contains(string(resp.Body()), testserver.Title) {
+					t.Errorf("got %d, %v", resp.StatusCode(), err)
+				}
+				fasthttp.ReleaseRequest(req)
+				fasthttp.ReleaseResponse(resp)
+			}
+		})
+	}
+	// Both requests of the via case went through the proxies in turn
+	if eu.Load() != 1 || us.Load() != 1 {
+		t.Errorf("%d tunnels through eu and %d through us, want 1 each", eu.Load(), us.Load())
+	}
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"log/slog"
+)
+
+const (
+	// fdsPerWorker is how many descriptors a probe may hold at once: its
+	// HTTP connection plus a DNS lookup or certificate fetch
+	fdsPerWorker = 2
+	// fdReserve is kept free for stdio, output files and the resolver
+	fdReserve = 64
+)
+
+// applyFDLimit caps -t and -max-conns-per-host so the scan stays within the
+// open file limit, instead of failing with "too many open files" halfway
+// through. With -auto-fd-limit the soft limit is raised to the hard limit
+// first.
+func applyFDLimit(config *Config) {
+	if config.AutoFDLimit {
+		if err := raiseFDLimit(); err != nil {
+			slog.Warn("could not raise open file limit", "error", err)
+		}
+	}
+
+	limit, ok := fdLimit()
+	if !ok {
+		return
+	}
+
+	budget := int(limit) - fdReserve
+	if budget < fdsPerWorker {
+		budget = fdsPerWorker
+	}
+
+	if config.Threads*fdsPerWorker > budget {
+		threads := budget / fdsPerWorker
+		slog.Warn("open file limit too low, reducing threads (raise it with ulimit -n or -auto-fd-limit)",
+			"limit", limit, "threads", config.Threads, "reduced_to", threads)
+		config.Threads = threads
+	}
+	if config.MaxConnsPerHost > budget {
+		config.MaxConnsPerHost = budget
+	}
+}
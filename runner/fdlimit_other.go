@@ -0,0 +1,12 @@
+//go:build !unix
+
+package runner
+
+// fdLimit reports no limit on platforms without RLIMIT_NOFILE
+func fdLimit() (uint64, bool) {
+	return 0, false
+}
+
+func raiseFDLimit() error {
+	return nil
+}
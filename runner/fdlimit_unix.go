@@ -0,0 +1,27 @@
+//go:build unix
+
+package runner
+
+import "syscall"
+
+// fdLimit returns the soft limit on open files
+func fdLimit() (uint64, bool) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, false
+	}
+	return uint64(rlimit.Cur), true
+}
+
+// raiseFDLimit raises the soft limit on open files to the hard limit
+func raiseFDLimit() error {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return err
+	}
+	if rlimit.Cur >= rlimit.Max {
+		return nil
+	}
+	rlimit.Cur = rlimit.Max
+	return syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rlimit)
+}
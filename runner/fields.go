@@ -0,0 +1,192 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// outputField is a field -fields can select: the result columns it shows
+// in text output, the keys it has in JSON, and the show flag that fills it
+type outputField struct {
+	name    string
+	columns []string
+	keys    []string
+	enable  func(config *Config)
+}
+
+// outputFields lists the -fields names. Fields without enable are filled
+// by their own flag (cert-expiry, similarity) or only when they apply.
+var outputFields = []outputField{
+	{"url", []string{"url"}, []string{"url"}, nil},
+	{"status", []string{"status"}, []string{"status_code"}, func(c *Config) { c.ShowStatusCode = true }},
+	{"content-type", []string{"content-type"}, []string{"content_type"}, func(c *Config) { c.ShowContentType = true }},
+	{"length", []string{"length"}, []string{"content_length"}, func(c *Config) { c.ShowContentLength = true }},
+	{"transfer-encoding", []string{"transfer-encoding"}, []string{"transfer_encoding", "trailers"}, func(c *Config) { c.ShowTransferEncoding = true }},
+	{"hash", []string{"hash"}, []string{"hash"}, func(c *Config) { c.ShowHash = true }},
+	{"dom-hash", []string{"dom-hash"}, []string{"dom_hash"}, func(c *Config) { c.DOMHash = true }},
+	{"title", []string{"title"}, []string{"title"}, func(c *Config) { c.ShowTitle = true }},
+	{"server", []string{"server"}, []string{"server"}, func(c *Config) { c.ShowServer = true }},
+	{"time", []string{"time"}, []string{"response_time"}, func(c *Config) { c.ShowResponseTime = true }},
+	{"rtt", []string{"rtt"}, []string{"rtt"}, func(c *Config) { c.RTT = true }},
+	{"hops", []string{"hops"}, []string{"hops"}, func(c *Config) { c.RTT, c.Hops = true, true }},
+	{"ip", []string{"ip"}, []string{"ip"}, func(c *Config) { c.ShowIP = true }},
+	{"cname", []string{"cname"}, []string{"cname", "cname_chain"}, func(c *Config) { c.ShowCNAME = true }},
+	{"sans", []string{"sans"}, []string{"sans"}, func(c *Config) { c.ExtractSANs = true }},
+	{"secrets", []string{"secrets"}, []string{"secrets"}, func(c *Config) { c.Secrets = true }},
+	{"meta", []string{"canonical", "generator", "site-name"}, []string{"meta"}, func(c *Config) { c.ShowMeta = true }},
+	{"cookies", []string{"cookies"}, []string{"cookies"}, func(c *Config) { c.Cookies = true }},
+	{"lang", []string{"lang"}, []string{"lang"}, func(c *Config) { c.ShowLang = true }},
+	{"cert-expiry", []string{"cert-expiry"}, []string{"cert_expiry", "cert_not_after"}, nil},
+	{"cert-mismatch", []string{"cert-mismatch"}, []string{"cert_mismatch", "cert_name"}, func(c *Config) { c.CertMismatch = true }},
+	{"hsts", []string{"hsts"}, []string{"hsts"}, func(c *Config) { c.HSTS = true }},
+	{"ntlm", []string{"ntlm"}, []string{"ntlm"}, func(c *Config) { c.NTLM = true }},
+	{"creds", []string{"creds"}, []string{"creds"}, nil},
+	{"redirect-issues", []string{"redirect-issues"}, []string{"redirect_chain", "redirect_issues"}, func(c *Config) { c.RedirectCheck = true }},
+	{"similarity", []string{"similarity"}, []string{"similarity"}, nil},
+	{"body-redirect", []string{"body-redirect"}, []string{"body_redirect"}, func(c *Config) { c.BodyRedirect = true }},
+	{"scripts", []string{"scripts"}, []string{"scripts"}, func(c *Config) { c.JS = true }},
+	{"api", []string{"api"}, []string{"api_specs"}, func(c *Config) { c.APIDetect = true }},
+	{"graphql", []string{"graphql"}, []string{"graphql"}, func(c *Config) { c.GraphQL = true }},
+	{"well-known", []string{"well-known"}, []string{"well_known"}, func(c *Config) { c.WellKnown = true }},
+	{"banner", []string{"banner"}, []string{"banner"}, nil},
+	{"nonhttp", []string{"nonhttp"}, []string{"nonhttp"}, nil},
+	{"failed", []string{"failed"}, []string{"failed"}, func(c *Config) { c.IncludeFailed = true }},
+	{"wildcard", []string{"wildcard"}, []string{"wildcard"}, nil},
+	{"origin", []string{"origin"}, []string{"origin_ips"}, nil},
+	{"regions", []string{"regions"}, []string{"regions", "regions_differ"}, nil},
+	{"protected-by", []string{"protected-by"}, []string{"protected_by"}, nil},
+	{"rendered", []string{"rendered"}, []string{"rendered"}, nil},
+	{"auth", []string{"auth"}, []string{"auth"}, nil},
+	{"fallback", []string{"fallback"}, []string{"net_http_fallback"}, nil},
+	{"connection", nil, []string{"connection"}, nil},
+}
+
+// parseFields parses a comma-separated -fields list, keeping its order
+func parseFields(s string) ([]outputField, error) {
+	var fields []outputField
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		field, ok := lookupField(name)
+		if !ok {
+			names := make([]string, len(outputFields))
+			for i, f := range outputFields {
+				names[i] = f.name
+			}
+			return nil, fmt.Errorf("unknown field %q, use %s", name, strings.Join(names, ","))
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields given")
+	}
+	return fields, nil
+}
+
+func lookupField(name string) (outputField, bool) {
+	for _, field := range outputFields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	return outputField{}, false
+}
+
+// fieldColumns returns the columns of result selected by -fields, in
+// -fields order
+func fieldColumns(result Result, config *Config) []column {
+	byName := map[string]column{"url": {Name: "url", Value: result.URL, Color: fmt.Sprint}}
+	for _, col := range resultColumns(result, config) {
+		byName[col.Name] = col
+	}
+
+	var columns []column
+	for _, field := range config.fields {
+		for _, name := range field.columns {
+			if col, ok := byName[name]; ok {
+				columns = append(columns, col)
+			} else {
+				// Shown only when they apply, e.g. banner
+				columns = append(columns, newColumn(name, "", color.FgWhite))
+			}
+		}
+	}
+	return columns
+}
+
+// fieldLine renders -fields columns like formatLine: the URL as is, the
+// other values bracketed
+func fieldLine(columns []column, colored bool) string {
+	output := make([]string, 0, len(columns))
+	for _, col := range columns {
+		switch {
+		case col.Name == "url":
+			output = append(output, col.Value)
+		case colored:
+			output = append(output, col.Color("["+col.Value+"]"))
+		default:
+			output = append(output, "["+col.Value+"]")
+		}
+	}
+	return strings.Join(output, " ")
+}
+
+// fieldJSON encodes the -fields of result as a JSON object with its keys
+// in -fields order. Selected fields without a value are null.
+func fieldJSON(result Result, fields []outputField) ([]byte, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, field := range fields {
+		for _, key := range field.keys {
+			if buf.Len() > 1 {
+				buf.WriteByte(',')
+			}
+			value, ok := values[key]
+			if !ok {
+				value = json.RawMessage("null")
+			}
+			fmt.Fprintf(&buf, "%q:%s", key, value)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// displayFields is displaySingleResult for -fields
+func displayFields(result Result, config *Config) {
+	if config.JSONOutput {
+		data, err := fieldJSON(result, config.fields)
+		if err != nil {
+			slog.Error("encoding JSON", "error", err)
+			return
+		}
+		displayJSON(json.RawMessage(data))
+		return
+	}
+
+	columns := fieldColumns(result, config)
+	if config.table != nil {
+		// The table is keyed by URL already
+		columns = slices.DeleteFunc(columns, func(col column) bool { return col.Name == "url" })
+		config.table.Add(result.URL, columns)
+		return
+	}
+	fmt.Fprintln(color.Output, fieldLine(columns, true))
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultHashes is the starter list of known-boring body hashes (default
+// server pages, parking pages...) shipped with livedom
+//
+//go:embed data/boring-hashes.txt
+var defaultHashes string
+
+// loadFilterHashes builds the set of body hashes to suppress from the
+// embedded starter list plus an optional user file in the same format
+func loadFilterHashes(path string) (map[string]bool, error) {
+	hashes := make(map[string]bool)
+	if err := readHashList(strings.NewReader(defaultHashes), hashes); err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		if err := readHashList(file, hashes); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	return hashes, nil
+}
+
+func readHashList(r io.Reader, hashes map[string]bool) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Anything after the hash is a free-form comment
+		hash := strings.ToLower(strings.Fields(line)[0])
+		hashes[hash] = true
+	}
+	return scanner.Err()
+}
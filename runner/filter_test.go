@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// boringPages are the pages data/boring-hashes.txt was taken from
+const boringPages = "testdata/boring-pages"
+
+func TestDefaultHashes(t *testing.T) {
+	hashes, err := loadFilterHashes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(boringPages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := make(map[string]bool)
+	for _, entry := range entries {
+		body, err := os.ReadFile(filepath.Join(boringPages, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// -hash covers the first 8KB
+		sum := sha256.Sum256(body[:min(len(body), 8192)])
+		hash := hex.EncodeToString(sum[:])
+		if !hashes[hash] {
+			t.Errorf("%s: hash %s is not listed", entry.Name(), hash)
+		}
+		pages[hash] = true
+	}
+	for hash := range hashes {
+		if !pages[hash] {
+			t.Errorf("listed hash %s has no page in %s", hash, boringPages)
+		}
+	}
+}
+
+func TestFilterDefaultHashes(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir(boringPages)))
+	defer server.Close()
+
+	entries, err := os.ReadDir(boringPages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var targets []string
+	for _, entry := range entries {
+		targets = append(targets, server.URL+"/"+entry.Name())
+	}
+
+	if results := scan(t, []string{"-sc"}, targets...); len(results) != len(targets) {
+		t.Fatalf("got %d results without filtering, want %d", len(results), len(targets))
+	}
+	if results := scan(t, []string{"-sc", "-filter-default-hashes"}, targets...); len(results) != 0 {
+		for _, result := range results {
+			t.Errorf("%s [%s] wasn't filtered", result.URL, result.Hash)
+		}
+	}
+}
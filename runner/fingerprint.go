@@ -0,0 +1,160 @@
+package runner
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// fingerprintTokens is how many of the most frequent words are kept
+	fingerprintTokens = 50
+	// maxFingerprintBodySize caps how much of the body is tokenized
+	maxFingerprintBodySize = 64 * 1024
+)
+
+// fingerprint is a compact word histogram of a page's visible text. Words
+// containing digits are skipped, so rotating nonces, timestamps and CSRF
+// tokens don't register as content changes.
+type fingerprint map[string]int
+
+// fingerprintStore keeps the fingerprint of each URL from the previous run
+// and collects the current ones, saved back to the same file at the end
+type fingerprintStore struct {
+	mu       sync.Mutex
+	path     string
+	previous map[string]storedFingerprint
+	current  map[string]storedFingerprint
+}
+
+type storedFingerprint struct {
+	Tokens  fingerprint `json:"tokens"`
+	Updated time.Time   `json:"updated"`
+}
+
+// loadFingerprintStore reads the fingerprints of the last run; a missing
+// file just means this is the first run
+func loadFingerprintStore(path string) (*fingerprintStore, error) {
+	store := &fingerprintStore{
+		path:     path,
+		previous: make(map[string]storedFingerprint),
+		current:  make(map[string]storedFingerprint),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.previous); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Compare records the fingerprint of url and returns its similarity to the
+// previous run in percent, or -1 if the URL wasn't seen before
+func (s *fingerprintStore) Compare(url string, fp fingerprint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current[url] = storedFingerprint{Tokens: fp, Updated: time.Now().UTC()}
+
+	previous, ok := s.previous[url]
+	if !ok {
+		return -1
+	}
+	return int(math.Round(fingerprintSimilarity(previous.Tokens, fp) * 100))
+}
+
+// Save writes the fingerprints back. URLs not probed in this run keep
+// their old fingerprint so a host that was briefly down isn't "new" later.
+func (s *fingerprintStore) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged := make(map[string]storedFingerprint, len(s.previous)+len(s.current))
+	for url, fp := range s.previous {
+		merged[url] = fp
+	}
+	for url, fp := range s.current {
+		merged[url] = fp
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// computeFingerprint returns the top word histogram of an HTML body
+func computeFingerprint(body []byte) fingerprint {
+	if len(body) > maxFingerprintBodySize {
+		body = body[:maxFingerprintBodySize]
+	}
+	text, _ := visibleText(body)
+
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) < 3 || strings.IndexFunc(word, unicode.IsDigit) != -1 {
+			continue
+		}
+		counts[word]++
+	}
+
+	words := make([]string, 0, len(counts))
+	for word := range counts {
+		words = append(words, word)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if counts[words[i]] != counts[words[j]] {
+			return counts[words[i]] > counts[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	if len(words) > fingerprintTokens {
+		words = words[:fingerprintTokens]
+	}
+
+	fp := make(fingerprint, len(words))
+	for _, word := range words {
+		fp[word] = counts[word]
+	}
+	return fp
+}
+
+// fingerprintSimilarity is the cosine similarity of two histograms, 1 for
+// identical content and 0 for nothing in common
+func fingerprintSimilarity(a, b fingerprint) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	var dot, normA, normB float64
+	for word, count := range a {
+		normA += float64(count * count)
+		dot += float64(count * b[word])
+	}
+	for _, count := range b {
+		normB += float64(count * count)
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
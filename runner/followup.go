@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// newFollowUpRequest builds a GET of url for a follow-up request of the
+// probe of target: redirect hops, NTLM, API specs, GraphQL, well-known
+// files and scripts. It carries the probe's headers, except that target
+// headers and Host overrides only go to the probed host itself, not to
+// the other hosts redirects and scripts lead to. Release it with
+// fasthttp.ReleaseRequest.
+func newFollowUpRequest(url string, target Target, config *Config) *fasthttp.Request {
+	req := fasthttp.AcquireRequest()
+	req.SetRequestURI(url)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	sameHost := strings.EqualFold(extractDomain(url), extractDomain(target.Input))
+	if !sameHost {
+		target.Headers = nil
+	}
+	applyTargetHeaders(req, target, config)
+	if !sameHost {
+		req.UseHostHeader = false
+	}
+	return req
+}
+
+// doFollowUp sends a follow-up request like the probe itself, within
+// -asn-rate-limit
+func doFollowUp(client *fasthttp.Client, req *fasthttp.Request, resp *fasthttp.Response, config *Config) error {
+	config.asnLimit.Wait(extractDomain(string(req.URI().Host())))
+
+	err := client.DoTimeout(req, resp, config.Timeout)
+	config.stats.recordRequest(err)
+	return err
+}
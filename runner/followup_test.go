@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestFollowUpRequestHeaders(t *testing.T) {
+	config := &Config{headers: headerFlag{{"X-Global", "g"}, {"Host", "internal.example.com"}}}
+	target := Target{Input: "https://app.example.com", Headers: []Header{{"X-Target", "t"}}}
+
+	req := newFollowUpRequest("https://app.example.com/.well-known/security.txt", target, config)
+	defer fasthttp.ReleaseRequest(req)
+	if string(req.Header.Method()) != "GET" {
+		t.Errorf("method = %s, want GET", req.Header.Method())
+	}
+	if string(req.Header.Peek("X-Global")) != "g" || string(req.Header.Peek("X-Target")) != "t" {
+		t.Errorf("probed host is missing headers:\n%s", req.Header.Header())
+	}
+	if !req.UseHostHeader || string(req.Header.Host()) != "internal.example.com" {
+		t.Errorf("Host override %q not applied", req.Header.Host())
+	}
+
+	// A redirect to another host gets the global headers only
+	other := newFollowUpRequest("https://cdn.example.net/app.js", target, config)
+	defer fasthttp.ReleaseRequest(other)
+	if string(other.Header.Peek("X-Global")) != "g" || len(other.Header.Peek("X-Target")) != 0 {
+		t.Errorf("other host got:\n%s", other.Header.Header())
+	}
+	if other.UseHostHeader {
+		t.Error("Host override sent to another host")
+	}
+}
+
+func TestFollowUpRateLimit(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+	}))
+	defer server.Close()
+
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	limiter := newASNRateLimiter(1, time.Second)
+	limiter.prefixes = []asnPrefix{{loopback, "AS0"}}
+	config := &Config{Timeout: time.Second, asnLimit: limiter, headers: headerFlag{{"X-Global", "g"}}}
+
+	req := newFollowUpRequest(server.URL+"/graphql", Target{Input: server.URL}, config)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+	if err := doFollowUp(&fasthttp.Client{}, req, resp, config); err != nil {
+		t.Fatal(err)
+	}
+	if headers.Get("X-Global") != "g" {
+		t.Errorf("request headers %v", headers)
+	}
+	if _, ok := limiter.next["AS0"]; !ok {
+		t.Error("follow-up request didn't take an -asn-rate-limit slot")
+	}
+}
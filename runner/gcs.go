@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"cloud.google.com/go/storage"
+)
+
+// gcsStore puts objects into a Google Cloud Storage bucket with the
+// application default credentials: GOOGLE_APPLICATION_CREDENTIALS, the
+// gcloud login, or the service account of the instance
+type gcsStore struct {
+	client *storage.Client
+	bucket *storage.BucketHandle
+}
+
+func newGCSStore(ctx context.Context, bucket string) (*gcsStore, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsStore{client: client, bucket: client.Bucket(bucket)}, nil
+}
+
+// PutFile uploads the file at path as key
+func (s *gcsStore) PutFile(ctx context.Context, key, path, contentType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Canceling the context abandons a partial upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := s.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, f); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (s *gcsStore) Close() error {
+	return s.client.Close()
+}
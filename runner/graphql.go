@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// graphQLPaths are where GraphQL servers are usually mounted
+var graphQLPaths = []string{
+	"/graphql",
+	"/api/graphql",
+	"/v1/graphql",
+	"/graphql/v1",
+	"/query",
+	"/gql",
+}
+
+// introspectionQuery asks for the smallest part of the schema; servers with
+// introspection disabled answer it with an error instead
+const introspectionQuery = `{"query":"query{__schema{queryType{name}}}"}`
+
+// GraphQLEndpoint is a GraphQL endpoint found by -graphql
+type GraphQLEndpoint struct {
+	URL           string `json:"url"`
+	Introspection bool   `json:"introspection"`
+}
+
+// detectGraphQL posts the introspection query to the common GraphQL paths
+// on the host of targetURL and returns the ones that answer like GraphQL
+func detectGraphQL(client *fasthttp.Client, target Target, targetURL string, config *Config) []GraphQLEndpoint {
+	base, err := url.Parse(targetURL)
+	if err != nil {
+		return nil
+	}
+
+	var endpoints []GraphQLEndpoint
+	for _, path := range graphQLPaths {
+		endpointURL := base.ResolveReference(&url.URL{Path: path}).String()
+		if endpoint, ok := probeGraphQL(client, target, endpointURL, config); ok {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// probeGraphQL sends the introspection query to one URL. Any JSON answer
+// with data or errors is GraphQL, whatever the status: many servers reject
+// a disabled introspection query with a 400.
+func probeGraphQL(client *fasthttp.Client, target Target, endpointURL string, config *Config) (GraphQLEndpoint, bool) {
+	req := newFollowUpRequest(endpointURL, target, config)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBodyString(introspectionQuery)
+
+	err := doFollowUp(client, req, resp, config)
+	if err != nil || resp.StatusCode() >= 500 || resp.StatusCode() == fasthttp.StatusNotFound {
+		return GraphQLEndpoint{}, false
+	}
+
+	var answer struct {
+		Data *struct {
+			Schema *json.RawMessage `json:"__schema"`
+		} `json:"data"`
+		Errors []json.RawMessage `json:"errors"`
+	}
+	if json.Unmarshal(resp.Body(), &answer) != nil || (answer.Data == nil && len(answer.Errors) == 0) {
+		return GraphQLEndpoint{}, false
+	}
+	introspection := answer.Data != nil && answer.Data.Schema != nil
+	return GraphQLEndpoint{URL: endpointURL, Introspection: introspection}, true
+}
+
+// formatGraphQL renders endpoints for the graphql column, marking the ones
+// with introspection enabled
+func formatGraphQL(endpoints []GraphQLEndpoint) string {
+	parts := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		path := endpoint.URL
+		if u, err := url.Parse(endpoint.URL); err == nil {
+			path = u.Path
+		}
+		if endpoint.Introspection {
+			path += "(introspection)"
+		}
+		parts[i] = path
+	}
+	return "graphql:" + strings.Join(parts, ",")
+}
@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errAddressBlocked is returned for connections -disallow-private or
+// -disallow-metadata refused to open
+var errAddressBlocked = errors.New("address blocked")
+
+// metadataAddresses are the instance metadata and platform endpoints of
+// cloud providers, which hand out credentials to anyone on the instance
+var metadataAddresses = []string{
+	"169.254.169.254", // AWS, GCP, Azure, Oracle, DigitalOcean...
+	"169.254.169.253", // AWS DNS
+	"169.254.170.2",   // AWS ECS task metadata
+	"fd00:ec2::254",   // AWS IPv6
+	"168.63.129.16",   // Azure wire server
+	"100.100.100.200", // Alibaba Cloud
+}
+
+// addressGuard decides which addresses probes may connect to
+type addressGuard struct {
+	private  bool // refuse RFC 1918, loopback, link-local and ULA addresses
+	metadata bool // refuse metadataAddresses
+	allow    []*net.IPNet
+}
+
+// newAddressGuard parses the -allow-ips CIDRs (or single IPs) that are
+// exempt from the guard
+func newAddressGuard(private, metadata bool, allow string) (*addressGuard, error) {
+	guard := &addressGuard{private: private, metadata: metadata}
+	for _, entry := range strings.Split(allow, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if strings.Contains(entry, ":") {
+				entry += "/128"
+			} else {
+				entry += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		guard.allow = append(guard.allow, network)
+	}
+	return guard, nil
+}
+
+// Check returns an error wrapping errAddressBlocked if ip may not be
+// connected to
+func (g *addressGuard) Check(ip net.IP) error {
+	if g == nil || ip == nil {
+		return nil
+	}
+	for _, network := range g.allow {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	if g.metadata || g.private {
+		for _, address := range metadataAddresses {
+			if ip.Equal(net.ParseIP(address)) {
+				return fmt.Errorf("%w: %s is a cloud metadata address (see -allow-ips)", errAddressBlocked, ip)
+			}
+		}
+	}
+	if g.private && (ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+		return fmt.Errorf("%w: %s is a private address (see -allow-ips)", errAddressBlocked, ip)
+	}
+	return nil
+}
+
+// guardedDialer returns a dialer that applies guard to the address it
+// actually connects to, after name resolution, so DNS names and redirects
+// pointing inside can't get around it. A nil guard allows everything.
+func guardedDialer(timeout time.Duration, guard *addressGuard) *net.Dialer {
+	return &net.Dialer{Timeout: timeout, Control: guard.control}
+}
+
+func (g *addressGuard) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	return g.Check(net.ParseIP(host))
+}
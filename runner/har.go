@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HAR 1.2 structures (http://www.softwareishard.com/blog/har-12-spec/).
+// Only the fields Burp, ZAP and browser devtools need for import are included.
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harWriter streams HAR entries to a file as probes complete, so large scans
+// don't have to hold every request/response in memory. The closing brackets
+// of the document are written by Close.
+type harWriter struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	count  int
+}
+
+func newHARWriter(path string) (*harWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &harWriter{
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+	w.writer.WriteString(`{"log":{"version":"1.2","creator":{"name":"livedom","version":"1.0"},"pages":[],"entries":[`)
+	return w, nil
+}
+
+// Record adds a request/response pair to the HAR log.
+func (w *harWriter) Record(req *fasthttp.Request, resp *fasthttp.Response, started time.Time, elapsed time.Duration) {
+	entry := harEntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Time:            float64(elapsed) / float64(time.Millisecond),
+		Request:         buildHARRequest(req),
+		Response:        buildHARResponse(resp),
+		Timings: harTimings{
+			Send:    0,
+			Wait:    float64(elapsed) / float64(time.Millisecond),
+			Receive: 0,
+		},
+	}
+	if addr := resp.RemoteAddr(); addr != nil {
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+			entry.ServerIPAddress = host
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.count > 0 {
+		w.writer.WriteByte(',')
+	}
+	w.writer.Write(data)
+	w.count++
+}
+
+func (w *harWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writer.WriteString("]}}\n")
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+func buildHARRequest(req *fasthttp.Request) harRequest {
+	r := harRequest{
+		Method:      string(req.Header.Method()),
+		URL:         req.URI().String(),
+		HTTPVersion: string(req.Header.Protocol()),
+		Cookies:     []harNameValue{},
+		Headers:     []harNameValue{},
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(req.Body()),
+	}
+
+	for k, v := range req.Header.All() {
+		r.Headers = append(r.Headers, harNameValue{Name: string(k), Value: string(v)})
+	}
+
+	if parsed, err := url.Parse(r.URL); err == nil {
+		for name, values := range parsed.Query() {
+			for _, value := range values {
+				r.QueryString = append(r.QueryString, harNameValue{Name: name, Value: value})
+			}
+		}
+	}
+
+	return r
+}
+
+func buildHARResponse(resp *fasthttp.Response) harResponse {
+	body := resp.Body()
+	r := harResponse{
+		Status:      resp.StatusCode(),
+		StatusText:  http.StatusText(resp.StatusCode()),
+		HTTPVersion: string(resp.Header.Protocol()),
+		Cookies:     []harNameValue{},
+		Headers:     []harNameValue{},
+		Content: harContent{
+			Size:     len(body),
+			MimeType: string(resp.Header.ContentType()),
+		},
+		RedirectURL: headerValue(resp, "Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+
+	for k, v := range resp.Header.All() {
+		r.Headers = append(r.Headers, harNameValue{Name: string(k), Value: string(v)})
+	}
+
+	// Binary bodies must be base64 encoded to survive JSON
+	if utf8.Valid(body) {
+		r.Content.Text = string(body)
+	} else {
+		r.Content.Text = base64.StdEncoding.EncodeToString(body)
+		r.Content.Encoding = "base64"
+	}
+
+	return r
+}
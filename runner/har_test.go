@@ -0,0 +1,13 @@
+package runner
+
+import "testing"
+
+func TestHARRedirectURL(t *testing.T) {
+	resp := rawResponse(t, "HTTP/1.1 301 Moved Permanently\r\n"+
+		"location: https://www.example.com/\r\n"+
+		"Content-Length: 0\r\n\r\n")
+
+	if got := buildHARResponse(resp).RedirectURL; got != "https://www.example.com/" {
+		t.Errorf("redirectURL = %q", got)
+	}
+}
@@ -0,0 +1,33 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// headerValue returns the first value of a response header whatever the
+// case of its name. The client keeps header names as received, so Peek
+// only finds headers spelled exactly like the lookup, and servers don't
+// agree on case: Go sends Www-Authenticate, Envoy sends everything
+// lowercase.
+func headerValue(resp *fasthttp.Response, name string) string {
+	for key, value := range resp.Header.All() {
+		if strings.EqualFold(string(key), name) {
+			return string(value)
+		}
+	}
+	return ""
+}
+
+// headerValues returns every value of a response header whatever the case
+// of its name
+func headerValues(resp *fasthttp.Response, name string) []string {
+	var values []string
+	for key, value := range resp.Header.All() {
+		if strings.EqualFold(string(key), name) {
+			values = append(values, string(value))
+		}
+	}
+	return values
+}
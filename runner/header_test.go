@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"bufio"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// rawResponse parses a response the way the client does, keeping header
+// names as sent
+func rawResponse(t *testing.T, raw string) *fasthttp.Response {
+	t.Helper()
+	resp := fasthttp.AcquireResponse()
+	t.Cleanup(func() { fasthttp.ReleaseResponse(resp) })
+	resp.Header.DisableNormalizing()
+	if err := resp.Header.Read(bufio.NewReader(strings.NewReader(raw))); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHeaderValue(t *testing.T) {
+	resp := rawResponse(t, "HTTP/1.1 401 Unauthorized\r\n"+
+		"strict-transport-security: max-age=300\r\n"+
+		"Www-Authenticate: Basic realm=\"a\"\r\n"+
+		"WWW-AUTHENTICATE: NTLM\r\n"+
+		"Content-Length: 0\r\n\r\n")
+
+	if got := headerValue(resp, "Strict-Transport-Security"); got != "max-age=300" {
+		t.Errorf("Strict-Transport-Security = %q", got)
+	}
+	if got := headerValue(resp, "Expect-CT"); got != "" {
+		t.Errorf("missing header = %q", got)
+	}
+	if got, want := headerValues(resp, "WWW-Authenticate"), []string{`Basic realm="a"`, "NTLM"}; !slices.Equal(got, want) {
+		t.Errorf("WWW-Authenticate = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// histogramWidth is the length of the longest -status-histogram bar
+const histogramWidth = 40
+
+// statusHistogram is the -status-histogram report
+type statusHistogram struct {
+	Total   int64            `json:"total"`
+	Classes map[string]int64 `json:"classes"` // 2xx, 3xx...
+	Codes   map[string]int64 `json:"codes"`
+}
+
+func newStatusHistogram(statuses map[int]int64) statusHistogram {
+	histogram := statusHistogram{Classes: make(map[string]int64), Codes: make(map[string]int64)}
+	for code, n := range statuses {
+		histogram.Total += n
+		histogram.Classes[statusClass(code)] += n
+		histogram.Codes[strconv.Itoa(code)] = n
+	}
+	return histogram
+}
+
+// statusClass returns "2xx" for 200 to 299 and so on
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// printStatusHistogram writes the counts per status class and code to
+// stderr, as bars or as a JSON object with -json, e.g.
+//
+//	2xx     640  64.0%  ████████████████████████████████████████
+//	  200     600
+//	  204      40
+//	4xx     360  36.0%  ██████████████████████
+//	  403     360
+func printStatusHistogram(statuses map[int]int64, jsonOutput bool) {
+	histogram := newStatusHistogram(statuses)
+	if jsonOutput {
+		data, _ := json.Marshal(map[string]statusHistogram{"status_histogram": histogram})
+		fmt.Fprintln(os.Stderr, string(data))
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "Status codes:")
+	if histogram.Total == 0 {
+		fmt.Fprintln(os.Stderr, "  no HTTP responses")
+		return
+	}
+
+	var largest int64
+	for _, n := range histogram.Classes {
+		largest = max(largest, n)
+	}
+
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+
+	class := ""
+	for _, code := range codes {
+		if statusClass(code) != class {
+			class = statusClass(code)
+			n := histogram.Classes[class]
+			bar := strings.Repeat("█", max(int(n*histogramWidth/largest), 1))
+			fmt.Fprintf(os.Stderr, "  %s %7d %5.1f%%  %s\n", class, n, float64(n)*100/float64(histogram.Total), bar)
+		}
+		fmt.Fprintf(os.Stderr, "    %d %7d\n", code, statuses[code])
+	}
+}
@@ -0,0 +1,158 @@
+package runner
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// preloadedTLDs lists the top-level domains that are on the HSTS preload
+// list as a whole. It is not the full list, which only -hsts-preload-file
+// provides
+//
+//go:embed data/hsts-preload-tlds.txt
+var preloadedTLDs string
+
+// HSTSInfo is the HSTS posture of an HTTPS host
+type HSTSInfo struct {
+	Enabled           bool   `json:"enabled"`
+	MaxAge            int64  `json:"max_age,omitempty"`
+	IncludeSubdomains bool   `json:"include_subdomains,omitempty"`
+	PreloadDirective  bool   `json:"preload_directive,omitempty"`
+	PreloadedTLD      bool   `json:"preloaded_tld,omitempty"`
+	Preloaded         *bool  `json:"preloaded,omitempty"`
+	ExpectCT          string `json:"expect_ct,omitempty"`
+}
+
+// hstsPreloadList maps preloaded domains to whether their entry includes
+// subdomains
+type hstsPreloadList map[string]bool
+
+// loadPreloadedTLDs reads the embedded list of preloaded top-level domains
+func loadPreloadedTLDs() (hstsPreloadList, error) {
+	list := make(hstsPreloadList)
+	if err := readHSTSPreload(strings.NewReader(preloadedTLDs), list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// loadHSTSPreload reads a full preload list from path
+func loadHSTSPreload(path string) (hstsPreloadList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := make(hstsPreloadList)
+	if err := readHSTSPreload(file, list); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return list, nil
+}
+
+func readHSTSPreload(r io.Reader, list hstsPreloadList) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		domain := strings.ToLower(strings.TrimSuffix(fields[0], "."))
+		list[domain] = list[domain] || (len(fields) > 1 && fields[1] == "include_subdomains")
+	}
+	return scanner.Err()
+}
+
+// Contains reports whether host is preloaded, directly or through a parent
+// entry that includes subdomains
+func (l hstsPreloadList) Contains(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if _, ok := l[host]; ok {
+		return true
+	}
+	for i := strings.Index(host, "."); i != -1; i = strings.Index(host, ".") {
+		host = host[i+1:]
+		if l[host] {
+			return true
+		}
+	}
+	return false
+}
+
+// hstsInfo returns the HSTS posture of an HTTPS response from host
+func hstsInfo(resp *fasthttp.Response, host string, config *Config) *HSTSInfo {
+	info := &HSTSInfo{
+		PreloadedTLD: config.hstsTLDs.Contains(host),
+		ExpectCT:     headerValue(resp, "Expect-CT"),
+	}
+	// Without a full list there's no telling whether host is preloaded
+	if config.hstsPreload != nil {
+		preloaded := config.hstsPreload.Contains(host)
+		info.Preloaded = &preloaded
+	}
+	parseHSTS(headerValue(resp, "Strict-Transport-Security"), info)
+	return info
+}
+
+// parseHSTS parses a Strict-Transport-Security header value
+func parseHSTS(value string, info *HSTSInfo) {
+	for _, directive := range strings.Split(value, ";") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			if maxAge, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(arg), `"`), 10, 64); err == nil {
+				info.Enabled = true
+				info.MaxAge = maxAge
+			}
+		case "includesubdomains":
+			info.IncludeSubdomains = true
+		case "preload":
+			info.PreloadDirective = true
+		}
+	}
+
+	// max-age=0 tells browsers to forget the host
+	if info.MaxAge == 0 {
+		info.Enabled = false
+	}
+}
+
+// formatHSTS renders HSTSInfo for the text column
+func formatHSTS(info *HSTSInfo) string {
+	if info == nil {
+		return ""
+	}
+
+	var parts []string
+	if info.Enabled {
+		parts = append(parts, fmt.Sprintf("max-age=%d", info.MaxAge))
+		if info.IncludeSubdomains {
+			parts = append(parts, "includeSubDomains")
+		}
+		if info.PreloadDirective {
+			parts = append(parts, "preload")
+		}
+	} else {
+		parts = append(parts, "none")
+	}
+	if info.PreloadedTLD {
+		parts = append(parts, "preloaded-tld")
+	}
+	if info.Preloaded != nil {
+		if *info.Preloaded {
+			parts = append(parts, "listed")
+		} else {
+			parts = append(parts, "unlisted")
+		}
+	}
+	return strings.Join(parts, ",")
+}
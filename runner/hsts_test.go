@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHSTSInfoLowercaseHeaders(t *testing.T) {
+	// Envoy and HTTP/2 proxies send header names lowercase
+	resp := rawResponse(t, "HTTP/1.1 200 OK\r\n"+
+		"strict-transport-security: max-age=31536000; includeSubDomains; preload\r\n"+
+		"expect-ct: max-age=86400, enforce\r\n"+
+		"Content-Length: 0\r\n\r\n")
+
+	info := hstsInfo(resp, "www.example.com", &Config{})
+	if !info.Enabled || info.MaxAge != 31536000 || !info.IncludeSubdomains || !info.PreloadDirective {
+		t.Errorf("got %+v, want max-age=31536000 with includeSubDomains and preload", info)
+	}
+	if info.ExpectCT != "max-age=86400, enforce" {
+		t.Errorf("expect_ct = %q", info.ExpectCT)
+	}
+}
+
+func TestParseHSTS(t *testing.T) {
+	tests := []struct {
+		value   string
+		enabled bool
+		maxAge  int64
+	}{
+		{"max-age=600", true, 600},
+		{`max-age="600"; includeSubDomains`, true, 600},
+		{"MAX-AGE=600", true, 600},
+		{"max-age=0", false, 0},
+		{"includeSubDomains", false, 0},
+		{"", false, 0},
+	}
+	for _, tt := range tests {
+		var info HSTSInfo
+		parseHSTS(tt.value, &info)
+		if info.Enabled != tt.enabled || info.MaxAge != tt.maxAge {
+			t.Errorf("parseHSTS(%q) = enabled %v, max-age %d", tt.value, info.Enabled, info.MaxAge)
+		}
+	}
+}
+
+func TestHSTSPreloadStatus(t *testing.T) {
+	tlds, err := loadPreloadedTLDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := rawResponse(t, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+
+	// The shipped TLDs don't make a host "preloaded"
+	config := &Config{hstsTLDs: tlds}
+	info := hstsInfo(resp, "shop.example.app", config)
+	if !info.PreloadedTLD || info.Preloaded != nil {
+		t.Errorf("without a preload file: preloaded_tld %v, preloaded %v", info.PreloadedTLD, info.Preloaded)
+	}
+	if got := formatHSTS(info); got != "none,preloaded-tld" {
+		t.Errorf("formatHSTS = %q", got)
+	}
+	if info := hstsInfo(resp, "www.example.com", config); info.PreloadedTLD {
+		t.Error("www.example.com reported under a preloaded TLD")
+	}
+
+	path := filepath.Join(t.TempDir(), "preload.txt")
+	if err := os.WriteFile(path, []byte("example.com include_subdomains\nexample.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.hstsPreload, err = loadHSTSPreload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"www.example.com", "none,listed"},
+		{"example.org", "none,listed"},
+		{"www.example.org", "none,unlisted"},
+		{"example.net", "none,unlisted"},
+	}
+	for _, tt := range tests {
+		if got := formatHSTS(hstsInfo(resp, tt.host, config)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"github.com/valyala/fasthttp"
+)
+
+// browserHellos are the ClientHellos -tls-impersonate can send
+var browserHellos = map[string]utls.ClientHelloID{
+	"chrome":  utls.HelloChrome_Auto,
+	"firefox": utls.HelloFirefox_Auto,
+	"safari":  utls.HelloSafari_Auto,
+}
+
+// parseImpersonate checks a -tls-impersonate value
+func parseImpersonate(browser string) (utls.ClientHelloID, error) {
+	hello, ok := browserHellos[strings.ToLower(browser)]
+	if !ok {
+		names := make([]string, 0, len(browserHellos))
+		for name := range browserHellos {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return utls.ClientHelloID{}, fmt.Errorf("unknown browser %q, use one of %s", browser, strings.Join(names, ", "))
+	}
+	return hello, nil
+}
+
+// impersonateTLS makes the HTTPS host clients of client send a browser's
+// ClientHello, so JA3-based bot filters let probes through. fasthttp leaves
+// connections that are already TLS alone, so the handshake is done while
+// dialing.
+func impersonateTLS(client *fasthttp.Client, hello utls.ClientHelloID) {
+	dial := client.DialTimeout
+	client.ConfigureClient = func(hc *fasthttp.HostClient) error {
+		if !hc.IsTLS {
+			return nil
+		}
+
+		insecure := hc.TLSConfig != nil && hc.TLSConfig.InsecureSkipVerify
+		hc.DialTimeout = func(addr string, timeout time.Duration) (net.Conn, error) {
+			var conn net.Conn
+			var err error
+			switch {
+			case dial != nil:
+				conn, err = dial(addr, timeout)
+			case timeout > 0:
+				conn, err = fasthttp.DialTimeout(addr, timeout)
+			default:
+				conn, err = fasthttp.Dial(addr)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return browserHandshake(conn, addr, hello, insecure, timeout)
+		}
+		return nil
+	}
+}
+
+// browserHandshake performs the TLS handshake with the ClientHello of a
+// browser. Browsers offer HTTP/2 too, which fasthttp doesn't speak, so
+// ALPN is narrowed to HTTP/1.1.
+func browserHandshake(conn net.Conn, addr string, hello utls.ClientHelloID, insecure bool, timeout time.Duration) (net.Conn, error) {
+	spec, err := utls.UTLSIdToSpec(hello)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	for _, extension := range spec.Extensions {
+		if alpn, ok := extension.(*utls.ALPNExtension); ok {
+			alpn.AlpnProtocols = []string{"http/1.1"}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	uconn := utls.UClient(conn, &utls.Config{ServerName: host, InsecureSkipVerify: insecure}, utls.HelloCustom)
+	if err := uconn.ApplyPreset(&spec); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	if err := uconn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return uconn, nil
+}
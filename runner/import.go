@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// httpxResult is the part of an httpx -json line that has a livedom
+// equivalent. httpx hashes the whole body and livedom its first 8KB, so
+// hashes aren't carried over.
+type httpxResult struct {
+	URL           string   `json:"url"`
+	StatusCode    int      `json:"status_code"`
+	ContentType   string   `json:"content_type"`
+	ContentLength int64    `json:"content_length"`
+	Title         string   `json:"title"`
+	Webserver     string   `json:"webserver"`
+	Time          string   `json:"time"`
+	Host          string   `json:"host"`
+	A             []string `json:"a"`
+	CNAME         []string `json:"cname"`
+	Failed        bool     `json:"failed"`
+	TLS           *struct {
+		SubjectAN []string `json:"subject_an"`
+	} `json:"tls"`
+}
+
+// runImport implements "livedom import": it converts httpx JSON Lines into
+// livedom JSON Lines, so results of both tools can be processed together
+func runImport(args []string) {
+	var path string
+	var labels labelFlag
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&path, "httpx", "", "httpx -json output to convert (- for stdin)")
+	fs.Var(&labels, "label", "Attach a key=value label to every result, e.g. -label source=httpx (repeatable)")
+	fs.Parse(args)
+
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "Error: -httpx is required, e.g. livedom import -httpx results.json")
+		os.Exit(2)
+	}
+
+	var reader io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fatal("opening httpx results", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var imported, failed int
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record httpxResult
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			slog.Warn("skipping httpx line", "line", line, "error", err)
+			continue
+		}
+		// Failed probes have no livedom result, dead hosts aren't output
+		if record.Failed || record.URL == "" {
+			failed++
+			continue
+		}
+		result := convertHTTPX(record)
+		result.Labels = labels
+		displayJSON(result)
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		fatal("reading httpx results", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %d results, skipped %d failed probes\n", imported, failed)
+}
+
+// convertHTTPX maps an httpx result onto a livedom Result
+func convertHTTPX(record httpxResult) Result {
+	result := Result{
+		URL:           record.URL,
+		StatusCode:    record.StatusCode,
+		ContentType:   record.ContentType,
+		ContentLength: record.ContentLength,
+		Title:         record.Title,
+		Server:        record.Webserver,
+	}
+	if d, err := time.ParseDuration(record.Time); err == nil {
+		result.ResponseTime = formatResponseTime(d)
+	}
+	switch {
+	case len(record.A) > 0:
+		result.IP = record.A[0]
+	case net.ParseIP(record.Host) != nil:
+		result.IP = record.Host
+	}
+	if len(record.CNAME) > 0 {
+		result.CNAMEChain = record.CNAME
+		result.CNAME = record.CNAME[len(record.CNAME)-1]
+	}
+	if record.TLS != nil {
+		result.SANs = record.TLS.SubjectAN
+	}
+	return result
+}
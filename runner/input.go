@@ -0,0 +1,371 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http/httpguts"
+)
+
+// Target is one input to probe: a domain or URL, plus optional request
+// settings that apply to this target only
+type Target struct {
+	Input       string
+	Method      string
+	Headers     []Header
+	Body        string
+	Passthrough []string // extra input columns, see -passthrough-cols
+}
+
+// Header is a request header set on a per-target basis
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// hopHeaders are the hop-by-hop and message framing headers that
+// -strip-hop-headers drops from user-supplied headers. Sent by hand they
+// can make a proxy and the server disagree on where a request ends.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"TE",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Content-Length",
+}
+
+func isHopHeader(name string) bool {
+	for _, hop := range hopHeaders {
+		if strings.EqualFold(name, hop) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHeader rejects headers that would produce a malformed request:
+// names that aren't HTTP tokens and values with CR, LF or other control
+// characters, which could inject extra headers or a second request
+func validateHeader(header Header) error {
+	if !httpguts.ValidHeaderFieldName(header.Name) {
+		return fmt.Errorf("invalid header name %q", header.Name)
+	}
+	if !httpguts.ValidHeaderFieldValue(header.Value) {
+		return fmt.Errorf("invalid value for header %s: %q", header.Name, header.Value)
+	}
+	return nil
+}
+
+// jsonTarget is the JSON Lines input format
+type jsonTarget struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// parseTargetLine parses an input line. Besides a bare domain or URL, a line
+// may carry per-target headers after whitespace:
+//
+//	https://api.example.com Host=internal.example.com;Authorization=Bearer abc
+//
+// or be a JSON object with url, method, headers and body:
+//
+//	{"url":"https://api.example.com/login","method":"POST","body":"{}"}
+func parseTargetLine(line string) (Target, error) {
+	if strings.HasPrefix(line, "{") {
+		return parseJSONTarget(line)
+	}
+
+	input, annotations, found := strings.Cut(line, " ")
+	if !found {
+		input, annotations, _ = strings.Cut(line, "\t")
+	}
+
+	target := Target{Input: input}
+	for _, annotation := range strings.Split(annotations, ";") {
+		name, value, ok := strings.Cut(annotation, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		header := Header{Name: name, Value: strings.TrimSpace(value)}
+		if err := validateHeader(header); err != nil {
+			return Target{}, err
+		}
+		target.Headers = append(target.Headers, header)
+	}
+	return target, nil
+}
+
+func parseJSONTarget(line string) (Target, error) {
+	var jt jsonTarget
+	if err := json.Unmarshal([]byte(line), &jt); err != nil {
+		return Target{}, err
+	}
+	if jt.URL == "" {
+		return Target{}, fmt.Errorf("missing url")
+	}
+
+	target := Target{
+		Input:  jt.URL,
+		Method: strings.ToUpper(jt.Method),
+		Body:   jt.Body,
+	}
+
+	// Sort so requests are built the same way on every run
+	names := make([]string, 0, len(jt.Headers))
+	for name := range jt.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header := Header{Name: name, Value: jt.Headers[name]}
+		if err := validateHeader(header); err != nil {
+			return Target{}, err
+		}
+		target.Headers = append(target.Headers, header)
+	}
+
+	return target, nil
+}
+
+// readTargets calls submit for every target in the configured input: Burp
+// or ZAP XML exports or certificate transparency lookups when given,
+// otherwise one target per line from -f or stdin. Lines are handed over
+// as they are read so probing starts at once.
+func readTargets(config *Config, submit func(target Target)) error {
+	switch {
+	case config.BurpXML != "":
+		return readXMLTargets(config.BurpXML, burpTargets, submit)
+	case config.ZAPXML != "":
+		return readXMLTargets(config.ZAPXML, zapTargets, submit)
+	case config.CTDomain != "":
+		return readCTTargets(config, submit)
+	}
+
+	var reader io.Reader
+	switch {
+	case config.targets != nil:
+		reader = strings.NewReader(strings.Join(config.targets, "\n"))
+	case config.InputFile != "":
+		file, err := os.Open(config.InputFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	default:
+		reader = inputHashReader(os.Stdin, config.inputHash)
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		// Everything after the first tab is carried through as is
+		var passthrough []string
+		if config.PassthroughCols {
+			fields := strings.Split(strings.TrimRight(scanner.Text(), "\r"), "\t")
+			line, passthrough = strings.TrimSpace(fields[0]), fields[1:]
+			if line == "" {
+				continue
+			}
+		}
+
+		target, err := parseTargetLine(line)
+		kind := inputInvalid
+		if err == nil {
+			kind, err = classifyInput(target.Input)
+		}
+		config.inputCounts.Add(kind, err)
+		if err != nil {
+			slog.Warn("skipping invalid input line", "line", line, "error", err)
+			continue
+		}
+		target.Passthrough = passthrough
+
+		switch kind {
+		case inputCIDR:
+			for _, address := range expandCIDR(target.Input) {
+				expanded := target
+				expanded.Input = address
+				submit(expanded)
+			}
+		case inputIPv6:
+			// Bare IPv6 addresses need brackets to take a scheme
+			if _, err := netip.ParseAddr(target.Input); err == nil {
+				target.Input = "[" + target.Input + "]"
+			}
+			submit(target)
+		default:
+			submit(target)
+		}
+	}
+	return scanner.Err()
+}
+
+// passthroughColumns returns the -passthrough-cols values as columns named
+// after their position in the input line
+func passthroughColumns(values []string) []column {
+	columns := make([]column, 0, len(values))
+	for i, value := range values {
+		columns = append(columns, newColumn(fmt.Sprintf("col%d", i+2), value, color.FgWhite))
+	}
+	return columns
+}
+
+// readXMLTargets streams an XML export through extract, submitting each
+// distinct URL once. Exports contain one item per request, so the same
+// URL usually appears many times.
+func readXMLTargets(path string, extract func(*xml.Decoder, xml.StartElement) string, submit func(target Target)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	seen := make(map[string]bool)
+	decoder := xml.NewDecoder(bufio.NewReader(file))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		target := strings.TrimSpace(extract(decoder, start))
+		if i := strings.Index(target, "#"); i != -1 {
+			target = target[:i]
+		}
+		if target != "" && !seen[target] {
+			seen[target] = true
+			submit(Target{Input: target})
+		}
+	}
+}
+
+// burpTargets extracts the URL of each <item> in a Burp "Save items" or
+// site map XML export
+func burpTargets(decoder *xml.Decoder, start xml.StartElement) string {
+	if start.Name.Local != "item" {
+		return ""
+	}
+
+	var item struct {
+		URL string `xml:"url"`
+	}
+	if err := decoder.DecodeElement(&item, &start); err != nil {
+		return ""
+	}
+	return item.URL
+}
+
+// zapTargets extracts site URLs and alert instance URIs from a ZAP XML report
+func zapTargets(decoder *xml.Decoder, start xml.StartElement) string {
+	switch start.Name.Local {
+	case "site":
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "name" {
+				return attr.Value
+			}
+		}
+	case "uri":
+		var uri string
+		if err := decoder.DecodeElement(&uri, &start); err == nil {
+			return uri
+		}
+	}
+	return ""
+}
+
+// applyTargetSettings applies the global -method, -H and -body flags and
+// then the target's own settings on top, so per-target values win. A Host
+// header replaces the Host sent to the server without changing where we
+// connect. Session headers of -login-script come between the two, and
+// -strip-hop-headers drops hop-by-hop headers from all of them.
+func applyTargetSettings(req *fasthttp.Request, target Target, config *Config) {
+	method, body := config.Method, config.Body
+	if target.Method != "" {
+		method = target.Method
+	}
+	if target.Body != "" {
+		body = target.Body
+	}
+
+	req.Header.SetMethod(method)
+	if body != "" {
+		req.SetBodyString(body)
+	}
+	applyTargetHeaders(req, target, config)
+}
+
+// applyTargetHeaders applies the headers of applyTargetSettings without
+// its method and body
+func applyTargetHeaders(req *fasthttp.Request, target Target, config *Config) {
+	// config.headers is shared by all workers, build a new slice instead of
+	// appending to it
+	var session []Header
+	if config.login != nil {
+		session = config.login.Headers(extractDomain(string(req.URI().Host())))
+	}
+	for _, header := range slices.Concat(config.headers, session, target.Headers) {
+		if config.StripHopHeaders && isHopHeader(header.Name) {
+			continue
+		}
+		if strings.EqualFold(header.Name, "Host") {
+			req.UseHostHeader = true
+			req.Header.SetHost(header.Value)
+			continue
+		}
+		req.Header.Set(header.Name, header.Value)
+	}
+}
+
+// headerFlag collects repeated -H "Name: value" flags
+type headerFlag []Header
+
+func (h *headerFlag) String() string {
+	var parts []string
+	for _, header := range *h {
+		parts = append(parts, header.Name+": "+header.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *headerFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header must be in \"Name: value\" form")
+	}
+	header := Header{Name: strings.TrimSpace(name), Value: strings.TrimSpace(val)}
+	if err := validateHeader(header); err != nil {
+		return err
+	}
+	*h = append(*h, header)
+	return nil
+}
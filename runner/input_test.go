@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestApplyTargetSettingsKeepsGlobalHeaders(t *testing.T) {
+	// Spare capacity is where an append would write the target's headers
+	global := make([]Header, 1, 8)
+	global[0] = Header{Name: "X-Global", Value: "1"}
+	config := &Config{Method: "GET", headers: global}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.SetRequestURI("https://example.com/")
+	applyTargetSettings(req, Target{Headers: []Header{{Name: "X-Target", Value: "a"}}}, config)
+
+	if spare := global[:cap(global)][1]; spare != (Header{}) {
+		t.Errorf("target header %+v was written into the global headers", spare)
+	}
+	if got := string(req.Header.Peek("X-Target")); got != "a" {
+		t.Errorf("X-Target = %q, want a", got)
+	}
+	if got := string(req.Header.Peek("X-Global")); got != "1" {
+		t.Errorf("X-Global = %q, want 1", got)
+	}
+}
+
+func TestApplyTargetSettingsConcurrent(t *testing.T) {
+	global := make([]Header, 1, 8)
+	global[0] = Header{Name: "X-Global", Value: "1"}
+	config := &Config{Method: "GET", headers: global}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := fmt.Sprint(i)
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.SetRequestURI("https://example.com/")
+			applyTargetSettings(req, Target{Headers: []Header{{Name: "X-Target", Value: value}}}, config)
+			if got := string(req.Header.Peek("X-Target")); got != value {
+				t.Errorf("target %d sent X-Target %q", i, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
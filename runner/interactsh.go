@@ -0,0 +1,200 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// interactshCorrelationIDLength is the length of the ID interactsh servers
+// use to route callbacks to a client
+const interactshCorrelationIDLength = 20
+
+// interactshClient is a minimal client for the interactsh OOB interaction
+// server protocol: register a public key, poll for interactions encrypted
+// with it, deregister
+type interactshClient struct {
+	server        *url.URL
+	domain        string
+	token         string
+	correlationID string
+	secret        string
+	key           *rsa.PrivateKey
+	http          *http.Client
+}
+
+// interaction is a decrypted callback as reported by interactsh
+type interaction struct {
+	Protocol      string    `json:"protocol"`
+	UniqueID      string    `json:"unique-id"`
+	FullID        string    `json:"full-id"`
+	RemoteAddress string    `json:"remote-address"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func newInteractshClient(server, token string) (*interactshClient, error) {
+	serverURL, err := serverURL(server)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &interactshClient{
+		server:        serverURL,
+		domain:        serverURL.Hostname(),
+		token:         token,
+		correlationID: randomToken(interactshCorrelationIDLength),
+		secret:        randomToken(32),
+		key:           key,
+		http:          &http.Client{Timeout: 10 * time.Second},
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+
+	err = c.post("/register", map[string]string{
+		"public-key":     base64.StdEncoding.EncodeToString(pemKey),
+		"secret-key":     c.secret,
+		"correlation-id": c.correlationID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("registering with %s: %v", c.domain, err)
+	}
+	return c, nil
+}
+
+// serverURL adds https:// to a bare interactsh server name
+func serverURL(server string) (*url.URL, error) {
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	return url.Parse(server)
+}
+
+// Poll returns the interactions since the last poll
+func (c *interactshClient) Poll() ([]interaction, error) {
+	query := url.Values{"id": {c.correlationID}, "secret": {c.secret}}
+	req, err := http.NewRequest("GET", c.server.JoinPath("/poll").String()+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data   []string `json:"data"`
+		AESKey string   `json:"aes_key"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Data) == 0 {
+		return nil, nil
+	}
+
+	encryptedKey, err := base64.StdEncoding.DecodeString(response.AESKey)
+	if err != nil {
+		return nil, err
+	}
+	aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, c.key, encryptedKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var interactions []interaction
+	for _, data := range response.Data {
+		plaintext, err := decryptInteraction(aesKey, data)
+		if err != nil {
+			continue
+		}
+		var i interaction
+		if err := json.Unmarshal(plaintext, &i); err == nil {
+			interactions = append(interactions, i)
+		}
+	}
+	return interactions, nil
+}
+
+// decryptInteraction decrypts an AES-CFB message with the IV prepended
+func decryptInteraction(key []byte, data string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aes.BlockSize {
+		return nil, errors.New("interaction too short")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext)-aes.BlockSize)
+	cipher.NewCFBDecrypter(block, ciphertext[:aes.BlockSize]).XORKeyStream(plaintext, ciphertext[aes.BlockSize:])
+	return plaintext, nil
+}
+
+// Close deregisters the client so the server drops its interactions
+func (c *interactshClient) Close() error {
+	return c.post("/deregister", map[string]string{
+		"correlation-id": c.correlationID,
+		"secret-key":     c.secret,
+	})
+}
+
+func (c *interactshClient) post(path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.server.JoinPath(path).String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, err = c.do(req)
+	return err
+}
+
+func (c *interactshClient) do(req *http.Request) ([]byte, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
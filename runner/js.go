@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/html"
+)
+
+// maxJSFetchSize caps how much of a JavaScript file is mined for endpoints
+const maxJSFetchSize = 2 * 1024 * 1024
+
+// endpointPattern matches quoted strings in JavaScript that look like URLs,
+// absolute paths, or relative paths to server-side resources
+var endpointPattern = regexp.MustCompile(`["'` + "`" + `]((?:https?:)?//[^"'` + "`" + `\s<>]+|/[a-zA-Z0-9_\-./]+[a-zA-Z0-9_\-/](?:\?[^"'` + "`" + `\s<>]*)?|[a-zA-Z0-9_\-]+/[a-zA-Z0-9_\-/.]+\.(?:php|aspx?|jsp|json|action|html?|js|txt|xml)(?:\?[^"'` + "`" + `\s<>]*)?)["'` + "`" + `]`)
+
+// extractScriptURLs returns the absolute URLs of all <script src> tags in an
+// HTML body, resolved against the page URL
+func extractScriptURLs(pageURL string, body []byte) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var scripts []string
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := tokenizer.TagName()
+		if string(name) != "script" || !hasAttr {
+			continue
+		}
+
+		for {
+			key, value, more := tokenizer.TagAttr()
+			if string(key) == "src" {
+				src, err := url.Parse(strings.TrimSpace(string(value)))
+				if err == nil {
+					script := base.ResolveReference(src).String()
+					if !seen[script] {
+						seen[script] = true
+						scripts = append(scripts, script)
+					}
+				}
+			}
+			if !more {
+				break
+			}
+		}
+	}
+
+	return scripts
+}
+
+// mineJSEndpoints fetches each in-scope script and writes the endpoints and
+// paths found in it to the -js-endpoints file
+func mineJSEndpoints(client *fasthttp.Client, target Target, scripts []string, domain string, config *Config) {
+	for _, script := range scripts {
+		host := extractDomain(script)
+		if host != domain && !inScope(host, domain, config.scope) {
+			continue
+		}
+
+		req := newFollowUpRequest(script, target, config)
+		resp := fasthttp.AcquireResponse()
+
+		// Bundles can be huge, read no more than is mined
+		resp.StreamBody = true
+		err := doFollowUp(client, req, resp, config)
+		limitResponseBody(resp, maxJSFetchSize)
+		if err == nil && resp.StatusCode() == fasthttp.StatusOK {
+			for _, endpoint := range extractEndpoints(resp.Body()) {
+				config.jsEndpoints.WriteLine(endpoint)
+			}
+		}
+
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+}
+
+// extractEndpoints returns the unique endpoint-like strings in a JS file
+func extractEndpoints(body []byte) []string {
+	seen := make(map[string]bool)
+	var endpoints []string
+	for _, match := range endpointPattern.FindAllSubmatch(body, -1) {
+		endpoint := string(match[1])
+		// Skip protocol-relative noise like "//" comments and bare slashes
+		if endpoint == "//" || strings.Trim(endpoint, "/.") == "" {
+			continue
+		}
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
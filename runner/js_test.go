@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestMineJSEndpointsLimitsBody(t *testing.T) {
+	// An endpoint at the start of a 64MB bundle and one past
+	// maxJSFetchSize. The server counts how much of it was read.
+	const size = 64 << 20
+	sent := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		written, _ := fmt.Fprint(w, `fetch("/api/early");`)
+		chunk := []byte(strings.Repeat(" ", 64<<10))
+		for written < size {
+			n, err := w.Write(chunk)
+			written += n
+			if err != nil {
+				break
+			}
+		}
+		fmt.Fprint(w, `fetch("/api/late");`)
+		sent <- written
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "endpoints.txt")
+	writer, err := newUniqueLineWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Timeout: 5 * time.Second, jsEndpoints: writer}
+	domain := extractDomain(server.URL)
+	mineJSEndpoints(&fasthttp.Client{}, Target{Input: server.URL}, []string{server.URL + "/app.js"}, domain, config)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "/api/early" {
+		t.Errorf("mined %q, want only /api/early", got)
+	}
+	if written := <-sent; written >= size {
+		t.Errorf("the whole %dMB bundle was read", written>>20)
+	}
+}
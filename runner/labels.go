@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// labelFlag collects repeated -label key=value flags. The labels are
+// attached to every result so datasets merged from many scans stay
+// filterable.
+type labelFlag map[string]string
+
+func (l *labelFlag) String() string {
+	keys := make([]string, 0, len(*l))
+	for key := range *l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		parts = append(parts, key+"="+(*l)[key])
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *labelFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("label must be in key=value form")
+	}
+	if *l == nil {
+		*l = make(labelFlag)
+	}
+	(*l)[key] = strings.TrimSpace(val)
+	return nil
+}
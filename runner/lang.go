@@ -0,0 +1,192 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// maxLangBodySize is how much of the body is read for language detection;
+// the first 8KB used for titles is often all <head> and scripts
+const maxLangBodySize = 32 * 1024
+
+// minLangTextLen is the minimum amount of visible text needed for trigram
+// detection, below that the <html lang> attribute is used instead
+const minLangTextLen = 40
+
+// langTrigrams holds the most frequent character trigrams of common
+// Latin-script languages (spaces mark word boundaries)
+var langTrigrams = map[string][]string{
+	"en": {" th", "the", "he ", "and", " an", "nd ", "ing", " of", "of ", "ion", "ed ", " to", "to ", "ent", " in", "er ", "tio", "is ", "re ", "on ", "at ", "es ", " co", "for", " fo", "or ", "ng ", "you", " yo", "our"},
+	"de": {"en ", "er ", " de", "der", "ie ", "ich", "ein", "sch", " di", "die", "che", "den", "ch ", "in ", "nd ", "und", " un", "cht", " ei", "gen", "ung", "ten", "es ", " ge", "ine", "te ", " zu", "ver", "ier", "sie"},
+	"fr": {"es ", " de", "de ", "le ", " le", "ent", "ion", "les", "on ", " la", "la ", "tio", "re ", " et", "et ", "nt ", "des", " pa", " co", "que", "ue ", "ne ", "men", " qu", "our", "ait", "est", " po", "ous", "vou"},
+	"es": {" de", "de ", "os ", "la ", " la", "el ", "es ", " el", "en ", "ión", "ent", "que", " qu", "ue ", "as ", "ado", " co", "aci", "ón ", "los", " en", "del", "nte", "ra ", "con", "par", " pa", "ara", "est", " lo"},
+	"it": {" di", "di ", "la ", "re ", "to ", "che", " de", "ell", "one", "ent", "zio", " la", "lla", "ion", "del", "per", " pe", "ato", "no ", "are", "ne ", "le ", " co", "ta ", "gli", " il", "il ", "nel", "ti ", "lle"},
+	"pt": {" de", "de ", "os ", "ão ", "ção", "do ", "as ", " qu", "que", "da ", "ent", " co", " a ", "ara", "com", "est", "ado", " da", " do", "ue ", "men", "ra ", "nte", "es ", "uma", " um", "par", " pa", "ões", "não"},
+	"nl": {"en ", "de ", " de", "van", " va", "an ", "het", " he", "et ", "een", " ee", "er ", "ing", "ijk", " en", "nd ", "te ", " in", "aar", "oor", "ver", "den", "ie ", "voo", " vo", "lij", "eer", " ge", " zi", "ij "},
+}
+
+// scriptLanguages maps Unicode scripts that are (mostly) used by a single
+// language straight to that language
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Thai, "th"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Cyrillic, "ru"},
+}
+
+// detectLanguage guesses the language of an HTML page from its visible text,
+// falling back to the <html lang> attribute when there is too little text
+func detectLanguage(body []byte) string {
+	if len(body) > maxLangBodySize {
+		body = body[:maxLangBodySize]
+	}
+
+	text, declared := visibleText(body)
+	if len([]rune(text)) < minLangTextLen {
+		return declared
+	}
+
+	if lang := detectScript(text); lang != "" {
+		return lang
+	}
+	if lang := detectTrigrams(text); lang != "" {
+		return lang
+	}
+	return declared
+}
+
+// visibleText returns the text content outside <script>/<style> and the
+// primary subtag of <html lang>, if any
+func visibleText(body []byte) (string, string) {
+	var text strings.Builder
+	var declared string
+	skip := 0
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return text.String(), declared
+		case html.StartTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style", "noscript":
+				skip++
+			case "html":
+				for hasAttr {
+					var key, value []byte
+					key, value, hasAttr = tokenizer.TagAttr()
+					if string(key) == "lang" {
+						declared = strings.ToLower(strings.SplitN(string(value), "-", 2)[0])
+					}
+				}
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script", "style", "noscript":
+				if skip > 0 {
+					skip--
+				}
+			}
+		case html.TextToken:
+			if skip == 0 {
+				text.Write(tokenizer.Text())
+				text.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// detectScript returns the language of the dominant non-Latin script, if
+// letters of that script make up a large share of the text
+func detectScript(text string) string {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				counts[script.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Japanese text is full of Han characters, any kana means Japanese
+	if counts["ja"] > 0 && counts["ja"]+counts["zh"] > letters/3 {
+		return "ja"
+	}
+
+	best, bestCount := "", 0
+	for lang, count := range counts {
+		if count > bestCount {
+			best, bestCount = lang, count
+		}
+	}
+	if bestCount > letters/3 {
+		return best
+	}
+	return ""
+}
+
+// detectTrigrams scores the text against each language's common trigrams
+// and returns the best match, or "" if nothing matches convincingly
+func detectTrigrams(text string) string {
+	// Normalize to lowercase letters separated by single spaces
+	var normalized strings.Builder
+	normalized.WriteByte(' ')
+	lastSpace := true
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) {
+			normalized.WriteRune(r)
+			lastSpace = false
+		} else if !lastSpace {
+			normalized.WriteByte(' ')
+			lastSpace = true
+		}
+	}
+
+	runes := []rune(normalized.String())
+	trigrams := make(map[string]int)
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams[string(runes[i:i+3])]++
+	}
+
+	best, bestScore, runnerUp := "", 0, 0
+	for lang, common := range langTrigrams {
+		score := 0
+		for _, trigram := range common {
+			score += trigrams[trigram]
+		}
+		if score > bestScore {
+			best, bestScore, runnerUp = lang, score, bestScore
+		} else if score > runnerUp {
+			runnerUp = score
+		}
+	}
+
+	// Require a clear winner, mixed or non-language text scores evenly
+	if bestScore < 5 || bestScore*10 < runnerUp*11 {
+		return ""
+	}
+	return best
+}
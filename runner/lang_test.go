@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	page := func(lang, text string) string {
+		return `<html lang="` + lang + `"><head><script>var theAndOfThe = "the and of the";</script></head><body><p>` + text + `</p></body></html>`
+	}
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"english", page("", "The quick brown fox jumps over the lazy dog and then runs into the forest for the night."), "en"},
+		{"german", page("", "Der schnelle braune Fuchs springt über den faulen Hund und läuft dann in den Wald, weil er sich dort sicher fühlt."), "de"},
+		{"french", page("", "Le renard brun rapide saute par-dessus le chien paresseux et puis il court dans la forêt pour la nuit."), "fr"},
+		{"spanish", page("", "El rápido zorro marrón salta sobre el perro perezoso y luego corre hacia el bosque de la ciudad para pasar la noche."), "es"},
+		// The text wins over the declared language
+		{"declared wrong", page("de", "The quick brown fox jumps over the lazy dog and then runs into the forest for the night."), "en"},
+		{"japanese", page("", "日本語のテキストです。これはテストのためのページで、ひらがなとカタカナと漢字が含まれています。"), "ja"},
+		{"chinese", page("", "这是一个用于测试的中文页面，其中包含足够多的汉字来判断语言，我们希望它被识别为中文。"), "zh"},
+		{"russian", page("", "Быстрая коричневая лиса прыгает через ленивую собаку и потом убегает в лес на всю ночь."), "ru"},
+		// Too little text to tell, the declaration is all there is
+		{"short", page("pt-BR", "Olá"), "pt"},
+		{"short undeclared", page("", "Hi"), ""},
+		// Scripts don't count
+		{"script only", `<html><script>` + strings.Repeat("the and of the ", 20) + `</script></html>`, ""},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage([]byte(tt.body)); got != tt.want {
+			t.Errorf("%s: detectLanguage = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVisibleText(t *testing.T) {
+	tests := []struct {
+		body, text, declared string
+	}{
+		{`<html lang="EN-us"><body>hi</body></html>`, "hi ", "en"},
+		{`<p>a</p><style>p{}</style><noscript>b</noscript><p>c</p>`, "a c ", ""},
+		{`<title>t</title><script>x</script>y`, "t y ", ""},
+	}
+	for _, tt := range tests {
+		text, declared := visibleText([]byte(tt.body))
+		if text != tt.text || declared != tt.declared {
+			t.Errorf("visibleText(%q) = %q, %q, want %q, %q", tt.body, text, declared, tt.text, tt.declared)
+		}
+	}
+}
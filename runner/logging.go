@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// setupLogging sends diagnostics to stderr, or -log-file, at -log-level,
+// keeping them apart from the results on stdout
+func setupLogging(config *Config) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return fmt.Errorf("invalid -log-level %q, use debug, info, warn or error", config.LogLevel)
+	}
+	switch {
+	case config.Debug:
+		level = slog.LevelDebug
+	case config.Silent:
+		level = slog.LevelError
+	}
+
+	var out io.Writer = os.Stderr
+	if config.LogFile != "" {
+		file, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if config.LogJSON {
+		handler = slog.NewJSONHandler(out, options)
+	} else {
+		handler = slog.NewTextHandler(out, options)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// fatal logs a setup error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// setupError is a step of setting up a scan that failed
+type setupError struct {
+	step string
+	err  error
+}
+
+func (e *setupError) Error() string {
+	return e.step + ": " + e.err.Error()
+}
+
+func (e *setupError) Unwrap() error {
+	return e.err
+}
+
+// exitOnError reports an error of setting up or running a scan and exits,
+// with the usage error status of the flag package for bad flags
+func exitOnError(err error) {
+	var problems *configProblems
+	var setup *setupError
+	switch {
+	case errors.As(err, &problems):
+		problems.Print()
+		os.Exit(2)
+	case errors.As(err, &setup):
+		fatal(setup.step, setup.err)
+	default:
+		fatal("scanning", err)
+	}
+}
@@ -0,0 +1,201 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"gopkg.in/yaml.v3"
+)
+
+// loginScript is a -login-script file: sessions to log in to before the
+// scan, each applied to the requests of its host group
+type loginScript struct {
+	Sessions []*loginSession `yaml:"sessions"`
+}
+
+// loginSession logs in once and adds the session cookies and token to
+// every request to a matching host. Values may reference environment
+// variables as ${NAME}, so credentials don't have to be in the file.
+type loginSession struct {
+	Name  string   `yaml:"name"`
+	Hosts []string `yaml:"hosts"` // example.com, *.example.com or *
+	Login struct {
+		URL     string            `yaml:"url"`
+		Method  string            `yaml:"method"`
+		Headers map[string]string `yaml:"headers"`
+		Body    string            `yaml:"body"`
+	} `yaml:"login"`
+	Extract struct {
+		Cookies bool `yaml:"cookies"`
+		Token   *struct {
+			JSON   string `yaml:"json"`   // dotted path in a JSON response, e.g. data.access_token
+			Regex  string `yaml:"regex"`  // first group is the token
+			Header string `yaml:"header"` // default Authorization
+			Format string `yaml:"format"` // default "Bearer {token}"
+		} `yaml:"token"`
+	} `yaml:"extract"`
+
+	headers []Header // what the login produced
+}
+
+func loadLoginScript(path string) (*loginScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var script loginScript
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&script); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, session := range script.Sessions {
+		if session.Name == "" {
+			session.Name = fmt.Sprintf("session %d", i+1)
+		}
+		if session.Login.URL == "" || len(session.Hosts) == 0 {
+			return nil, fmt.Errorf("%s: %s needs login.url and hosts", path, session.Name)
+		}
+		if !session.Extract.Cookies && session.Extract.Token == nil {
+			return nil, fmt.Errorf("%s: %s extracts nothing, set extract.cookies or extract.token", path, session.Name)
+		}
+		if token := session.Extract.Token; token != nil && token.JSON == "" && token.Regex == "" {
+			return nil, fmt.Errorf("%s: %s needs extract.token.json or extract.token.regex", path, session.Name)
+		}
+	}
+	return &script, nil
+}
+
+// Login runs the login request of every session
+func (s *loginScript) Login(client *fasthttp.Client, config *Config) error {
+	for _, session := range s.Sessions {
+		if err := session.login(client, config); err != nil {
+			return fmt.Errorf("%s: %v", session.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *loginSession) login(client *fasthttp.Client, config *Config) error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	method := strings.ToUpper(s.Login.Method)
+	if method == "" {
+		method = "POST"
+	}
+	req.SetRequestURI(os.ExpandEnv(s.Login.URL))
+	req.Header.SetMethod(method)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	for name, value := range s.Login.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+	if s.Login.Body != "" {
+		req.SetBodyString(os.ExpandEnv(s.Login.Body))
+	}
+
+	err := client.DoTimeout(req, resp, config.Timeout)
+	config.stats.recordRequest(err)
+	if err != nil {
+		return err
+	}
+	if status := resp.StatusCode(); status >= 400 {
+		return fmt.Errorf("login returned %d", status)
+	}
+
+	if s.Extract.Cookies {
+		// Cookies yields each name with its full Set-Cookie value
+		var cookies []string
+		for _, value := range resp.Header.Cookies() {
+			var cookie fasthttp.Cookie
+			if cookie.ParseBytes(value) == nil && len(cookie.Value()) > 0 {
+				cookies = append(cookies, string(cookie.Key())+"="+string(cookie.Value()))
+			}
+		}
+		if len(cookies) == 0 {
+			return fmt.Errorf("login set no cookies")
+		}
+		s.headers = append(s.headers, Header{Name: "Cookie", Value: strings.Join(cookies, "; ")})
+	}
+
+	if extract := s.Extract.Token; extract != nil {
+		token, err := extractToken(resp.Body(), extract.JSON, extract.Regex)
+		if err != nil {
+			return err
+		}
+		header, format := extract.Header, extract.Format
+		if header == "" {
+			header = "Authorization"
+		}
+		if format == "" {
+			format = "Bearer {token}"
+		}
+		s.headers = append(s.headers, Header{Name: header, Value: strings.ReplaceAll(format, "{token}", token)})
+	}
+	return nil
+}
+
+// extractToken finds the token in a login response, by dotted JSON path
+// or by the first group of a regex
+func extractToken(body []byte, jsonPath, pattern string) (string, error) {
+	if jsonPath != "" {
+		var value any
+		if err := json.Unmarshal(body, &value); err != nil {
+			return "", fmt.Errorf("login response isn't JSON: %v", err)
+		}
+		for _, key := range strings.Split(jsonPath, ".") {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("no %s in login response", jsonPath)
+			}
+			value = object[key]
+		}
+		token, ok := value.(string)
+		if !ok || token == "" {
+			return "", fmt.Errorf("no %s in login response", jsonPath)
+		}
+		return token, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	match := re.FindSubmatch(body)
+	if len(match) < 2 {
+		return "", fmt.Errorf("token regex didn't match the login response")
+	}
+	return string(match[1]), nil
+}
+
+// Headers returns the session headers for host
+func (s *loginScript) Headers(host string) []Header {
+	var headers []Header
+	for _, session := range s.Sessions {
+		if session.matches(host) {
+			headers = append(headers, session.headers...)
+		}
+	}
+	return headers
+}
+
+func (s *loginSession) matches(host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range s.Hosts {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case pattern == "*", pattern == host:
+			return true
+		case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]):
+			return true
+		}
+	}
+	return false
+}
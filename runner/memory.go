@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// memoryHighWater is the share of -max-memory above which intake pauses
+	memoryHighWater = 0.9
+	// memoryCheckInterval is how often a paused intake rechecks the heap
+	memoryCheckInterval = 100 * time.Millisecond
+	// maxPooledBodySize is the largest response body whose buffer is
+	// returned to fasthttp's pool while memory is under pressure
+	maxPooledBodySize = 64 * 1024
+	// minBodyBudget is the smallest per-probe body size -max-memory allows
+	minBodyBudget = 64 * 1024
+)
+
+// memoryGuard keeps the heap under -max-memory by pausing input while
+// it is near the limit, so big scans slow down instead of getting OOM-killed
+type memoryGuard struct {
+	limit int64
+}
+
+func newMemoryGuard(limit int64) *memoryGuard {
+	// Let the GC work harder as the heap approaches the limit too
+	debug.SetMemoryLimit(limit)
+	return &memoryGuard{limit: limit}
+}
+
+// heapInUse returns the bytes occupied by live and not yet swept objects
+func (g *memoryGuard) heapInUse() int64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return int64(sample[0].Value.Uint64())
+}
+
+// Pressure reports whether the heap is near the limit. It is false when
+// -max-memory is off.
+func (g *memoryGuard) Pressure() bool {
+	if g == nil {
+		return false
+	}
+	return float64(g.heapInUse()) > float64(g.limit)*memoryHighWater
+}
+
+// Wait blocks while the heap is near the limit, collecting garbage so
+// finished probes give their memory back. It doesn't wait when no probes
+// are running, as nothing would free memory then.
+func (g *memoryGuard) Wait(running func() int) {
+	if g == nil {
+		return
+	}
+	for g.Pressure() && running() > 0 {
+		runtime.GC()
+		if !g.Pressure() {
+			return
+		}
+		time.Sleep(memoryCheckInterval)
+	}
+}
+
+// BodyBudget is how much of each response body a probe may read: a quarter
+// of the limit split across workers, so concurrent large responses can't
+// blow through it
+func (g *memoryGuard) BodyBudget(threads int) int64 {
+	budget := g.limit / int64(4*max(threads, 1))
+	return max(budget, minBodyBudget)
+}
+
+// limitResponseBody reads at most limit bytes of a streamed response body
+// and drops the rest. A connection with unread body left can't carry
+// another request, so it is closed.
+func limitResponseBody(resp *fasthttp.Response, limit int64) {
+	stream := resp.BodyStream()
+	if stream == nil {
+		return
+	}
+	body, _ := io.ReadAll(io.LimitReader(stream, limit+1))
+	if int64(len(body)) > limit {
+		body = body[:limit]
+		resp.SetConnectionClose()
+	}
+	resp.CloseBodyStream()
+	resp.SetBodyRaw(body)
+}
+
+// releaseResponse returns resp to fasthttp's pool, except that large
+// bodies are left to the GC under memory pressure so the pool doesn't keep
+// big buffers alive
+func releaseResponse(resp *fasthttp.Response, config *Config) {
+	if len(resp.Body()) > maxPooledBodySize && config.memory.Pressure() {
+		return
+	}
+	fasthttp.ReleaseResponse(resp)
+}
+
+// parseByteSize parses sizes like "512MB", "1GB" or "1073741824"
+func parseByteSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return int64(value * float64(multiplier)), nil
+}
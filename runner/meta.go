@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PageMeta holds identifying metadata from a page's <head>
+type PageMeta struct {
+	Canonical string `json:"canonical,omitempty"`
+	Generator string `json:"generator,omitempty"`
+	SiteName  string `json:"site_name,omitempty"`
+}
+
+// extractPageMeta reads <link rel=canonical>, <meta name=generator> and
+// <meta property=og:site_name> from an HTML body. Parsing stops at </head>.
+func extractPageMeta(pageURL string, body []byte) PageMeta {
+	var meta PageMeta
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return meta
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "head" {
+				return meta
+			}
+			continue
+		case html.StartTagToken, html.SelfClosingTagToken:
+		default:
+			continue
+		}
+
+		name, hasAttr := tokenizer.TagName()
+		if !hasAttr || (string(name) != "link" && string(name) != "meta") {
+			continue
+		}
+
+		attrs := make(map[string]string)
+		for {
+			key, value, more := tokenizer.TagAttr()
+			attrs[string(key)] = strings.TrimSpace(string(value))
+			if !more {
+				break
+			}
+		}
+
+		switch {
+		case string(name) == "link" && strings.EqualFold(attrs["rel"], "canonical") && meta.Canonical == "":
+			meta.Canonical = redirectLocation(pageURL, attrs["href"])
+		case strings.EqualFold(attrs["name"], "generator") && meta.Generator == "":
+			meta.Generator = attrs["content"]
+		case strings.EqualFold(attrs["property"], "og:site_name") && meta.SiteName == "":
+			meta.SiteName = attrs["content"]
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// monitorFields are what -notify-on can watch, with the probe flags that
+// fill them
+var monitorFields = map[string][]string{
+	"status": nil,
+	"title":  {"-title"},
+	"tech":   {"-server", "-meta"},
+	"cert":   {"-cert-mismatch"},
+}
+
+// hostState is what monitor mode remembers about a URL between runs
+type hostState struct {
+	Status string   `json:"status"` // status class, e.g. 2xx
+	Title  string   `json:"title,omitempty"`
+	Techs  []string `json:"techs,omitempty"`
+	Cert   string   `json:"cert,omitempty"` // expiry date and status, and the name of a mismatched certificate
+}
+
+// hostChange is a watched field of a URL that changed between two runs.
+// Hosts that appear or disappear change status from or to "".
+type hostChange struct {
+	URL    string `json:"url"`
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// monitor is "livedom monitor": it scans the same targets at an interval
+// and reports only what changed
+type monitor struct {
+	args      []string
+	targets   []string // read from stdin once when args have no input flag
+	every     time.Duration
+	runs      int
+	webhook   string
+	notifyOn  []string
+	statePath string
+	out       io.Writer
+
+	state    map[string]hostState
+	baseline bool // state is from an earlier run
+}
+
+// runMonitor implements "livedom monitor"
+func runMonitor(args []string) {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	every := fs.Duration("every", time.Hour, "Time between the starts of two scans")
+	runs := fs.Int("runs", 0, "Stop after this many scans (0 = run until interrupted)")
+	webhook := fs.String("webhook", "", "POST changes to this URL as JSON (Slack-compatible)")
+	notifyOn := fs.String("notify-on", "status,title,tech,cert", "Changes to report: status (class, or the host appearing or disappearing), title, tech, cert")
+	statePath := fs.String("state", "", "Keep the last seen state of every URL in this file, so restarts don't lose the baseline")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: livedom monitor [flags] [-- probe flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	m, err := newMonitor(fs.Args(), *notifyOn, *statePath)
+	if err != nil {
+		fatal("setting up monitor", err)
+	}
+	m.every, m.runs, m.webhook, m.out = *every, *runs, *webhook, os.Stdout
+
+	if !hasInputFlag(m.args) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				m.targets = append(m.targets, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			fatal("reading input", err)
+		}
+		if len(m.targets) == 0 {
+			fatal("reading input", errors.New("no targets"))
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := m.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		fatal("monitoring", err)
+	}
+}
+
+// newMonitor parses -notify-on, adds the probe flags the watched fields
+// need and loads the state of earlier runs
+func newMonitor(args []string, notifyOn, statePath string) (*monitor, error) {
+	m := &monitor{statePath: statePath, state: make(map[string]hostState)}
+	for _, field := range strings.Split(notifyOn, ",") {
+		field = strings.TrimSpace(field)
+		flags, ok := monitorFields[field]
+		if !ok {
+			return nil, fmt.Errorf("-notify-on: unknown change %q, use status, title, tech or cert", field)
+		}
+		m.notifyOn = append(m.notifyOn, field)
+		args = append(args, flags...)
+	}
+	if slices.Contains(m.notifyOn, "cert") && !slices.ContainsFunc(args, func(arg string) bool {
+		return strings.HasPrefix(strings.TrimLeft(arg, "-"), "cert-expiry-warn")
+	}) {
+		args = append(args, "-cert-expiry-warn", "30d")
+	}
+	m.args = args
+
+	if statePath != "" {
+		data, err := os.ReadFile(statePath)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, &m.state); err != nil {
+				return nil, fmt.Errorf("reading %s: %w", statePath, err)
+			}
+			m.baseline = true
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// hasInputFlag tells whether probe flags name their own input
+func hasInputFlag(args []string) bool {
+	return slices.ContainsFunc(args, func(arg string) bool {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		return strings.HasPrefix(arg, "-") && slices.Contains([]string{"f", "burp-xml", "zap-xml", "ct-domain"}, name)
+	})
+}
+
+// Run scans every m.every until ctx is done or m.runs scans are done
+func (m *monitor) Run(ctx context.Context) error {
+	for run := 1; ; run++ {
+		started := time.Now()
+		if err := m.check(ctx); err != nil {
+			return err
+		}
+		if m.runs > 0 && run >= m.runs {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(started.Add(m.every))):
+		}
+	}
+}
+
+// check runs one scan and reports what changed since the last one. The
+// first scan without a -state file only sets the baseline.
+func (m *monitor) check(ctx context.Context) error {
+	current := make(map[string]hostState)
+	results := make(chan Result)
+	scanned := make(chan error, 1)
+	go func() {
+		scanned <- Scan(ctx, Options{
+			Args:     m.args,
+			Targets:  m.targets,
+			OnResult: func(result Result) { results <- result },
+		})
+		close(results)
+	}()
+	for result := range results {
+		current[result.URL] = newHostState(result)
+	}
+	if err := <-scanned; err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		// A cut short scan would report every host it didn't get to as gone
+		return ctx.Err()
+	}
+
+	if m.baseline {
+		changes := compareStates(m.state, current, m.notifyOn)
+		slog.Info("monitor run done", "urls", len(current), "changes", len(changes))
+		for _, change := range changes {
+			fmt.Fprintf(m.out, "%s %s: %q -> %q\n", change.URL, change.Field, change.Before, change.After)
+		}
+		if len(changes) > 0 && m.webhook != "" {
+			if err := postChanges(ctx, m.webhook, changes); err != nil {
+				slog.Error("sending webhook", "error", err)
+			}
+		}
+	} else {
+		slog.Info("monitor baseline set", "urls", len(current))
+	}
+	m.state, m.baseline = current, true
+
+	if m.statePath != "" {
+		data, err := json.Marshal(m.state)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(m.statePath, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newHostState takes the watched fields of a result
+func newHostState(result Result) hostState {
+	state := hostState{Title: result.Title}
+	if result.StatusCode != 0 {
+		state.Status = statusClass(result.StatusCode)
+	}
+	state.Techs = resultTechnologies(result)
+	sort.Strings(state.Techs)
+	if result.CertNotAfter != nil {
+		state.Cert = result.CertNotAfter.Format("2006-01-02") + " " + result.CertExpiry
+	}
+	if result.CertMismatch {
+		state.Cert = strings.TrimSpace(state.Cert + " mismatch:" + result.CertName)
+	}
+	return state
+}
+
+// compareStates lists the changes of the watched fields, by URL
+func compareStates(before, after map[string]hostState, notifyOn []string) []hostChange {
+	urls := make(map[string]bool)
+	for url := range before {
+		urls[url] = true
+	}
+	for url := range after {
+		urls[url] = true
+	}
+	sorted := make([]string, 0, len(urls))
+	for url := range urls {
+		sorted = append(sorted, url)
+	}
+	sort.Strings(sorted)
+
+	var changes []hostChange
+	for _, url := range sorted {
+		old, seen := before[url]
+		now, live := after[url]
+		if !seen || !live {
+			if slices.Contains(notifyOn, "status") {
+				changes = append(changes, hostChange{url, "status", old.Status, now.Status})
+			}
+			continue
+		}
+		for _, field := range notifyOn {
+			var a, b string
+			switch field {
+			case "status":
+				a, b = old.Status, now.Status
+			case "title":
+				a, b = old.Title, now.Title
+			case "tech":
+				a, b = strings.Join(old.Techs, ","), strings.Join(now.Techs, ",")
+			case "cert":
+				a, b = old.Cert, now.Cert
+			}
+			if a != b {
+				changes = append(changes, hostChange{url, field, a, b})
+			}
+		}
+	}
+	return changes
+}
+
+// postChanges sends changes to a webhook. text is for Slack and other
+// chat webhooks, changes for everything else.
+func postChanges(ctx context.Context, webhook string, changes []hostChange) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "livedom: %d changes", len(changes))
+	for _, change := range changes {
+		fmt.Fprintf(&text, "\n• %s %s: %q → %q", change.URL, change.Field, change.Before, change.After)
+	}
+	body, err := json.Marshal(map[string]any{"text": text.String(), "changes": changes})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
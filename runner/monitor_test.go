@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompareStates(t *testing.T) {
+	before := map[string]hostState{
+		"https://a.example.com": {Status: "2xx", Title: "Home", Techs: []string{"nginx"}},
+		"https://b.example.com": {Status: "2xx", Title: "Login"},
+		"https://c.example.com": {Status: "4xx"},
+	}
+	after := map[string]hostState{
+		"https://a.example.com": {Status: "5xx", Title: "Home", Techs: []string{"WordPress", "nginx"}},
+		"https://b.example.com": {Status: "2xx", Title: "Sign in"},
+		"https://d.example.com": {Status: "2xx"},
+	}
+
+	got := compareStates(before, after, []string{"status", "title", "tech", "cert"})
+	want := []hostChange{
+		{"https://a.example.com", "status", "2xx", "5xx"},
+		{"https://a.example.com", "tech", "nginx", "WordPress,nginx"},
+		{"https://b.example.com", "title", "Login", "Sign in"},
+		{"https://c.example.com", "status", "4xx", ""},
+		{"https://d.example.com", "status", "", "2xx"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("changes = %v\nwant %v", got, want)
+	}
+
+	// Title changes alone don't notify without "title"
+	if got := compareStates(before, after, []string{"status"}); len(got) != 3 {
+		t.Errorf("status only: %v", got)
+	}
+}
+
+func TestMonitorWebhook(t *testing.T) {
+	var requests atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The title changes on the third request, the third run
+		title := "stable"
+		if requests.Add(1) >= 3 {
+			title = "changed"
+		}
+		fmt.Fprintf(w, "<html><head><title>%s</title></head></html>", title)
+	}))
+	defer target.Close()
+
+	var mu sync.Mutex
+	var posts []map[string]any
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		posts = append(posts, body)
+	}))
+	defer hook.Close()
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	m, err := newMonitor([]string{"-timeout", "2s"}, "status,title", statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	m.targets = []string{target.URL}
+	m.every, m.runs, m.webhook, m.out = time.Millisecond, 3, hook.URL, &out
+	if err := m.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The baseline and the unchanged second run stay quiet
+	if len(posts) != 1 {
+		t.Fatalf("got %d webhook posts, want 1: %v", len(posts), posts)
+	}
+	if text, _ := posts[0]["text"].(string); !strings.Contains(text, `"stable" → "changed"`) {
+		t.Errorf("webhook text = %q", text)
+	}
+	if want := target.URL + ` title: "stable" -> "changed"` + "\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	// A restart picks up the state and doesn't set a new baseline
+	restarted, err := newMonitor(nil, "title", statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restarted.baseline || restarted.state[target.URL].Title != "changed" {
+		t.Errorf("restarted with state %v", restarted.state)
+	}
+}
+
+func TestNewMonitorFlags(t *testing.T) {
+	m, err := newMonitor([]string{"-f", "hosts.txt"}, "title,cert", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"-f", "hosts.txt", "-title", "-cert-mismatch", "-cert-expiry-warn", "30d"}
+	if fmt.Sprint(m.args) != fmt.Sprint(want) {
+		t.Errorf("args = %q, want %q", m.args, want)
+	}
+	if !hasInputFlag(m.args) || hasInputFlag([]string{"-title"}) {
+		t.Error("hasInputFlag")
+	}
+
+	if _, err := newMonitor(nil, "status,uptime", ""); err == nil {
+		t.Error("unknown -notify-on field accepted")
+	}
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"net"
+	"strconv"
+)
+
+// Liveness of hosts that didn't answer HTTP, see -include-nonhttp
+const (
+	nonHTTPTCPOpen    = "tcp-open"
+	nonHTTPResolvable = "resolvable"
+)
+
+// checkNonHTTP tells whether a target that failed both HTTPS and HTTP
+// still exists: "tcp-open" if a connection to its port (443 and 80 for
+// bare hosts) succeeds, "resolvable" if its name resolves, "" otherwise
+func checkNonHTTP(target string, config *Config) string {
+	host := extractDomain(target)
+	if host == "" {
+		return ""
+	}
+	isIP := net.ParseIP(host) != nil
+	if !isIP && len(lookupAddresses(host, config.Timeout)) == 0 {
+		return ""
+	}
+
+	ports := []int{443, 80}
+	if port := extractPort(target); port != 0 {
+		ports = []int{port}
+	}
+	for _, port := range ports {
+		conn, err := guardedDialer(config.Timeout, config.guard).Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err == nil {
+			conn.Close()
+			return nonHTTPTCPOpen
+		}
+	}
+
+	if isIP {
+		return ""
+	}
+	return nonHTTPResolvable
+}
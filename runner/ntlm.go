@@ -0,0 +1,165 @@
+package runner
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ntlmNegotiate is a type 1 NTLM message asking for Unicode, NTLM and the
+// target info block, with no domain or workstation of our own
+var ntlmNegotiate = base64.StdEncoding.EncodeToString([]byte{
+	'N', 'T', 'L', 'M', 'S', 'S', 'P', 0,
+	1, 0, 0, 0, // type 1
+	0x07, 0x82, 0x08, 0xa2, // flags
+	0, 0, 0, 0, 0, 0, 0, 0, // domain
+	0, 0, 0, 0, 0, 0, 0, 0, // workstation
+	10, 0, 0x63, 0x45, 0, 0, 0, 15, // version
+})
+
+// NTLM target info attribute IDs (MS-NLMP 2.2.2.1)
+const (
+	ntlmAvEOL             = 0
+	ntlmAvNbComputerName  = 1
+	ntlmAvNbDomainName    = 2
+	ntlmAvDNSComputerName = 3
+	ntlmAvDNSDomainName   = 4
+	ntlmAvDNSTreeName     = 5
+)
+
+// NTLMInfo is what a server discloses about itself in its NTLM challenge
+type NTLMInfo struct {
+	NetBIOSDomain   string `json:"netbios_domain,omitempty"`
+	NetBIOSComputer string `json:"netbios_computer,omitempty"`
+	DNSDomain       string `json:"dns_domain,omitempty"`
+	DNSComputer     string `json:"dns_computer,omitempty"`
+	DNSTree         string `json:"dns_tree,omitempty"`
+	OSVersion       string `json:"os_version,omitempty"`
+}
+
+// probeNTLM sends an NTLM negotiate message to a URL that offered NTLM or
+// Negotiate authentication and decodes the challenge it answers with.
+// Servers return it to anyone, along with their internal host and domain
+// names.
+func probeNTLM(client *fasthttp.Client, target Target, url string, challenges []AuthChallenge, config *Config) *NTLMInfo {
+	scheme := ""
+	for _, challenge := range challenges {
+		switch {
+		case strings.EqualFold(challenge.Scheme, "NTLM"):
+			scheme = "NTLM"
+		case strings.EqualFold(challenge.Scheme, "Negotiate") && scheme == "":
+			scheme = "Negotiate"
+		}
+	}
+	if scheme == "" {
+		return nil
+	}
+
+	req := newFollowUpRequest(url, target, config)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.Set("Authorization", scheme+" "+ntlmNegotiate)
+
+	err := doFollowUp(client, req, resp, config)
+	if err != nil {
+		return nil
+	}
+
+	for _, value := range headerValues(resp, "WWW-Authenticate") {
+		challengeScheme, token, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if !strings.EqualFold(challengeScheme, scheme) || token == "" {
+			continue
+		}
+		message, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+		if err != nil {
+			continue
+		}
+		if info, err := parseNTLMChallenge(message); err == nil {
+			return info
+		}
+	}
+	return nil
+}
+
+// parseNTLMChallenge decodes the target info and version of a type 2 message
+func parseNTLMChallenge(message []byte) (*NTLMInfo, error) {
+	if len(message) < 48 || string(message[:8]) != "NTLMSSP\x00" || binary.LittleEndian.Uint32(message[8:]) != 2 {
+		return nil, errors.New("not an NTLM challenge")
+	}
+
+	info := &NTLMInfo{}
+
+	// The version field is only there when the target info block starts
+	// after it
+	infoLength := int(binary.LittleEndian.Uint16(message[40:]))
+	infoOffset := int(binary.LittleEndian.Uint32(message[44:]))
+	if infoOffset >= 56 && len(message) >= 56 {
+		info.OSVersion = fmt.Sprintf("%d.%d.%d", message[48], message[49], binary.LittleEndian.Uint16(message[50:]))
+	}
+	if infoOffset+infoLength > len(message) {
+		return nil, errors.New("truncated target info")
+	}
+
+	pairs := message[infoOffset : infoOffset+infoLength]
+	for len(pairs) >= 4 {
+		id := binary.LittleEndian.Uint16(pairs)
+		length := int(binary.LittleEndian.Uint16(pairs[2:]))
+		if id == ntlmAvEOL || 4+length > len(pairs) {
+			break
+		}
+		value := decodeUTF16(pairs[4 : 4+length])
+		pairs = pairs[4+length:]
+
+		switch id {
+		case ntlmAvNbComputerName:
+			info.NetBIOSComputer = value
+		case ntlmAvNbDomainName:
+			info.NetBIOSDomain = value
+		case ntlmAvDNSComputerName:
+			info.DNSComputer = value
+		case ntlmAvDNSDomainName:
+			info.DNSDomain = value
+		case ntlmAvDNSTreeName:
+			info.DNSTree = value
+		}
+	}
+
+	return info, nil
+}
+
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// formatNTLM renders the ntlm column, e.g.
+// CORP\WEB01,web01.corp.example.com,10.0.17763
+func formatNTLM(info *NTLMInfo) string {
+	if info == nil {
+		return ""
+	}
+
+	var parts []string
+	if info.NetBIOSDomain != "" || info.NetBIOSComputer != "" {
+		parts = append(parts, info.NetBIOSDomain+`\`+info.NetBIOSComputer)
+	}
+	if info.DNSComputer != "" {
+		parts = append(parts, info.DNSComputer)
+	} else if info.DNSDomain != "" {
+		parts = append(parts, info.DNSDomain)
+	}
+	if info.OSVersion != "" {
+		parts = append(parts, info.OSVersion)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// ntlmChallenge builds a type 2 message with the target info pairs, and the
+// version field unless it's nil
+func ntlmChallenge(version []byte, pairs map[uint16]string) []byte {
+	var info []byte
+	for _, id := range []uint16{ntlmAvNbDomainName, ntlmAvNbComputerName, ntlmAvDNSDomainName, ntlmAvDNSComputerName, ntlmAvDNSTreeName} {
+		value, ok := pairs[id]
+		if !ok {
+			continue
+		}
+		units := utf16.Encode([]rune(value))
+		info = binary.LittleEndian.AppendUint16(info, id)
+		info = binary.LittleEndian.AppendUint16(info, uint16(2*len(units)))
+		for _, unit := range units {
+			info = binary.LittleEndian.AppendUint16(info, unit)
+		}
+	}
+	info = append(info, 0, 0, 0, 0) // EOL
+
+	header := make([]byte, 48)
+	copy(header, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(header[8:], 2)
+	header = append(header, version...)
+	binary.LittleEndian.PutUint16(header[40:], uint16(len(info)))
+	binary.LittleEndian.PutUint32(header[44:], uint32(len(header)))
+	return append(header, info...)
+}
+
+func TestParseNTLMChallenge(t *testing.T) {
+	names := map[uint16]string{
+		ntlmAvNbDomainName:    "CORP",
+		ntlmAvNbComputerName:  "WEB01",
+		ntlmAvDNSDomainName:   "corp.example.com",
+		ntlmAvDNSComputerName: "web01.corp.example.com",
+		ntlmAvDNSTreeName:     "example.com",
+	}
+	windows2019 := []byte{10, 0, 0x63, 0x45, 0, 0, 0, 15}
+	truncated := ntlmChallenge(nil, names)
+
+	tests := []struct {
+		name    string
+		message []byte
+		want    string // formatNTLM of the result
+		wantErr bool
+	}{
+		{"full", ntlmChallenge(windows2019, names), `CORP\WEB01,web01.corp.example.com,10.0.17763`, false},
+		{"no version", ntlmChallenge(nil, names), `CORP\WEB01,web01.corp.example.com`, false},
+		{"domain only", ntlmChallenge(nil, map[uint16]string{ntlmAvDNSDomainName: "corp.example.com"}), "corp.example.com", false},
+		{"unicode", ntlmChallenge(nil, map[uint16]string{ntlmAvNbDomainName: "ÜBER", ntlmAvNbComputerName: "ΣΥΣ"}), `ÜBER\ΣΥΣ`, false},
+		{"truncated info", truncated[:len(truncated)-10], "", true},
+		{"type 1", append([]byte("NTLMSSP\x00\x01\x00\x00\x00"), make([]byte, 40)...), "", true},
+		{"too short", []byte("NTLMSSP\x00"), "", true},
+	}
+	for _, tt := range tests {
+		info, err := parseNTLMChallenge(tt.message)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if got := formatNTLM(info); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// nucleiWriter writes live URLs to a file in the one-target-per-line format
+// nuclei expects for its -l flag. Each URL is written at most once.
+type nucleiWriter struct {
+	*uniqueLineWriter
+	statusCodes map[int]bool
+}
+
+func newNucleiWriter(path string, statusCodes string) (*nucleiWriter, error) {
+	codes, err := parseStatusCodes(statusCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := newUniqueLineWriter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nucleiWriter{
+		uniqueLineWriter: writer,
+		statusCodes:      codes,
+	}, nil
+}
+
+func (w *nucleiWriter) Write(result Result) {
+	// Only export status codes the user asked for (all codes if none given)
+	if len(w.statusCodes) > 0 && !w.statusCodes[result.StatusCode] {
+		return
+	}
+
+	w.WriteLine(strings.TrimSuffix(result.URL, "/"))
+}
+
+// parseStatusCodes parses a comma-separated list of status codes like "200,403"
+func parseStatusCodes(s string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", part)
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
@@ -0,0 +1,232 @@
+package runner
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/valyala/fasthttp"
+)
+
+// oobHeaders are the headers that carry canary hosts. Log pipelines and
+// analytics backends fetch or resolve them, often long after the request.
+var oobHeaders = []struct {
+	name  string
+	label string
+	value func(host string) string
+}{
+	{"X-Forwarded-For", "xff", func(host string) string { return host }},
+	{"Referer", "ref", func(host string) string { return "http://" + host + "/" }},
+}
+
+// oobTokenLength is the per-probe part of a canary host. With interactsh
+// it is the nonce that follows the correlation ID.
+const oobTokenLength = 13
+
+// oobPollInterval is how often interactsh is polled during a scan
+const oobPollInterval = 5 * time.Second
+
+// oobTokenTTL is how long a canary is matched against interactsh callbacks
+// after its probe, or -oob-wait if that is longer
+const oobTokenTTL = 10 * time.Minute
+
+// oobCanaries hands out a unique canary host per probe and maps callbacks
+// back to the URL that sent it
+type oobCanaries struct {
+	domain     string
+	interactsh *interactshClient // nil with -oob-domain
+	ttl        time.Duration
+	mu         sync.Mutex
+	probes     map[string]oobProbe // token -> probe, only with interactsh
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// oobProbe is a probe whose canary may still be called back
+type oobProbe struct {
+	url  string
+	sent time.Time
+}
+
+// OOBHit is a callback to a canary host
+type OOBHit struct {
+	URL           string    `json:"url"`
+	Header        string    `json:"header,omitempty"`
+	Protocol      string    `json:"protocol"`
+	RemoteAddress string    `json:"remote_address,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// newOOBCanaries sets up canaries under a domain whose DNS and HTTP logs
+// the user watches, or registers with an interactsh server that is polled
+// for callbacks
+func newOOBCanaries(config *Config) (*oobCanaries, error) {
+	o := &oobCanaries{
+		domain: strings.Trim(config.OOBDomain, "."),
+		ttl:    max(oobTokenTTL, config.OOBWait),
+		probes: make(map[string]oobProbe),
+	}
+	if config.InteractshServer != "" {
+		client, err := newInteractshClient(config.InteractshServer, config.InteractshToken)
+		if err != nil {
+			return nil, err
+		}
+		o.interactsh = client
+		o.domain = client.domain
+
+		o.stop = make(chan struct{})
+		o.done = make(chan struct{})
+		go o.pollLoop(config)
+	}
+	return o, nil
+}
+
+// Apply adds canary headers for targetURL to req and returns the token
+func (o *oobCanaries) Apply(req *fasthttp.Request, targetURL string) string {
+	token := randomToken(oobTokenLength)
+
+	// Callbacks to -oob-domain show up in the user's logs, only interactsh
+	// ones are matched here
+	host := token + "." + o.domain
+	if o.interactsh != nil {
+		host = o.interactsh.correlationID + token + "." + o.domain
+
+		o.mu.Lock()
+		o.probes[token] = oobProbe{url: targetURL, sent: time.Now()}
+		o.mu.Unlock()
+	}
+	for _, header := range oobHeaders {
+		req.Header.Set(header.name, header.value(header.label+"."+host))
+	}
+	return token
+}
+
+func (o *oobCanaries) pollLoop(config *Config) {
+	defer close(o.done)
+	ticker := time.NewTicker(oobPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+			o.poll(config)
+		}
+	}
+}
+
+func (o *oobCanaries) poll(config *Config) {
+	interactions, err := o.interactsh.Poll()
+	if err != nil {
+		slog.Warn("polling interactsh", "error", err)
+		return
+	}
+	for _, hit := range o.handle(interactions, time.Now()) {
+		displayOOBHit(hit, config)
+	}
+}
+
+// handle matches a batch of interactions to their probes, then forgets the
+// canaries that were hit and those that expired. A canary usually gets its
+// DNS and HTTP callbacks in the same batch.
+func (o *oobCanaries) handle(interactions []interaction, now time.Time) []OOBHit {
+	var hits []OOBHit
+	matched := make(map[string]bool)
+	for _, interaction := range interactions {
+		if hit, token, ok := o.match(interaction); ok {
+			hits = append(hits, hit)
+			matched[token] = true
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for token, probe := range o.probes {
+		if matched[token] || now.Sub(probe.sent) > o.ttl {
+			delete(o.probes, token)
+		}
+	}
+	return hits
+}
+
+// match maps an interaction to the probe whose canary it hit and returns
+// its token. The full ID is the subdomain part of the canary host, e.g.
+// "xff.<correlation ID><token>".
+func (o *oobCanaries) match(interaction interaction) (OOBHit, string, bool) {
+	labels := strings.Split(strings.ToLower(interaction.FullID), ".")
+	for i, label := range labels {
+		if !strings.HasPrefix(label, o.interactsh.correlationID) {
+			continue
+		}
+		token := strings.TrimPrefix(label, o.interactsh.correlationID)
+
+		o.mu.Lock()
+		probe, ok := o.probes[token]
+		o.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		hit := OOBHit{
+			URL:           probe.url,
+			Protocol:      interaction.Protocol,
+			RemoteAddress: interaction.RemoteAddress,
+			Timestamp:     interaction.Timestamp,
+		}
+		if i > 0 {
+			for _, header := range oobHeaders {
+				if labels[i-1] == header.label {
+					hit.Header = header.name
+				}
+			}
+		}
+		return hit, token, true
+	}
+	return OOBHit{}, "", false
+}
+
+// Close waits for late callbacks, polls one last time and deregisters
+func (o *oobCanaries) Close(wait time.Duration, config *Config) {
+	if o.interactsh == nil {
+		return
+	}
+	time.Sleep(wait)
+	close(o.stop)
+	<-o.done
+	o.poll(config)
+	if err := o.interactsh.Close(); err != nil {
+		slog.Warn("deregistering from interactsh", "error", err)
+	}
+}
+
+func displayOOBHit(hit OOBHit, config *Config) {
+	if config.JSONOutput {
+		displayJSON(struct {
+			OOB OOBHit `json:"oob"`
+		}{hit})
+		return
+	}
+
+	columns := []column{
+		newColumn("protocol", hit.Protocol, color.FgHiRed),
+		newColumn("header", hit.Header, color.FgYellow),
+		newColumn("from", hit.RemoteAddress, color.FgCyan),
+	}
+	fmt.Fprintln(color.Output, formatLine("[oob] "+hit.URL, columns))
+}
+
+// randomToken returns n random lowercase letters and digits, usable in a
+// DNS label
+func randomToken(n int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	rand.Read(b)
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b)
+}
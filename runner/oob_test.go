@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestOOBCanaryHeaders(t *testing.T) {
+	o, err := newOOBCanaries(&Config{OOBDomain: "oob.example.com."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	token := o.Apply(req, "https://app.example.com")
+	if got, want := string(req.Header.Peek("X-Forwarded-For")), "xff."+token+".oob.example.com"; got != want {
+		t.Errorf("X-Forwarded-For = %q, want %q", got, want)
+	}
+	if got, want := string(req.Header.Peek("Referer")), "http://ref."+token+".oob.example.com/"; got != want {
+		t.Errorf("Referer = %q, want %q", got, want)
+	}
+
+	// Nothing polls for -oob-domain callbacks, so nothing is kept
+	if len(o.probes) != 0 {
+		t.Errorf("%d probes kept without interactsh", len(o.probes))
+	}
+}
+
+func TestOOBForgetsCanaries(t *testing.T) {
+	o := &oobCanaries{
+		domain:     "oast.fun",
+		interactsh: &interactshClient{correlationID: "corr"},
+		ttl:        oobTokenTTL,
+		probes:     make(map[string]oobProbe),
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	hit := o.Apply(req, "https://hit.example.com")
+	quiet := o.Apply(req, "https://quiet.example.com")
+	if len(o.probes) != 2 {
+		t.Fatalf("%d probes kept, want 2", len(o.probes))
+	}
+
+	// DNS and HTTP callbacks of one canary arrive in the same poll
+	interactions := []interaction{
+		{Protocol: "dns", FullID: "xff.corr" + hit},
+		{Protocol: "http", FullID: "REF.CORR" + strings.ToUpper(hit)},
+		{Protocol: "dns", FullID: "xff.corrunknown"},
+	}
+	hits := o.handle(interactions, time.Now())
+	if len(hits) != 2 {
+		t.Fatalf("got %d hits, want 2", len(hits))
+	}
+	for i, header := range []string{"X-Forwarded-For", "Referer"} {
+		if hits[i].URL != "https://hit.example.com" || hits[i].Header != header {
+			t.Errorf("hit %d = %+v", i, hits[i])
+		}
+	}
+	if _, ok := o.probes[hit]; ok {
+		t.Error("matched canary is still kept")
+	}
+	if _, ok := o.probes[quiet]; !ok {
+		t.Error("canary without callbacks was dropped before it expired")
+	}
+
+	// Canaries are forgotten once they can't be expected to call back
+	if o.handle(nil, time.Now().Add(oobTokenTTL+time.Minute)); len(o.probes) != 0 {
+		t.Errorf("%d expired probes kept", len(o.probes))
+	}
+}
@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// originCandidates maps hosts to IPs they resolved to in the past. IPs
+// listed without a host are tried for every host.
+type originCandidates map[string][]string
+
+// loadOriginCandidates reads historical DNS data for -origin-ips, one
+// "host ip [ip...]" or bare "ip" per line
+func loadOriginCandidates(path string) (originCandidates, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	candidates := make(originCandidates)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		host := ""
+		if net.ParseIP(fields[0]) == nil {
+			host = strings.ToLower(strings.TrimSuffix(fields[0], "."))
+			fields = fields[1:]
+		}
+		for _, ip := range fields {
+			if net.ParseIP(ip) != nil {
+				candidates[host] = append(candidates[host], ip)
+			}
+		}
+	}
+	return candidates, scanner.Err()
+}
+
+// originIPs collects the candidate origins of host: its historical IPs and
+// the addresses of in-scope names in its certificate, minus the addresses
+// it resolves to now (the CDN edge)
+func originIPs(host string, cert *x509.Certificate, config *Config) []string {
+	var ips []string
+	ips = append(ips, config.originCandidates[host]...)
+	ips = append(ips, config.originCandidates[""]...)
+
+	if cert != nil {
+		for _, san := range extractSANs(cert) {
+			if san != host && !strings.HasPrefix(san, "*.") && inScope(san, host, config.scope) {
+				ips = append(ips, lookupAddresses(san, config.Timeout)...)
+			}
+		}
+	}
+
+	current := make(map[string]bool)
+	for _, ip := range lookupAddresses(host, config.Timeout) {
+		current[ip] = true
+	}
+
+	seen := make(map[string]bool)
+	var origins []string
+	for _, ip := range ips {
+		if !current[ip] && !seen[ip] {
+			seen[ip] = true
+			origins = append(origins, ip)
+		}
+	}
+	return origins
+}
+
+func lookupAddresses(host string, timeout time.Duration) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addresses, _ := net.DefaultResolver.LookupHost(ctx, host)
+	return addresses
+}
+
+// huntOrigins requests targetURL from each candidate IP directly, with the
+// real Host header and SNI, and returns the IPs that serve the same status
+// and body hash as the CDN did
+func huntOrigins(target Target, targetURL string, status int, bodyHash string, candidates []string, config *Config) []string {
+	var origins []string
+	for _, ip := range candidates {
+		gotStatus, gotHash, err := fetchFromIP(target, targetURL, ip, config)
+		if err != nil {
+			continue
+		}
+		if gotStatus == status && gotHash == bodyHash {
+			origins = append(origins, ip)
+		}
+	}
+	return origins
+}
+
+// fetchFromIP sends the probe for targetURL to ip instead of whatever the
+// hostname resolves to
+func fetchFromIP(target Target, targetURL, ip string, config *Config) (int, string, error) {
+	client := &fasthttp.Client{
+		ReadTimeout:                   config.Timeout,
+		WriteTimeout:                  config.Timeout,
+		MaxIdemponentCallAttempts:     1,
+		DisableHeaderNamesNormalizing: true,
+		DisablePathNormalizing:        true,
+		// Origins often serve an old or self-signed certificate, and only
+		// the content matters here
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		Dial: func(addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			return guardedDialer(config.Timeout, config.guard).Dial("tcp", net.JoinHostPort(ip, port))
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(targetURL)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	applyTargetSettings(req, target, config)
+	err := client.Do(req, resp)
+	config.stats.recordRequest(err)
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.StatusCode(), bodyHash(resp.Body()), nil
+}
+
+// bodyHash is the -hash of a body: SHA-256 of its first 8KB
+func bodyHash(body []byte) string {
+	if len(body) > 8192 {
+		body = body[:8192]
+	}
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:])
+}
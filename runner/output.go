@@ -0,0 +1,25 @@
+package runner
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// jsonMu keeps JSON lines from concurrent workers from interleaving
+var jsonMu sync.Mutex
+
+// displayJSON writes v as a single JSON line to stdout, tagged with the
+// schema version
+func displayJSON(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("encoding JSON", "error", err)
+		return
+	}
+
+	jsonMu.Lock()
+	defer jsonMu.Unlock()
+	os.Stdout.Write(append(withSchema(data), '\n'))
+}
@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresLockID is the advisory lock scanners sharing a database take to
+// migrate its schema one at a time
+const postgresLockID = 0x6c697665
+
+// postgresMigrations bring the tables of a PostgreSQL -db up to date, as
+// sqliteMigrations do for SQLite
+var postgresMigrations = []string{
+	// 1: runs and their results
+	`
+CREATE TABLE livedom_runs (
+	run_id   text PRIMARY KEY,
+	started  timestamptz NOT NULL,
+	finished timestamptz,
+	scanner  text NOT NULL,
+	args     jsonb NOT NULL
+);
+CREATE INDEX livedom_runs_started ON livedom_runs (started);
+CREATE TABLE livedom_results (
+	run_id      text NOT NULL REFERENCES livedom_runs ON DELETE CASCADE,
+	host        text NOT NULL,
+	port        integer NOT NULL,
+	path        text NOT NULL,
+	url         text NOT NULL,
+	status_code integer,
+	title       text,
+	result      jsonb NOT NULL,
+	seen        timestamptz NOT NULL,
+	PRIMARY KEY (host, port, path, run_id)
+);
+CREATE INDEX livedom_results_run_id ON livedom_results (run_id);
+`,
+}
+
+// postgresInsert stores a result, updating the row a rerun of the same
+// run already has
+const postgresInsert = `INSERT INTO livedom_results (run_id, host, port, path, url, status_code, title, result, seen)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (host, port, path, run_id) DO UPDATE SET url = EXCLUDED.url, status_code = EXCLUDED.status_code,
+	title = EXCLUDED.title, result = EXCLUDED.result, seen = EXCLUDED.seen`
+
+// postgresStore keeps -db results in PostgreSQL
+type postgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// openPostgres connects to the database of a postgres:// URL. Connections
+// that break are replaced by the pool on the next use.
+func openPostgres(ctx context.Context, rawURL string) (*postgresStore, error) {
+	pool, err := pgxpool.New(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return &postgresStore{pool: pool}, nil
+}
+
+// migrate applies the migrations the database hasn't had yet, each in a
+// transaction
+func (s *postgresStore) migrate(ctx context.Context) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", postgresLockID); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", postgresLockID)
+
+	if _, err := conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS livedom_migrations (version integer PRIMARY KEY, applied timestamptz NOT NULL DEFAULT now())"); err != nil {
+		return fmt.Errorf("creating the migrations table: %w", err)
+	}
+	var version int
+	if err := conn.QueryRow(ctx, "SELECT coalesce(max(version), 0) FROM livedom_migrations").Scan(&version); err != nil {
+		return fmt.Errorf("reading the schema version: %w", err)
+	}
+	if err := checkSchemaVersion(version, len(postgresMigrations)); err != nil {
+		return err
+	}
+
+	for i := version; i < len(postgresMigrations); i++ {
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, postgresMigrations[i]); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO livedom_migrations (version) VALUES ($1)", i+1)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migrating the schema to version %d: %w", i+1, err)
+		}
+		slog.Info("migrated the database schema", "version", i+1)
+	}
+	return nil
+}
+
+func (s *postgresStore) startRun(ctx context.Context, runID string, started time.Time, scanner string, args []byte) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO livedom_runs (run_id, started, scanner, args) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (run_id) DO UPDATE SET finished = NULL`, runID, started, scanner, args)
+	return err
+}
+
+// insert sends rows in one batch, a round trip that stores all of them or
+// none
+func (s *postgresStore) insert(ctx context.Context, runID string, rows []dbRow) error {
+	batch := &pgx.Batch{}
+	for _, row := range rows {
+		var status any
+		if row.status != 0 {
+			status = row.status
+		}
+		// jsonb can't hold NUL characters
+		result := bytes.ReplaceAll(row.result, []byte(`\u0000`), []byte(`\ufffd`))
+		batch.Queue(postgresInsert, runID, row.host, row.port, row.path, row.url, status, row.title, result, row.seen)
+	}
+	return s.pool.SendBatch(ctx, batch).Close()
+}
+
+func (s *postgresStore) finishRun(ctx context.Context, runID string, finished time.Time) error {
+	_, err := s.pool.Exec(ctx, "UPDATE livedom_runs SET finished = $2 WHERE run_id = $1", runID, finished)
+	return err
+}
+
+func (s *postgresStore) prune(ctx context.Context, runID string, retention dbRetention) (int64, error) {
+	var pruned int64
+	if retention.keepRuns > 0 {
+		tag, err := s.pool.Exec(ctx, `DELETE FROM livedom_runs WHERE run_id <> $1 AND run_id IN
+			(SELECT run_id FROM livedom_runs ORDER BY started DESC OFFSET $2)`, runID, retention.keepRuns)
+		if err != nil {
+			return pruned, err
+		}
+		pruned += tag.RowsAffected()
+	}
+	if retention.pruneOlder > 0 {
+		tag, err := s.pool.Exec(ctx, "DELETE FROM livedom_runs WHERE run_id <> $1 AND started < $2", runID, time.Now().Add(-retention.pruneOlder))
+		if err != nil {
+			return pruned, err
+		}
+		pruned += tag.RowsAffected()
+	}
+	return pruned, nil
+}
+
+// rejected tells errors of the statements, which lose their batch, from
+// lost connections and FATAL errors, which lose only the session
+func (s *postgresStore) rejected(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Severity == "ERROR"
+}
+
+func (s *postgresStore) Close() error {
+	s.pool.Close()
+	return nil
+}
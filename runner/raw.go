@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// rawRequest is a -raw-request template: a complete HTTP request sent
+// byte for byte, with {{host}} replaced by the host (and port) of each
+// target. Nothing is validated, a broken template makes broken requests.
+type rawRequest struct {
+	template []byte
+	head     bool // HEAD responses have no body to read
+}
+
+func loadRawRequest(path string) (*rawRequest, error) {
+	template, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rawRequest{template: template, head: bytes.HasPrefix(template, []byte("HEAD "))}, nil
+}
+
+// Do sends the template to the host of targetURL over TCP, or TLS for
+// https URLs, and reads the response into resp. req is replaced by the
+// parsed template, where it parses, for -trace, -har and -include-request.
+func (r *rawRequest) Do(targetURL string, req *fasthttp.Request, resp *fasthttp.Response, config *Config) error {
+	parsed, err := url.Parse(targetURL)
+	if err != nil {
+		return err
+	}
+	address := parsed.Host
+	if parsed.Port() == "" {
+		port := "80"
+		if parsed.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(parsed.Hostname(), port)
+	}
+
+	dialer := guardedDialer(config.Timeout, config.guard)
+	var conn net.Conn
+	if parsed.Scheme == "https" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: parsed.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+
+	raw := bytes.ReplaceAll(r.template, []byte("{{host}}"), []byte(parsed.Host))
+	if _, err := conn.Write(raw); err != nil {
+		return err
+	}
+
+	var sent fasthttp.Request
+	if sent.Read(bufio.NewReader(bytes.NewReader(raw))) == nil {
+		sent.CopyTo(req)
+	}
+
+	resp.SkipBody = r.head
+	return resp.Read(bufio.NewReader(conn))
+}
@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"bytes"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/html"
+)
+
+// maxRedirectHops is how far -redirect-check follows a Location chain
+// before calling it a loop. Browsers give up at 20, real chains are short.
+const maxRedirectHops = 10
+
+// jsRedirectPattern matches the trivial JavaScript redirects parked and
+// transition pages use, e.g. window.location.href = "/new" or
+// location.replace('https://example.com')
+var jsRedirectPattern = regexp.MustCompile(`(?:window\.|document\.|top\.|self\.)?location(?:\.href)?\s*(?:=\s*|\.(?:replace|assign)\(\s*)["']([^"']+)["']`)
+
+// detectBodyRedirect returns the absolute destination of a <meta refresh> or
+// JavaScript redirect in an HTML body, or "" if there is none. Meta refresh
+// wins since browsers act on it even with scripts disabled.
+func detectBodyRedirect(pageURL string, body []byte) string {
+	if target := metaRefreshTarget(body); target != "" {
+		return redirectLocation(pageURL, target)
+	}
+	if match := jsRedirectPattern.FindSubmatch(body); match != nil {
+		return redirectLocation(pageURL, string(match[1]))
+	}
+	return ""
+}
+
+// metaRefreshTarget extracts the URL from <meta http-equiv="refresh" content="0; url=...">
+func metaRefreshTarget(body []byte) string {
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return ""
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := tokenizer.TagName()
+		if string(name) != "meta" || !hasAttr {
+			continue
+		}
+
+		var httpEquiv, content string
+		for {
+			key, value, more := tokenizer.TagAttr()
+			switch string(key) {
+			case "http-equiv":
+				httpEquiv = strings.ToLower(string(value))
+			case "content":
+				content = string(value)
+			}
+			if !more {
+				break
+			}
+		}
+		if httpEquiv != "refresh" {
+			continue
+		}
+
+		// content is "<delay>; url=<target>", the url= part is optional
+		_, target, found := strings.Cut(content, ";")
+		if !found {
+			return ""
+		}
+		target = strings.TrimSpace(target)
+		if len(target) >= 4 && strings.EqualFold(target[:4], "url=") {
+			target = target[4:]
+		}
+		return strings.Trim(strings.TrimSpace(target), `"'`)
+	}
+}
+
+// checkRedirectChain follows the redirects of a response whose Location
+// resolved to location and returns the URLs visited, in order, plus the
+// problems found: "downgrade" when an https URL redirects to http, and
+// "loop" when the chain comes back to a URL or doesn't end within
+// maxRedirectHops.
+func checkRedirectChain(client *fasthttp.Client, target Target, start, location string, config *Config) (chain, issues []string) {
+	seen := map[string]bool{start: true}
+	previous := start
+
+	for location != "" {
+		if strings.HasPrefix(previous, "https://") && strings.HasPrefix(location, "http://") && !slices.Contains(issues, "downgrade") {
+			issues = append(issues, "downgrade")
+		}
+		if seen[location] || len(chain) == maxRedirectHops {
+			issues = append(issues, "loop")
+			break
+		}
+		seen[location] = true
+		chain = append(chain, location)
+
+		// Show where an out-of-scope hop goes without requesting it
+		if !redirectAllowed(previous, location, config) {
+			break
+		}
+
+		next, err := fetchLocation(client, target, location, config)
+		if err != nil {
+			break
+		}
+		previous, location = location, next
+	}
+
+	return chain, issues
+}
+
+// redirectScopes are the values of -redirect-scope
+var redirectScopes = []string{"same-host", "same-domain", "any"}
+
+// redirectAllowed reports whether -redirect-scope lets us follow a
+// redirect from one URL to another. same-domain is the -scope check
+// used for discovered hosts.
+func redirectAllowed(from, to string, config *Config) bool {
+	fromHost, toHost := extractDomain(from), extractDomain(to)
+	switch config.RedirectScope {
+	case "any":
+		return true
+	case "same-host":
+		return strings.EqualFold(fromHost, toHost)
+	default:
+		return strings.EqualFold(fromHost, toHost) || inScope(toHost, fromHost, config.scope)
+	}
+}
+
+// writeRedirectHosts writes the hosts of redirect destinations other than
+// domain itself to w
+func writeRedirectHosts(w *uniqueLineWriter, domain string, destinations ...string) {
+	for _, destination := range destinations {
+		host := strings.ToLower(extractDomain(destination))
+		if host != "" && !strings.EqualFold(host, domain) {
+			w.WriteLine(host)
+		}
+	}
+}
+
+// fetchLocation requests url and returns where it redirects to, if anywhere
+func fetchLocation(client *fasthttp.Client, target Target, url string, config *Config) (string, error) {
+	req := newFollowUpRequest(url, target, config)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	err := doFollowUp(client, req, resp, config)
+	if err != nil {
+		return "", err
+	}
+
+	if status := resp.StatusCode(); status < 300 || status >= 400 {
+		return "", nil
+	}
+	return redirectLocation(url, headerValue(resp, "Location")), nil
+}
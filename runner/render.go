@@ -0,0 +1,224 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/html"
+)
+
+// chromeNames are the executables -render looks for when -render-chrome
+// isn't given
+var chromeNames = []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "chrome"}
+
+// maxRenders bounds concurrent headless Chrome processes, which cost far
+// more than a probe
+const maxRenders = 4
+
+// renderBudget is the virtual time scripts get to build the page
+const renderBudget = 5 * time.Second
+
+// jsShellTextLimit is the visible text below which a page with scripts is
+// taken for an SPA shell
+const jsShellTextLimit = 200
+
+// renderer renders pages in headless Chrome
+type renderer struct {
+	chrome    string
+	noSandbox bool // -render-no-sandbox
+	proxy     *renderProxy
+	slots     chan struct{}
+}
+
+// newRenderer finds Chrome and starts the proxy its traffic goes through,
+// connecting with dial
+func newRenderer(chrome string, noSandbox bool, timeout time.Duration, dial fasthttp.DialFuncWithTimeout) (*renderer, error) {
+	if chrome == "" {
+		for _, name := range chromeNames {
+			if path, err := exec.LookPath(name); err == nil {
+				chrome = path
+				break
+			}
+		}
+		if chrome == "" {
+			return nil, errors.New("no Chrome or Chromium found, set -render-chrome")
+		}
+	}
+	proxy, err := newRenderProxy(timeout, dial)
+	if err != nil {
+		return nil, err
+	}
+	return &renderer{chrome: chrome, noSandbox: noSandbox, proxy: proxy, slots: make(chan struct{}, maxRenders)}, nil
+}
+
+// renderProxy is the HTTP proxy all of Chrome's traffic goes through. It
+// connects like the probes do, so -disallow-private, -disallow-metadata
+// and -via apply to rendered pages and everything they load.
+type renderProxy struct {
+	listener net.Listener
+	timeout  time.Duration
+	dial     fasthttp.DialFuncWithTimeout // the scan's dialTarget
+	forward  *httputil.ReverseProxy
+}
+
+// newRenderProxy starts a renderProxy on a loopback port
+func newRenderProxy(timeout time.Duration, dial fasthttp.DialFuncWithTimeout) (*renderProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &renderProxy{listener: listener, timeout: timeout, dial: dial}
+	p.forward = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL = r.In.URL
+		},
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, addr string) (net.Conn, error) {
+				return p.dial(addr, timeout)
+			},
+		},
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	go http.Serve(listener, p)
+	return p, nil
+}
+
+// URL is the address to give Chrome's --proxy-server
+func (p *renderProxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// ServeHTTP tunnels CONNECT requests (HTTPS) and forwards plain HTTP
+func (p *renderProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		if !r.URL.IsAbs() {
+			http.Error(w, "not a proxy request", http.StatusBadRequest)
+			return
+		}
+		p.forward.ServeHTTP(w, r)
+		return
+	}
+
+	upstream, err := p.dial(r.Host, p.timeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	fmt.Fprint(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+}
+
+// Render returns the DOM of pageURL after its scripts ran
+func (r *renderer) Render(pageURL string, timeout time.Duration) ([]byte, error) {
+	r.slots <- struct{}{}
+	defer func() { <-r.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+renderBudget)
+	defer cancel()
+
+	// Pages come from untrusted targets, keep Chrome's sandbox unless it
+	// can't start, e.g. as root in a container
+	args := []string{
+		"--headless=new",
+		"--disable-gpu",
+		"--user-agent=Mozilla/5.0",
+		fmt.Sprintf("--virtual-time-budget=%d", renderBudget.Milliseconds()),
+		"--dump-dom",
+		"--proxy-server=" + r.proxy.URL(),
+		// Loopback addresses bypass proxies unless told otherwise
+		"--proxy-bypass-list=<-loopback>",
+	}
+	if r.noSandbox {
+		args = append(args, "--no-sandbox")
+	}
+	cmd := exec.CommandContext(ctx, r.chrome, append(args, pageURL)...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// needsRender reports whether a page's static HTML doesn't show what users
+// see: it has no title, or it is a script-driven shell with hardly any text
+func needsRender(body []byte) bool {
+	if title, _ := extractTitle(bytes.NewReader(body)); title == "" {
+		return true
+	}
+	return isJSShell(body)
+}
+
+// isJSShell reports whether body has scripts and little visible text, like
+// the <div id="root"></div> pages of React, Vue or Angular apps
+func isJSShell(body []byte) bool {
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	scripts, text := 0, 0
+	skip := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return scripts > 0 && text < jsShellTextLimit
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "script":
+				scripts++
+				skip = true
+			case "style", "noscript", "title":
+				skip = true
+			}
+		case html.EndTagToken:
+			skip = false
+		case html.TextToken:
+			if !skip {
+				text += len(strings.TrimSpace(string(tokenizer.Text())))
+			}
+		}
+	}
+}
+
+// renderPage renders targetURL and takes the title and hash from the DOM,
+// which it returns for -dom-hash. It returns nil if rendering failed.
+func renderPage(targetURL string, result *Result, config *Config) []byte {
+	dom, err := config.renderer.Render(targetURL, config.Timeout)
+	if err != nil || len(dom) == 0 {
+		return nil
+	}
+	result.Rendered = true
+
+	if config.ShowTitle {
+		if title, err := extractTitle(bytes.NewReader(dom)); err == nil && title != "" {
+			result.Title = title
+		}
+	}
+	if config.ShowHash {
+		hash := sha256.Sum256(dom)
+		result.Hash = hex.EncodeToString(hash[:])
+	}
+	return dom
+}
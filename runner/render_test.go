@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackruler/livedom/internal/testserver"
+)
+
+// fakeChrome writes a stand-in for Chrome that prints its arguments as
+// the DOM
+func fakeChrome(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "chrome")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRenderSandbox(t *testing.T) {
+	chrome := fakeChrome(t)
+	for _, noSandbox := range []bool{false, true} {
+		r, err := newRenderer(chrome, noSandbox, time.Second, (&Config{}).dialTarget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := r.Render("https://example.com/", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args := string(out)
+		if got := strings.Contains(args, "--no-sandbox"); got != noSandbox {
+			t.Errorf("noSandbox %v: Chrome ran with %s", noSandbox, args)
+		}
+		if strings.Contains(args, "--ignore-certificate-errors") {
+			t.Errorf("Chrome ignores certificate errors: %s", args)
+		}
+		if !strings.Contains(args, "--proxy-server="+r.proxy.URL()) {
+			t.Errorf("Chrome doesn't use the render proxy: %s", args)
+		}
+	}
+}
+
+func TestRenderProxy(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+	tlsServer := testserver.NewTLS()
+	defer tlsServer.Close()
+
+	// get requests target through a new render proxy, plain HTTP forwarded
+	// and HTTPS tunneled with CONNECT
+	config := &Config{}
+	get := func(target string) (int, error) {
+		proxy, err := newRenderProxy(time.Second, config.dialTarget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxyURL, _ := url.Parse(proxy.URL())
+		transport := tlsServer.Client().Transport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		defer transport.CloseIdleConnections()
+
+		resp, err := (&http.Client{Transport: transport}).Get(target)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	for _, target := range []string{server.URL + "/ok", tlsServer.URL + "/ok"} {
+		if status, err := get(target); err != nil || status != 200 {
+			t.Errorf("%s through the proxy: %d, %v", target, status, err)
+		}
+	}
+
+	// -disallow-private applies to Chrome's connections too
+	guard, err := newAddressGuard(true, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.guard = guard
+	if status, err := get(server.URL + "/ok"); err == nil && status != http.StatusBadGateway {
+		t.Errorf("HTTP to a private address got %d through the proxy", status)
+	}
+	if _, err := get(tlsServer.URL + "/ok"); err == nil {
+		t.Error("HTTPS to a private address was tunneled")
+	}
+}
@@ -0,0 +1,184 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// harLog is the part of a HAR document replay reads
+type harLog struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+// runReplay implements "livedom replay": it re-runs the response analyzers
+// over the responses of a HAR file (written by -har, Burp, ZAP or browser
+// devtools) without sending any traffic, so new fingerprints, secret
+// patterns or flags apply to old scans
+func runReplay(args []string) {
+	config := parseFlags("replay", args)
+
+	var reader io.Reader = os.Stdin
+	if config.InputFile != "" {
+		file, err := os.Open(config.InputFile)
+		if err != nil {
+			fatal("opening HAR file", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	var har harLog
+	if err := json.NewDecoder(reader).Decode(&har); err != nil {
+		fatal("reading HAR file", err)
+	}
+
+	if config.FilterHashFile != "" || config.FilterDefaultHashes {
+		hashes, err := loadFilterHashes(config.FilterHashFile)
+		if err != nil {
+			fatal("loading filter hashes", err)
+		}
+		config.filterHashes = hashes
+	}
+	if config.FingerprintDB != "" {
+		fingerprints, err := loadFingerprintStore(config.FingerprintDB)
+		if err != nil {
+			fatal("loading fingerprint database", err)
+		}
+		config.fingerprints = fingerprints
+		defer func() {
+			if err := fingerprints.Save(); err != nil {
+				slog.Error("saving fingerprint database", "error", err)
+			}
+		}()
+	}
+	if config.Table && !config.JSONOutput {
+		config.table = newTableWriter("url")
+	}
+
+	var replayed, filtered int
+	for _, entry := range har.Log.Entries {
+		result, err := replayEntry(entry, config)
+		if err != nil {
+			slog.Warn("skipping HAR entry", "url", entry.Request.URL, "error", err)
+			continue
+		}
+		if result.Filtered {
+			filtered++
+			continue
+		}
+		replayed++
+		displaySingleResult(result, config)
+	}
+
+	if config.table != nil {
+		config.table.Flush()
+	}
+	if !config.Silent {
+		fmt.Fprintf(os.Stderr, "Replayed %d responses, %d filtered\n", replayed, filtered)
+	}
+}
+
+// replayEntry rebuilds the response of a HAR entry and runs the analyzers
+// that only need the response on it, like checkSubdomain does after a
+// probe. Lookups and extra requests (-cname, -js-endpoints, -api-detect...)
+// are skipped; -ip shows the server address the HAR recorded.
+func replayEntry(entry harEntry, config *Config) (Result, error) {
+	body := []byte(entry.Response.Content.Text)
+	if entry.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Response.Content.Text)
+		if err != nil {
+			return Result{}, err
+		}
+		body = decoded
+	}
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	resp.SetStatusCode(entry.Response.Status)
+	for _, header := range entry.Response.Headers {
+		if strings.EqualFold(header.Name, "Content-Length") || strings.EqualFold(header.Name, "Transfer-Encoding") {
+			continue
+		}
+		resp.Header.Add(header.Name, header.Value)
+	}
+	resp.SetBody(body)
+
+	targetURL := entry.Request.URL
+	result := Result{
+		URL:           targetURL,
+		StatusCode:    entry.Response.Status,
+		ContentType:   string(resp.Header.Peek("Content-Type")),
+		Server:        string(resp.Header.Peek("Server")),
+		ContentLength: int64(len(body)),
+		ResponseTime:  formatResponseTime(time.Duration(entry.Time * float64(time.Millisecond))),
+		ProtectedBy:   detectChallenge(resp),
+		Labels:        config.labels,
+	}
+	if config.ShowIP {
+		result.IP = entry.ServerIPAddress
+	}
+	if config.Cookies {
+		result.Cookies = responseCookies(resp)
+	}
+	if result.StatusCode == fasthttp.StatusUnauthorized {
+		result.Auth = authChallenges(resp)
+	}
+
+	// The same 8KB the probe looks at
+	head := body
+	if len(head) > 8192 {
+		head = head[:8192]
+	}
+
+	if config.ShowHash || config.filterHashes != nil {
+		hash := sha256.Sum256(head)
+		result.Hash = hex.EncodeToString(hash[:])
+		if config.filterHashes[result.Hash] {
+			result.Filtered = true
+			return result, nil
+		}
+	}
+	if config.ShowTitle && result.ProtectedBy == "" {
+		result.Title, _ = extractTitle(strings.NewReader(string(head)))
+		if result.Title == "" && len(config.titleFallback) > 0 {
+			result.Title, result.TitleSource = titleFallback(head, config.titleFallback)
+		}
+	}
+	if config.DOMHash {
+		result.DOMHash = domHash(body)
+	}
+	if config.BodyRedirect {
+		result.BodyRedirect = detectBodyRedirect(targetURL, head)
+	}
+	if config.ShowMeta {
+		meta := extractPageMeta(targetURL, head)
+		result.Meta = &meta
+	}
+	if config.ShowLang {
+		result.Lang = detectLanguage(body)
+	}
+	if config.fingerprints != nil {
+		if similarity := config.fingerprints.Compare(targetURL, computeFingerprint(body)); similarity >= 0 {
+			result.Similarity = &similarity
+		}
+	}
+	if config.Secrets {
+		result.Secrets = scanSecrets(body, config.RedactSecrets)
+	}
+	if config.JS {
+		result.Scripts = extractScriptURLs(targetURL, body)
+	}
+	return result, nil
+}
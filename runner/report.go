@@ -0,0 +1,298 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// scanReport summarizes the -json results of one or more scans
+type scanReport struct {
+	results  []Result
+	failed   map[string]int64 // failed probes by reason, see -include-failed
+	nonHTTP  int64
+	statuses map[int]int64
+	top      *topValues
+	findings []reportFinding
+}
+
+// reportFinding is a result worth a closer look
+type reportFinding struct {
+	URL    string
+	Kind   string
+	Detail string
+}
+
+// reportLine is a -json line as far as reports look at it: a result, or a
+// callback with -interactsh-server. Other lines have no URL and are skipped.
+type reportLine struct {
+	Result
+	OOB *OOBHit `json:"oob"`
+}
+
+// runReport implements "livedom report": it summarizes -json results as
+// text, Markdown or HTML
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	input := fs.String("f", "", "livedom -json output to summarize (default: stdin)")
+	format := fs.String("format", "text", "Report format: text, markdown or html")
+	topN := fs.Int("top", 10, "Number of most common titles, servers and technologies to list")
+	fs.Parse(args)
+
+	write, ok := reportFormats[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown -format %q, use text, markdown or html\n", *format)
+		os.Exit(2)
+	}
+
+	var reader io.Reader = os.Stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			fatal("opening results", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	report, err := readReport(reader)
+	if err != nil {
+		fatal("reading results", err)
+	}
+	if err := write(report, os.Stdout, *topN); err != nil {
+		fatal("writing report", err)
+	}
+}
+
+// readReport reads -json lines into a report
+func readReport(reader io.Reader) (*scanReport, error) {
+	report := &scanReport{failed: make(map[string]int64), statuses: make(map[int]int64), top: newTopValues()}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var line reportLine
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			slog.Warn("skipping results line", "line", n, "error", err)
+			continue
+		}
+		switch {
+		case line.OOB != nil:
+			report.findings = append(report.findings, reportFinding{line.OOB.URL, "oob callback", line.OOB.Protocol + " from " + line.OOB.RemoteAddress})
+		case line.URL != "":
+			report.Add(line.Result)
+		}
+	}
+	return report, scanner.Err()
+}
+
+// Add counts a result and notes its findings
+func (r *scanReport) Add(result Result) {
+	switch {
+	case result.Failed != "":
+		r.failed[result.Failed]++
+		return
+	case result.NonHTTP != "":
+		r.nonHTTP++
+		return
+	}
+
+	r.results = append(r.results, result)
+	if result.StatusCode != 0 {
+		r.statuses[result.StatusCode]++
+	}
+	r.top.Add(result)
+
+	note := func(kind, detail string) {
+		r.findings = append(r.findings, reportFinding{result.URL, kind, detail})
+	}
+	if result.CertExpiry == "expired" || result.CertExpiry == "expiring" {
+		detail := ""
+		if result.CertNotAfter != nil {
+			detail = "not after " + result.CertNotAfter.Format("2006-01-02")
+		}
+		note("certificate "+result.CertExpiry, detail)
+	}
+	if result.CertMismatch {
+		note("certificate mismatch", "issued for "+result.CertName)
+	}
+	for _, issue := range result.RedirectIssues {
+		note("redirect "+issue, strings.Join(result.RedirectChain, " -> "))
+	}
+	if len(result.Secrets) > 0 {
+		note("secrets", strconv.Itoa(len(result.Secrets))+" found")
+	}
+	for _, file := range result.WellKnown {
+		if file.StatusCode == 200 {
+			note("exposed file", file.Path)
+		}
+	}
+	for _, endpoint := range result.GraphQL {
+		if endpoint.Introspection {
+			note("graphql introspection", endpoint.URL)
+		}
+	}
+	for _, spec := range result.APISpecs {
+		note("api spec", spec.URL)
+	}
+	if result.Creds != nil && result.Creds.Accepted {
+		note("credentials accepted", "")
+	}
+}
+
+// Failed returns the number of failed probes
+func (r *scanReport) Failed() int64 {
+	var n int64
+	for _, count := range r.failed {
+		n += count
+	}
+	return n
+}
+
+// reportFormats write a report with the n most common values
+var reportFormats = map[string]func(report *scanReport, w io.Writer, n int) error{
+	"text":     writeTextReport,
+	"markdown": writeMarkdownReport,
+	"html":     writeHTMLReport,
+}
+
+// sortedStatuses returns the status codes of a report in order
+func (r *scanReport) sortedStatuses() []int {
+	codes := make([]int, 0, len(r.statuses))
+	for code := range r.statuses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// topSections are the -top-n sections of a report
+func (r *scanReport) topSections(n int) []topSection {
+	return []topSection{
+		{"titles", top(r.top.titles, n)},
+		{"servers", top(r.top.servers, n)},
+		{"technologies", top(r.top.techs, n)},
+	}
+}
+
+// topSection is a list of most common values
+type topSection struct {
+	Name   string
+	Values []topCount
+}
+
+func writeTextReport(r *scanReport, w io.Writer, n int) error {
+	fmt.Fprintf(w, "Results: %d live, %d failed, %d without HTTP\n", len(r.results), r.Failed(), r.nonHTTP)
+
+	fmt.Fprintln(w, "\nStatus codes:")
+	for _, code := range r.sortedStatuses() {
+		fmt.Fprintf(w, "  %d %7d\n", code, r.statuses[code])
+	}
+	for _, section := range r.topSections(n) {
+		fmt.Fprintf(w, "\nTop %s:\n", section.Name)
+		for _, value := range section.Values {
+			fmt.Fprintf(w, "  %7d  %s\n", value.Count, value.Value)
+		}
+	}
+
+	fmt.Fprintf(w, "\nFindings: %d\n", len(r.findings))
+	for _, finding := range r.findings {
+		fmt.Fprintf(w, "  %s  %s  %s\n", finding.URL, finding.Kind, finding.Detail)
+	}
+
+	fmt.Fprintln(w, "\nHosts:")
+	for _, result := range r.results {
+		fmt.Fprintf(w, "  %s [%d] [%s] [%s]\n", result.URL, result.StatusCode, result.Title, result.Server)
+	}
+	return nil
+}
+
+// markdownCell escapes a value for a Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "\r", "").Replace(s)
+}
+
+func writeMarkdownReport(r *scanReport, w io.Writer, n int) error {
+	fmt.Fprintln(w, "# livedom report")
+	fmt.Fprintf(w, "\n%d live, %d failed, %d without HTTP\n", len(r.results), r.Failed(), r.nonHTTP)
+
+	fmt.Fprintln(w, "\n## Status codes\n\n| Status | Count |\n|--------|-------|")
+	for _, code := range r.sortedStatuses() {
+		fmt.Fprintf(w, "| %d | %d |\n", code, r.statuses[code])
+	}
+	for _, section := range r.topSections(n) {
+		fmt.Fprintf(w, "\n## Top %s\n\n| Value | Count |\n|-------|-------|\n", section.Name)
+		for _, value := range section.Values {
+			fmt.Fprintf(w, "| %s | %d |\n", markdownCell(value.Value), value.Count)
+		}
+	}
+
+	fmt.Fprintln(w, "\n## Findings\n\n| URL | Finding | Detail |\n|-----|---------|--------|")
+	for _, finding := range r.findings {
+		fmt.Fprintf(w, "| %s | %s | %s |\n", markdownCell(finding.URL), finding.Kind, markdownCell(finding.Detail))
+	}
+
+	fmt.Fprintln(w, "\n## Hosts\n\n| URL | Status | Title | Server |\n|-----|--------|-------|--------|")
+	for _, result := range r.results {
+		fmt.Fprintf(w, "| %s | %d | %s | %s |\n", markdownCell(result.URL), result.StatusCode, markdownCell(result.Title), markdownCell(result.Server))
+	}
+	return nil
+}
+
+// htmlReport is the template of -format html. Values come from the
+// scanned hosts, html/template escapes them.
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>livedom report</title>
+<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin-bottom:2em}td,th{border:1px solid #ccc;padding:.3em .6em;text-align:left}</style>
+</head><body>
+<h1>livedom report</h1>
+<p>{{len .Hosts}} live, {{.Failed}} failed, {{.NonHTTP}} without HTTP</p>
+<h2>Status codes</h2>
+<table><tr><th>Status</th><th>Count</th></tr>
+{{range .Statuses}}<tr><td>{{.Code}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{range .Top}}<h2>Top {{.Name}}</h2>
+<table><tr><th>Value</th><th>Count</th></tr>
+{{range .Values}}<tr><td>{{.Value}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Findings</h2>
+<table><tr><th>URL</th><th>Finding</th><th>Detail</th></tr>
+{{range .Findings}}<tr><td>{{.URL}}</td><td>{{.Kind}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>
+<h2>Hosts</h2>
+<table><tr><th>URL</th><th>Status</th><th>Title</th><th>Server</th></tr>
+{{range .Hosts}}<tr><td>{{.URL}}</td><td>{{.StatusCode}}</td><td>{{.Title}}</td><td>{{.Server}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+func writeHTMLReport(r *scanReport, w io.Writer, n int) error {
+	type statusCount struct {
+		Code  int
+		Count int64
+	}
+	var statuses []statusCount
+	for _, code := range r.sortedStatuses() {
+		statuses = append(statuses, statusCount{code, r.statuses[code]})
+	}
+	return htmlReport.Execute(w, map[string]any{
+		"Hosts":    r.results,
+		"Failed":   r.Failed(),
+		"NonHTTP":  r.nonHTTP,
+		"Statuses": statuses,
+		"Top":      r.topSections(n),
+		"Findings": r.findings,
+	})
+}
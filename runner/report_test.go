@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const reportInput = `{"schema":"livedom/v1","url":"https://a.example.com","status_code":200,"title":"Admin | Login","server":"nginx/1.18.0","cert_expiry":"expired","well_known":[{"path":"/.git/HEAD","status_code":200,"size":23}]}
+{"schema":"livedom/v1","url":"https://b.example.com","status_code":403,"title":"<script>alert(1)</script>","server":"nginx"}
+{"schema":"livedom/v1","url":"c.example.com","failed":"timeout"}
+{"schema":"livedom/v1","host":"d.example.com","records":{"A":["192.0.2.1"]}}
+{"status_histogram":{"total":2}}
+{"schema":"livedom/v1","oob":{"url":"https://b.example.com","protocol":"dns","remote_address":"198.51.100.7","timestamp":"2026-01-01T00:00:00Z"}}
+`
+
+func TestReadReport(t *testing.T) {
+	report, err := readReport(strings.NewReader(reportInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.results) != 2 || report.Failed() != 1 {
+		t.Errorf("%d results and %d failed, want 2 and 1", len(report.results), report.Failed())
+	}
+	if report.statuses[200] != 1 || report.statuses[403] != 1 {
+		t.Errorf("statuses = %v", report.statuses)
+	}
+	want := []reportFinding{
+		{"https://a.example.com", "certificate expired", ""},
+		{"https://a.example.com", "exposed file", "/.git/HEAD"},
+		{"https://b.example.com", "oob callback", "dns from 198.51.100.7"},
+	}
+	if len(report.findings) != len(want) {
+		t.Fatalf("findings = %+v, want %+v", report.findings, want)
+	}
+	for i := range want {
+		if report.findings[i] != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, report.findings[i], want[i])
+		}
+	}
+	if servers := top(report.top.techs, 1); len(servers) != 1 || servers[0] != (topCount{"nginx", 2}) {
+		t.Errorf("top technologies = %v", servers)
+	}
+}
+
+func TestReportFormats(t *testing.T) {
+	report, err := readReport(strings.NewReader(reportInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, write := range reportFormats {
+		var buf bytes.Buffer
+		if err := write(report, &buf, 5); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		out := buf.String()
+		if !strings.Contains(out, "https://a.example.com") || !strings.Contains(out, "exposed file") {
+			t.Errorf("%s report is missing results:\n%s", format, out)
+		}
+		switch format {
+		case "markdown":
+			if !strings.Contains(out, `Admin \| Login`) {
+				t.Errorf("markdown doesn't escape |:\n%s", out)
+			}
+		case "html":
+			if strings.Contains(out, "<script>alert") {
+				t.Errorf("html doesn't escape titles:\n%s", out)
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package runner
+
+import "github.com/valyala/fasthttp"
+
+// RequestInfo is the request livedom sent for a result, included in JSON
+// output with -include-request so it can be replayed exactly
+type RequestInfo struct {
+	Method  string   `json:"method"`
+	URL     string   `json:"url"`
+	Headers []Header `json:"headers"`
+	Body    string   `json:"body,omitempty"`
+}
+
+// buildRequestInfo captures the final request after all overrides
+func buildRequestInfo(req *fasthttp.Request) *RequestInfo {
+	info := &RequestInfo{
+		Method:  string(req.Header.Method()),
+		URL:     req.URI().String(),
+		Headers: []Header{},
+		Body:    string(req.Body()),
+	}
+	for name, value := range req.Header.All() {
+		info.Headers = append(info.Headers, Header{Name: string(name), Value: string(value)})
+	}
+	return info
+}
@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetryAttempts limits how often a rate-limited probe is requeued
+	maxRetryAttempts = 3
+	// maxRetryAfter is the longest Retry-After we are willing to wait for;
+	// longer delays are reported as a normal 429/503 result
+	maxRetryAfter = 5 * time.Minute
+)
+
+// hostBackoff tracks hosts that asked us to slow down with Retry-After, so
+// every pending probe of that host waits, not just the one that got the 429
+type hostBackoff struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func newHostBackoff() *hostBackoff {
+	return &hostBackoff{until: make(map[string]time.Time)}
+}
+
+// Wait blocks until host may be probed again
+func (b *hostBackoff) Wait(host string) {
+	b.mu.Lock()
+	until := b.until[host]
+	b.mu.Unlock()
+
+	if delay := time.Until(until); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// Delay holds off further probes of host for d
+func (b *hostBackoff) Delay(host string, d time.Duration) {
+	until := time.Now().Add(d)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until.After(b.until[host]) {
+		b.until[host] = until
+	}
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date. It returns 0 if the header is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackruler/livedom/internal/testserver"
+)
+
+func TestRateLimitedResult(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	// The host keeps answering 429, so the probe runs out of attempts and
+	// the last response is reported in full
+	result := scanOne(t, []string{"-honor-retry-after", "-sc", "-ct", "-hash"}, server.URL+"/rate-limited")
+	if result.StatusCode != 429 || result.Failed != "rate-limited" {
+		t.Errorf("got status %d, failed %q, want 429 and rate-limited", result.StatusCode, result.Failed)
+	}
+	if !strings.HasPrefix(result.ContentType, "text/plain") {
+		t.Errorf("content type %q, want text/plain", result.ContentType)
+	}
+	// like any other response, body and all
+	if result.Hash == "" || result.RetryAfter != 0 {
+		t.Errorf("hash %q, retry after %s", result.Hash, result.RetryAfter)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"120", 2 * time.Minute},
+		{" 5 ", 5 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{"", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	if got := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("HTTP date an hour ahead = %s", got)
+	}
+}
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxHops is the highest TTL -hops tries
+const maxHops = 32
+
+// errHopsUnsupported is returned by setTTL where sockets have no TTL option
+var errHopsUnsupported = errors.New("setting the TTL is not supported on this platform")
+
+// measureConnect times a TCP handshake with the host and port of
+// targetURL, apart from any HTTP work, so slow networks can be told from
+// slow applications
+func measureConnect(targetURL string, timeout time.Duration, guard *addressGuard) (time.Duration, error) {
+	addr := targetAddress(targetURL)
+	started := time.Now()
+	conn, err := guardedDialer(timeout, guard).Dial("tcp", addr)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(started)
+	conn.Close()
+	return elapsed, nil
+}
+
+// countHops finds how many hops away the host of targetURL is: the lowest
+// TTL a TCP handshake still completes with. Handshakes with a too small
+// TTL never get an answer, so each attempt only waits a few connect
+// times, and the TTL is found by binary search in about five attempts.
+func countHops(targetURL string, rtt, timeout time.Duration, guard *addressGuard) (int, error) {
+	addr := targetAddress(targetURL)
+	wait := min(max(3*rtt, 100*time.Millisecond), timeout)
+
+	low, high := 1, maxHops
+	if !connectsWithTTL(addr, high, timeout, guard) {
+		return 0, errors.New("no handshake within " + strconv.Itoa(maxHops) + " hops")
+	}
+	for low < high {
+		mid := (low + high) / 2
+		if connectsWithTTL(addr, mid, wait, guard) {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return low, nil
+}
+
+// connectsWithTTL reports whether a TCP handshake with addr completes when
+// the SYN may only cross ttl hops
+func connectsWithTTL(addr string, ttl int, timeout time.Duration, guard *addressGuard) bool {
+	dialer := guardedDialer(timeout, guard)
+	check := dialer.Control
+	dialer.Control = func(network, address string, c syscall.RawConn) error {
+		if err := check(network, address, c); err != nil {
+			return err
+		}
+		return setTTL(network, c, ttl)
+	}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// targetAddress is the host:port a URL connects to
+func targetAddress(targetURL string) string {
+	port := extractPort(targetURL)
+	if port == 0 {
+		port = 80
+		if strings.HasPrefix(targetURL, "https://") {
+			port = 443
+		}
+	}
+	return net.JoinHostPort(extractDomain(targetURL), strconv.Itoa(port))
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package runner
+
+import "syscall"
+
+const hopsSupported = false
+
+// setTTL isn't available without the unix socket options
+func setTTL(network string, c syscall.RawConn, ttl int) error {
+	return errHopsUnsupported
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package runner
+
+import (
+	"strings"
+	"syscall"
+)
+
+const hopsSupported = true
+
+// setTTL limits how many hops packets of the socket may cross
+func setTTL(network string, c syscall.RawConn, ttl int) error {
+	var err error
+	controlErr := c.Control(func(fd uintptr) {
+		if strings.HasSuffix(network, "6") {
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, ttl)
+		} else {
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+		}
+	})
+	if controlErr != nil {
+		return controlErr
+	}
+	return err
+}
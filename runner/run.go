@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Options configure a scan started from Go code with Run or Scan
+type Options struct {
+	// Args are probe flags as on the command line, e.g. -title -ip
+	Args []string
+	// Targets are input lines in any format the command line reads,
+	// instead of -f or another input flag
+	Targets []string
+
+	// OnResult gets each result, OnError each target that failed and
+	// OnProgress the counts after each finished target. They are called
+	// from the probing goroutines, so they must be safe for concurrent use
+	// and return quickly.
+	OnResult   func(Result)
+	OnError    func(target string, err error)
+	OnProgress func(Progress)
+}
+
+// Progress counts the targets of a running scan
+type Progress struct {
+	Targets int64 `json:"targets"` // read from the input so far
+	Done    int64 `json:"done"`    // probed or skipped by -dead-cache
+	Results int64 `json:"results"`
+	Failed  int64 `json:"failed"`
+}
+
+// libraryUnsupported are the flags Run and Scan refuse: they print reports
+// or results other than Result, or don't scan at all
+var libraryUnsupported = []string{
+	"dry-run", "table", "stats", "status-histogram", "top-n",
+	"dns-only", "tcp-only", "udp-probe", "interactsh-server",
+	"version", "update", "up", "list-flags-json", "schema",
+}
+
+// Scan probes the targets of opts and returns when the scan is done.
+// Results only go to the hooks of opts. Cancelling ctx stops the scan from
+// starting new probes; the ones running finish first. Scans may run side
+// by side.
+func Scan(ctx context.Context, opts Options) error {
+	config, err := newLibraryConfig(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return runScan(config)
+}
+
+// Run starts a scan like Scan and sends its results on the first channel,
+// closed when the scan is done. The second channel then gets the error the
+// scan ended with, nil if none. The hooks of opts are called as well.
+// Probes wait for the results to be received, unless ctx is cancelled.
+func Run(ctx context.Context, opts Options) (<-chan Result, <-chan error) {
+	results := make(chan Result, 64)
+	errs := make(chan error, 1)
+
+	onResult := opts.OnResult
+	opts.OnResult = func(result Result) {
+		if onResult != nil {
+			onResult(result)
+		}
+		select {
+		case results <- result:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(errs)
+		err := Scan(ctx, opts)
+		close(results)
+		errs <- err
+	}()
+	return results, errs
+}
+
+// runScan sets up the scan of config, probes its targets and closes its
+// outputs
+func runScan(config *Config) error {
+	closeOutputs, err := setupScan(config)
+	if err != nil {
+		return err
+	}
+	defer closeOutputs()
+	return probeTargets(config)
+}
+
+// newLibraryConfig parses the flags of opts like the probe command, with
+// errors returned instead of printed
+func newLibraryConfig(ctx context.Context, opts Options) (*Config, error) {
+	config := &Config{}
+	fs := probeFlags("probe", config, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(opts.Args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	var unsupported []string
+	fs.Visit(func(f *flag.Flag) {
+		if slices.Contains(libraryUnsupported, f.Name) {
+			unsupported = append(unsupported, "-"+f.Name)
+		}
+	})
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("%s can't be used from Go", strings.Join(unsupported, ", "))
+	}
+
+	hasInput := config.InputFile != "" || config.BurpXML != "" || config.ZAPXML != "" || config.CTDomain != ""
+	switch {
+	case len(opts.Targets) > 0 && hasInput:
+		return nil, errors.New("targets given with an input flag")
+	case len(opts.Targets) == 0 && !hasInput:
+		return nil, errors.New("no targets")
+	}
+
+	// The end of scan reports are for the command line
+	config.Silent = true
+	if err := initConfig(config, fs, opts.Args); err != nil {
+		return nil, err
+	}
+
+	config.ctx = ctx
+	if len(opts.Targets) > 0 {
+		config.targets = opts.Targets
+	}
+	config.onError = opts.OnError
+	config.onProgress = opts.OnProgress
+	config.onResult = opts.OnResult
+	if config.onResult == nil {
+		config.onResult = func(Result) {}
+	}
+	return config, nil
+}
+
+// cancelled tells whether the context of a scan started from Go is done
+func (c *Config) cancelled() bool {
+	return c.ctx != nil && c.ctx.Err() != nil
+}
+
+// progress passes the counts of summary to the OnProgress hook
+func (c *Config) progress(summary *scanSummary) {
+	if c.onProgress == nil {
+		return
+	}
+	c.onProgress(Progress{
+		Targets: summary.targets.Load(),
+		Done:    summary.done.Load(),
+		Results: summary.results.Load(),
+		Failed:  summary.failed.Load(),
+	})
+}
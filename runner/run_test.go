@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hackruler/livedom/internal/testserver"
+)
+
+func TestScanHooks(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	var mu sync.Mutex
+	var results []Result
+	var failed []string
+	var last Progress
+	err := Scan(context.Background(), Options{
+		Args:    []string{"-title", "-timeout", "2s"},
+		Targets: []string{server.URL + "/ok", server.URL + "/redirect", "http://127.0.0.1:1"},
+		OnResult: func(result Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		},
+		OnError: func(target string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, target)
+		},
+		OnProgress: func(progress Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			last = progress
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+	for _, result := range results {
+		if strings.HasSuffix(result.URL, "/ok") && result.Title != testserver.Title {
+			t.Errorf("%s: title %q", result.URL, result.Title)
+		}
+	}
+	if len(failed) != 1 || failed[0] != "http://127.0.0.1:1" {
+		t.Errorf("OnError got %q", failed)
+	}
+	if want := (Progress{Targets: 3, Done: 3, Results: 2, Failed: 1}); last != want {
+		t.Errorf("last progress = %+v, want %+v", last, want)
+	}
+}
+
+func TestRunChannel(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	var hooked atomic.Int64
+	results, errs := Run(context.Background(), Options{
+		Targets:  []string{server.URL + "/ok", server.URL + "/gzip"},
+		OnResult: func(Result) { hooked.Add(1) },
+	})
+	var received int
+	for result := range results {
+		if result.StatusCode != 200 {
+			t.Errorf("%s: status %d", result.URL, result.StatusCode)
+		}
+		received++
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if received != 2 || hooked.Load() != 2 {
+		t.Errorf("received %d results, hooked %d, want 2", received, hooked.Load())
+	}
+}
+
+func TestScanCancelled(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var results atomic.Int64
+	err := Scan(ctx, Options{
+		Targets:  []string{server.URL + "/ok"},
+		OnResult: func(Result) { results.Add(1) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Load() != 0 {
+		t.Errorf("a cancelled scan probed %d targets", results.Load())
+	}
+}
+
+func TestScanOptionErrors(t *testing.T) {
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{Args: []string{"-table"}, Targets: []string{"example.com"}}, "-table can't be used from Go"},
+		{Options{Args: []string{"-no-such-flag"}, Targets: []string{"example.com"}}, "not defined"},
+		{Options{Args: []string{"-f", "hosts.txt"}, Targets: []string{"example.com"}}, "targets given with an input flag"},
+		{Options{}, "no targets"},
+		{Options{Args: []string{"-method", "HEAD", "-hash"}, Targets: []string{"example.com"}}, "-hash"},
+	}
+	for _, tt := range tests {
+		err := Scan(context.Background(), tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Scan(%q) = %v, want an error with %q", tt.opts.Args, err, tt.want)
+		}
+	}
+}
+
+func TestScanSideBySide(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	// A guarded scan running alongside leaves the other one's connections
+	// alone
+	var wg sync.WaitGroup
+	var guarded, open atomic.Int64
+	for _, tt := range []struct {
+		args    []string
+		results *atomic.Int64
+	}{
+		{[]string{"-disallow-private"}, &guarded},
+		{nil, &open},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Scan(context.Background(), Options{
+				Args:     tt.args,
+				Targets:  []string{server.URL + "/slow?delay=100ms", server.URL + "/ok"},
+				OnResult: func(Result) { tt.results.Add(1) },
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if guarded.Load() != 0 || open.Load() != 2 {
+		t.Errorf("%d guarded and %d open results, want 0 and 2", guarded.Load(), open.Load())
+	}
+}
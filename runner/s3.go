@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Store puts objects into a bucket of S3 or a store speaking its API.
+// Credentials, region and endpoint come from the AWS SDK's default chain:
+// the AWS_* variables, shared config files, SSO, or the role of the
+// instance or container.
+type s3Store struct {
+	bucket   string
+	uploader *manager.Uploader
+}
+
+func newS3Store(ctx context.Context, bucket string) (*s3Store, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	// Fail now rather than at the first upload, after the scan
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		return nil, fmt.Errorf("finding AWS credentials: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// MinIO and most other S3-compatible stores don't serve buckets
+		// as subdomains of their endpoint
+		o.UsePathStyle = o.BaseEndpoint != nil
+	})
+	return &s3Store{bucket: bucket, uploader: manager.NewUploader(client)}, nil
+}
+
+// PutFile uploads the file at path as key, in parts if it's large
+func (s *s3Store) PutFile(ctx context.Context, key, path, contentType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        f,
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+func (s *s3Store) Close() error {
+	return nil
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// scanOptions are the settings a submitted scan may pick for itself, on
+// top of the server's probe flags and within its limits
+type scanOptions struct {
+	Threads             int      `json:"threads,omitempty"`
+	Fields              []string `json:"fields,omitempty"`
+	StatusCodes         []int    `json:"status_codes,omitempty"`
+	FilterDefaultHashes bool     `json:"filter_default_hashes,omitempty"`
+	IncludeFailed       bool     `json:"include_failed,omitempty"`
+}
+
+// scanLimits bound what submitted scans may ask for
+type scanLimits struct {
+	threads int // most threads of a scan
+	targets int // most targets of a scan or /probe request, 0 for any number
+}
+
+// scanSettings are scanOptions turned into the probe flags they add and
+// the shaping of the results they ask for
+type scanSettings struct {
+	args        []string
+	fields      []outputField // of the stored results, nil for all
+	statusCodes map[int]bool  // of the results kept, nil for any
+}
+
+// settings checks the options against limits
+func (o *scanOptions) settings(limits scanLimits) (scanSettings, error) {
+	var settings scanSettings
+	if o == nil {
+		return settings, nil
+	}
+
+	if o.Threads != 0 {
+		if o.Threads < 1 || o.Threads > limits.threads {
+			return settings, fmt.Errorf("threads must be 1 to %d", limits.threads)
+		}
+		settings.args = append(settings.args, "-t", strconv.Itoa(o.Threads))
+	}
+	if len(o.Fields) > 0 {
+		fields, err := parseFields(strings.Join(o.Fields, ","))
+		if err != nil {
+			return settings, err
+		}
+		settings.fields = fields
+		// The flags the fields need are turned on too
+		settings.args = append(settings.args, "-fields", strings.Join(o.Fields, ","))
+	}
+	if len(o.StatusCodes) > 0 {
+		settings.statusCodes = make(map[int]bool)
+		for _, code := range o.StatusCodes {
+			if code < 100 || code > 599 {
+				return settings, fmt.Errorf("invalid status code %d", code)
+			}
+			settings.statusCodes[code] = true
+		}
+	}
+	if o.FilterDefaultHashes {
+		settings.args = append(settings.args, "-filter-default-hashes")
+	}
+	if o.IncludeFailed {
+		settings.args = append(settings.args, "-include-failed")
+	}
+	return settings, nil
+}
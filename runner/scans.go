@@ -0,0 +1,257 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// States of a scan job
+const (
+	scanQueued    = "queued"
+	scanRunning   = "running"
+	scanDone      = "done"
+	scanFailed    = "failed"
+	scanCancelled = "cancelled"
+)
+
+// scanJob is a scan submitted to the server. Its results are kept as the
+// JSON lines they'd be on stdout.
+type scanJob struct {
+	id      string
+	targets []string
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// What the submission asked for, and the flags and filters it became
+	options  *scanOptions
+	settings scanSettings
+
+	mu        sync.Mutex
+	state     string
+	err       string
+	submitted time.Time
+	started   time.Time
+	finished  time.Time
+	progress  Progress
+	results   []json.RawMessage
+	changed   chan struct{} // closed and replaced on every update
+}
+
+// scanStatus is a scan job as the API shows it
+type scanStatus struct {
+	ID        string       `json:"id"`
+	State     string       `json:"state"`
+	Targets   int          `json:"targets"`
+	Results   int          `json:"results"`
+	Options   *scanOptions `json:"options,omitempty"`
+	Progress  Progress     `json:"progress"`
+	Error     string       `json:"error,omitempty"`
+	Submitted time.Time    `json:"submitted"`
+	Started   *time.Time   `json:"started,omitempty"`
+	Finished  *time.Time   `json:"finished,omitempty"`
+}
+
+func newScanJob(targets []string, options *scanOptions, settings scanSettings) *scanJob {
+	id := make([]byte, 16)
+	rand.Read(id)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scanJob{
+		id:        hex.EncodeToString(id),
+		targets:   targets,
+		options:   options,
+		settings:  settings,
+		ctx:       ctx,
+		cancel:    cancel,
+		state:     scanQueued,
+		submitted: time.Now(),
+		changed:   make(chan struct{}),
+	}
+}
+
+// update runs f under the lock and wakes up whoever waits for changes
+func (j *scanJob) update(f func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f()
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// Start marks the job running, unless it was cancelled while queued
+func (j *scanJob) Start() bool {
+	started := false
+	j.update(func() {
+		if j.state == scanQueued {
+			j.state, j.started, started = scanRunning, time.Now(), true
+		}
+	})
+	return started
+}
+
+// Cancel stops the job. A queued job is finished right away, a running one
+// once its probes in flight are done.
+func (j *scanJob) Cancel() {
+	j.cancel()
+	j.Skip()
+}
+
+// Skip finishes the job as cancelled if it's still queued, leaving a
+// running one be
+func (j *scanJob) Skip() {
+	j.update(func() {
+		if j.state == scanQueued {
+			j.state, j.finished = scanCancelled, time.Now()
+		}
+	})
+}
+
+// Finish marks the job done, or failed with err
+func (j *scanJob) Finish(state string, err error) {
+	j.update(func() {
+		j.state, j.finished = state, time.Now()
+		if err != nil {
+			j.err = err.Error()
+		}
+	})
+}
+
+// Add stores a result
+func (j *scanJob) Add(result Result) {
+	// Failures asked for with include_failed have no status to filter by
+	if j.settings.statusCodes != nil && result.Failed == "" && !j.settings.statusCodes[result.StatusCode] {
+		return
+	}
+	var data []byte
+	var err error
+	if j.settings.fields != nil {
+		data, err = fieldJSON(result, j.settings.fields)
+	} else {
+		data, err = json.Marshal(result)
+	}
+	if err != nil {
+		slog.Error("encoding JSON", "error", err)
+		return
+	}
+	j.update(func() {
+		j.results = append(j.results, withSchema(data))
+	})
+}
+
+// SetProgress stores the counts of the running scan
+func (j *scanJob) SetProgress(progress Progress) {
+	j.update(func() {
+		// Hooks run concurrently, keep the furthest along
+		if progress.Done >= j.progress.Done {
+			j.progress = progress
+		}
+	})
+}
+
+// Status returns the job as the API shows it
+func (j *scanJob) Status() scanStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := scanStatus{
+		ID:        j.id,
+		State:     j.state,
+		Targets:   len(j.targets),
+		Results:   len(j.results),
+		Options:   j.options,
+		Progress:  j.progress,
+		Error:     j.err,
+		Submitted: j.submitted,
+	}
+	if !j.started.IsZero() {
+		status.Started = &j.started
+	}
+	if !j.finished.IsZero() {
+		status.Finished = &j.finished
+	}
+	return status
+}
+
+// Results returns up to limit results from offset on, whether the job is
+// finished and a channel closed on the next update
+func (j *scanJob) Results(offset, limit int) ([]json.RawMessage, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	offset = min(offset, len(j.results))
+	end := min(offset+limit, len(j.results))
+	finished := j.state != scanQueued && j.state != scanRunning
+	return j.results[offset:end:end], finished, j.changed
+}
+
+// scanRetention bounds the finished jobs the server remembers, with their
+// results
+type scanRetention struct {
+	keep int           // most finished jobs, 0 for any number
+	ttl  time.Duration // how long a job is kept after it finished, 0 for ever
+}
+
+// scanStore holds the server's scan jobs, in submission order
+type scanStore struct {
+	retention scanRetention
+
+	mu    sync.Mutex
+	jobs  map[string]*scanJob
+	order []*scanJob
+}
+
+func newScanStore(retention scanRetention) *scanStore {
+	return &scanStore{retention: retention, jobs: make(map[string]*scanJob)}
+}
+
+// Add stores a new job, forgetting the finished ones past the retention
+func (s *scanStore) Add(job *scanJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict(time.Now())
+	s.jobs[job.id] = job
+	s.order = append(s.order, job)
+}
+
+// evict drops finished jobs older than the ttl, then the oldest finished
+// ones over keep. Queued and running jobs stay.
+func (s *scanStore) evict(now time.Time) {
+	finished := 0
+	for i := len(s.order) - 1; i >= 0; i-- {
+		status := s.order[i].Status()
+		if status.Finished == nil {
+			continue
+		}
+		finished++
+		expired := s.retention.ttl > 0 && now.Sub(*status.Finished) > s.retention.ttl
+		if expired || s.retention.keep > 0 && finished > s.retention.keep {
+			delete(s.jobs, status.ID)
+			s.order = slices.Delete(s.order, i, i+1)
+		}
+	}
+}
+
+// Remove drops the job with id
+func (s *scanStore) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	s.order = slices.DeleteFunc(s.order, func(job *scanJob) bool { return job.id == id })
+}
+
+// Get returns the job with id, or nil
+func (s *scanStore) Get(id string) *scanJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+// List returns every job, in submission order
+func (s *scanStore) List() []*scanJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.order)
+}
@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaVersion names the layout of -json output lines. Within a version
+// fields are only ever added; removing, renaming or retyping one means a
+// new version.
+const schemaVersion = "livedom/v1"
+
+// schemaLines are the kinds of line -json writes to stdout
+var schemaLines = []struct {
+	name        string
+	description string
+	value       any
+}{
+	{"result", "An HTTP result. Output with -fields has a schema of its own, print it with the same -fields.", Result{}},
+	{"dns", "A -dns-only or 'livedom dns' result", DNSResult{}},
+	{"tcp", "A -tcp-only result", PortResult{}},
+	{"udp", "A -udp-probe result", UDPResult{}},
+	{"oob", "An out-of-band interaction seen by interactsh", struct {
+		OOB OOBHit `json:"oob"`
+	}{}},
+}
+
+// withSchema adds the schema key in front of an encoded JSON object
+func withSchema(data []byte) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"schema":%q`, schemaVersion)
+	if data[1] != '}' {
+		buf.WriteByte(',')
+	}
+	buf.Write(data[1:])
+	return buf.Bytes()
+}
+
+// runSchema is the schema command
+func runSchema(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	selected := fs.String("fields", "", "Describe the output of these -fields instead of full results")
+	fs.Parse(args)
+	printSchema(*selected)
+}
+
+// printSchema writes the JSON Schema of -json output lines to stdout, for
+// the output of selected -fields if given
+func printSchema(selected string) {
+	var fields []outputField
+	if selected != "" {
+		var err error
+		if fields, err = parseFields(selected); err != nil {
+			fatal("parsing -fields", err)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(schemaDocument(fields))
+}
+
+// schemaDocument is the JSON Schema of -json output lines. With fields,
+// results are described the way fieldJSON writes them.
+func schemaDocument(fields []outputField) map[string]any {
+	defs := make(map[string]any, len(schemaLines))
+	var lines []any
+	for _, line := range schemaLines {
+		def := typeSchema(reflect.TypeOf(line.value))
+		if line.name == "result" && fields != nil {
+			def = fieldsSchema(def, fields)
+		}
+		def["description"] = line.description
+		def["properties"].(map[string]any)["schema"] = map[string]any{"const": schemaVersion}
+		def["required"] = append([]string{"schema"}, def["required"].([]string)...)
+		defs[line.name] = def
+		lines = append(lines, map[string]any{"$ref": "#/$defs/" + line.name})
+	}
+
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         schemaVersion,
+		"title":       "livedom -json output line",
+		"description": "Fields are only added within a schema version, never removed, renamed or retyped.",
+		"anyOf":       lines,
+		"$defs":       defs,
+	}
+}
+
+// fieldsSchema narrows the schema of full results to the keys of fields.
+// fieldJSON writes every one of them, null when it has no value.
+func fieldsSchema(result map[string]any, fields []outputField) map[string]any {
+	all := result["properties"].(map[string]any)
+	properties := make(map[string]any)
+	required := []string{}
+	for _, field := range fields {
+		for _, key := range field.keys {
+			properties[key] = map[string]any{"anyOf": []any{all[key], map[string]any{"type": "null"}}}
+			required = append(required, key)
+		}
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": required, "additionalProperties": false}
+}
+
+// typeSchema describes t the way encoding/json encodes it. Struct fields
+// without omitempty are required.
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	}
+	return map[string]any{}
+}
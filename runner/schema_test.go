@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// -fields output must validate against the schema printed for the same
+// -fields: every key present, null allowed, nothing else
+func TestSchemaFields(t *testing.T) {
+	fields, err := parseFields("title,status,ip,url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := schemaDocument(fields)["$defs"].(map[string]any)["result"].(map[string]any)
+	properties := result["properties"].(map[string]any)
+	required := result["required"].([]string)
+
+	data, err := fieldJSON(Result{URL: "https://example.com", StatusCode: 200}, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = withSchema(data)
+	var line map[string]any
+	if err := json.Unmarshal(data, &line); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range line {
+		property, ok := properties[key]
+		if !ok {
+			t.Errorf("%s isn't in the schema", key)
+			continue
+		}
+		if value == nil && !allowsNull(property) {
+			t.Errorf("%s is null, the schema doesn't allow it", key)
+		}
+	}
+	for _, key := range required {
+		if _, ok := line[key]; !ok {
+			t.Errorf("required %s is missing from %s", key, data)
+		}
+	}
+	if len(required) != len(line) {
+		t.Errorf("required = %v, output has %s", required, data)
+	}
+	if result["additionalProperties"] != false {
+		t.Error("the -fields schema allows other keys")
+	}
+}
+
+// Without -fields only keys that are always written are required
+func TestSchemaFullResult(t *testing.T) {
+	result := schemaDocument(nil)["$defs"].(map[string]any)["result"].(map[string]any)
+	data, err := json.Marshal(Result{URL: "https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = withSchema(data)
+	var line map[string]any
+	if err := json.Unmarshal(data, &line); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range result["required"].([]string) {
+		if _, ok := line[key]; !ok {
+			t.Errorf("required %s is missing from %s", key, data)
+		}
+	}
+	if !slices.Contains(result["required"].([]string), "url") {
+		t.Error("url isn't required")
+	}
+}
+
+func allowsNull(property any) bool {
+	for _, option := range property.(map[string]any)["anyOf"].([]any) {
+		if option.(map[string]any)["type"] == "null" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// parseScope parses a comma-separated list of in-scope domains
+func parseScope(s string) []string {
+	var scope []string
+	for _, domain := range strings.Split(s, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		domain = strings.TrimPrefix(domain, "*.")
+		if domain != "" {
+			scope = append(scope, domain)
+		}
+	}
+	return scope
+}
+
+// inScope reports whether a discovered host may be probed. With an explicit
+// -scope the host must be one of those domains or a subdomain of one;
+// otherwise it must share the registrable domain of the host it came from.
+func inScope(host, origin string, scope []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" || strings.HasPrefix(host, "*.") {
+		return false
+	}
+
+	if len(scope) > 0 {
+		for _, domain := range scope {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return apexDomain(host) != "" && apexDomain(host) == apexDomain(origin)
+}
+
+// apexDomain returns the registrable domain (eTLD+1) of a host, e.g.
+// "api.dev.example.co.uk" -> "example.co.uk"
+func apexDomain(host string) string {
+	apex, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return ""
+	}
+	return apex
+}
+
+// targetSet records targets that were already queued so discovered hosts
+// are probed only once
+type targetSet struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newTargetSet() *targetSet {
+	return &targetSet{seen: make(map[string]struct{})}
+}
+
+// Add returns true if the target wasn't seen before
+func (s *targetSet) Add(target string) bool {
+	target = strings.ToLower(target)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[target]; ok {
+		return false
+	}
+	s.seen[target] = struct{}{}
+	return true
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+)
+
+// secretPattern is a named regular expression for a well-known credential
+// format. If the expression has a capture group, the first group is the
+// secret itself and the rest of the match is context.
+type secretPattern struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var secretPatterns = []secretPattern{
+	{"aws-access-key", regexp.MustCompile(`\b((?:AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[A-Z0-9]{16})\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws.{0,20}(?:secret|private).{0,20}['"]([0-9a-zA-Z/+]{40})['"]`)},
+	{"google-api-key", regexp.MustCompile(`\b(AIza[0-9A-Za-z\-_]{35})\b`)},
+	{"jwt", regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`)},
+	{"github-token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36})\b`)},
+	{"slack-token", regexp.MustCompile(`\b(xox[abprs]-[0-9A-Za-z-]{10,})`)},
+	{"private-key", regexp.MustCompile(`(-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP )?PRIVATE KEY(?: BLOCK)?-----)`)},
+}
+
+// scanSecrets returns unique "name:value" matches of the built-in secret
+// patterns in body, with values masked when redact is set
+func scanSecrets(body []byte, redact bool) []string {
+	seen := make(map[string]bool)
+	var found []string
+
+	for _, sp := range secretPatterns {
+		for _, match := range sp.pattern.FindAllSubmatch(body, -1) {
+			value := string(match[0])
+			if len(match) > 1 {
+				value = string(match[1])
+			}
+			if redact && sp.name != "private-key" {
+				value = redactSecret(value)
+			}
+
+			finding := sp.name + ":" + value
+			if !seen[finding] {
+				seen[finding] = true
+				found = append(found, finding)
+			}
+		}
+	}
+
+	return found
+}
+
+// redactSecret keeps only the first and last four characters of a secret
+func redactSecret(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", len(value)-8) + value[len(value)-4:]
+}
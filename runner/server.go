@@ -0,0 +1,508 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	maxSubmitSize      = 16 << 20 // request body of a scan submission
+	maxQueuedScans     = 100
+	defaultResultsPage = 100
+	maxResultsPage     = 1000
+)
+
+// scanServer is "livedom server": an HTTP API probing the targets posted
+// to it with the probe flags the server was started with
+type scanServer struct {
+	args   []string   // probe flags of every scan
+	limits scanLimits // on the options of submitted scans
+	tokens apiTokens  // nil leaves the API open
+	scans  *scanStore
+	queue  chan *scanJob
+
+	// Held by the running scan or /probe request, one runs at a time
+	scanMu sync.Mutex
+
+	// Set on SIGTERM: new scans are refused and queued ones cancelled
+	draining atomic.Bool
+}
+
+// scanRequest is the body of POST /scan
+type scanRequest struct {
+	Targets []string     `json:"targets"`
+	Options *scanOptions `json:"options"`
+}
+
+// scanResults is a page of GET /scan/{id}/results
+type scanResults struct {
+	Results []json.RawMessage `json:"results"`
+	Offset  int               `json:"offset"`
+	Next    int               `json:"next"`
+	State   string            `json:"state"`
+}
+
+// runServer implements "livedom server"
+func runServer(args []string) {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to serve the API on")
+	web := fs.String("web", "", "Also serve a web UI, with the API, on this address (e.g. :8081)")
+	tokensPath := fs.String("tokens", "", "File of API tokens and their scopes (default: $LIVEDOM_TOKENS, none leaves the API open)")
+	maxThreads := fs.Int("max-threads", 0, "Most threads a submitted scan may ask for (default: the -t of the probe flags)")
+	maxTargets := fs.Int("max-targets", 0, "Most targets a submitted scan or /probe request may have (0 = no limit)")
+	drainTimeout := fs.Duration("drain-timeout", 0, "On SIGTERM, cancel the running scan if it takes longer than this to finish (0 = wait for it)")
+	keepScans := fs.Int("keep-scans", 100, "Forget all but the newest N finished scans and their results (0 = keep all)")
+	forgetAfter := fs.Duration("forget-after", 24*time.Hour, "Forget finished scans and their results this long after they finish (0 = keep them)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: livedom server [flags] [-- probe flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *maxThreads < 0 || *maxTargets < 0 || *keepScans < 0 || *forgetAfter < 0 {
+		fatal("parsing flags", errors.New("-max-threads, -max-targets, -keep-scans and -forget-after can't be negative"))
+	}
+	server, err := newScanServer(fs.Args(), scanRetention{keep: *keepScans, ttl: *forgetAfter})
+	if err != nil {
+		fatal("parsing probe flags", err)
+	}
+	if *maxThreads > 0 {
+		server.limits.threads = *maxThreads
+	}
+	server.limits.targets = *maxTargets
+	if server.tokens, err = loadAPITokens(*tokensPath); err != nil {
+		fatal("loading API tokens", err)
+	}
+	if server.tokens == nil && (*web != "" || !loopbackAddress(*listen)) {
+		slog.Warn("the API is open to anyone who can reach it, set -tokens to require tokens")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	servers := []*http.Server{{Addr: *listen, Handler: server.Handler()}}
+	slog.Info("serving the scan API", "addr", *listen)
+	if *web != "" {
+		servers = append(servers, &http.Server{Addr: *web, Handler: server.WebHandler()})
+		slog.Info("serving the web UI", "addr", *web)
+	}
+	for _, srv := range servers {
+		go func() {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				fatal("serving", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stop() // a second signal kills at once
+	slog.Info("draining: refusing new scans and finishing the running one")
+	drainCtx := context.Background()
+	if *drainTimeout > 0 {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(drainCtx, *drainTimeout)
+		defer cancel()
+	}
+	server.Drain(drainCtx)
+	// Result streams of finished scans end on their own
+	for _, srv := range servers {
+		if err := srv.Shutdown(drainCtx); err != nil {
+			srv.Close()
+		}
+	}
+	slog.Info("drained, exiting")
+}
+
+// Drain stops taking work for a shutdown: submissions are refused, /readyz
+// fails, queued scans are cancelled and the running scan or probe may
+// finish until ctx is done, when it's cancelled too
+func (s *scanServer) Drain(ctx context.Context) {
+	s.draining.Store(true)
+	for _, job := range s.scans.List() {
+		job.Skip()
+	}
+
+	idle := make(chan struct{})
+	go func() {
+		s.scanMu.Lock()
+		s.scanMu.Unlock()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		slog.Warn("drain timed out, cancelling the running scan")
+		for _, job := range s.scans.List() {
+			job.Cancel()
+		}
+	}
+}
+
+// newScanServer checks the probe flags of a server before it takes any
+// scans
+func newScanServer(args []string, retention scanRetention) (*scanServer, error) {
+	config, err := checkProbeFlags(args)
+	if err != nil {
+		return nil, err
+	}
+	s := &scanServer{
+		args:   args,
+		limits: scanLimits{threads: config.Threads},
+		scans:  newScanStore(retention),
+		queue:  make(chan *scanJob, maxQueuedScans),
+	}
+	go s.work()
+	return s, nil
+}
+
+// checkProbeFlags parses the probe flags of scans without probing
+func checkProbeFlags(args []string) (*Config, error) {
+	return newLibraryConfig(context.Background(), Options{Args: args, Targets: []string{"localhost"}})
+}
+
+// work runs the queued scans, one at a time
+func (s *scanServer) work() {
+	for job := range s.queue {
+		s.scanMu.Lock()
+		if s.draining.Load() {
+			job.Skip()
+		}
+		if !job.Start() {
+			s.scanMu.Unlock()
+			continue
+		}
+		err := Scan(job.ctx, Options{
+			Args:       slices.Concat(s.args, job.settings.args),
+			Targets:    job.targets,
+			OnResult:   job.Add,
+			OnProgress: job.SetProgress,
+		})
+		switch {
+		case err != nil:
+			slog.Error("scan failed", "id", job.id, "error", err)
+			job.Finish(scanFailed, err)
+		case job.ctx.Err() != nil:
+			job.Finish(scanCancelled, nil)
+		default:
+			job.Finish(scanDone, nil)
+		}
+		s.scanMu.Unlock()
+	}
+}
+
+// Handler serves the API:
+//
+//	POST /probe              probe the targets in the body, one per line,
+//	                         and stream the results back as JSON Lines
+//	POST /scan               queue a scan of {"targets": [...]}, with
+//	                         optional "options" within the server's limits
+//	GET  /scan               every scan, newest first
+//	GET  /scan/{id}          state and progress of a scan
+//	GET  /scan/{id}/results  a page of results, or all of them as they come
+//	                         in with Accept: text/event-stream
+//	DELETE /scan/{id}        cancel a scan and drop it with its results
+//	GET  /stream?scan={id}   a WebSocket pushing the results and progress of
+//	                         a scan as they come in
+//	GET  /healthz            200 while the process is up
+//	GET  /readyz             200 while scans are taken, 503 once draining
+//
+// With tokens, POST needs the submit scope, GET read and DELETE admin; the
+// health checks need none.
+func (s *scanServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	mux.HandleFunc("POST /probe", s.require(scopeSubmit, s.handleProbe))
+	mux.HandleFunc("POST /scan", s.require(scopeSubmit, s.handleSubmit))
+	mux.HandleFunc("GET /scan", s.require(scopeRead, s.handleList))
+	mux.HandleFunc("GET /scan/{id}", s.require(scopeRead, s.handleStatus))
+	mux.HandleFunc("DELETE /scan/{id}", s.require(scopeAdmin, s.handleDelete))
+	mux.HandleFunc("GET /scan/{id}/results", s.require(scopeRead, s.handleResults))
+	mux.HandleFunc("GET /stream", s.require(scopeRead, s.handleStream))
+	return mux
+}
+
+// loopbackAddress tells whether a listen address only takes local
+// connections
+func loopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || ip != nil && ip.IsLoopback()
+}
+
+// writeJSON sends v as the JSON body of a response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *scanServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	io.WriteString(w, "ok\n")
+}
+
+// refuseDraining answers 503 to work submitted while draining
+func (s *scanServer) refuseDraining(w http.ResponseWriter) bool {
+	if !s.draining.Load() {
+		return false
+	}
+	w.Header().Set("Connection", "close")
+	http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+	return true
+}
+
+func (s *scanServer) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if s.refuseDraining(w) {
+		return
+	}
+	var req scanRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSubmitSize))
+	// A misspelled option would silently run the scan without it
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "decoding scan: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var targets []string
+	for _, target := range req.Targets {
+		if target = strings.TrimSpace(target); target != "" {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		http.Error(w, "no targets", http.StatusBadRequest)
+		return
+	}
+	if s.limits.targets > 0 && len(targets) > s.limits.targets {
+		http.Error(w, fmt.Sprintf("at most %d targets per scan", s.limits.targets), http.StatusBadRequest)
+		return
+	}
+	settings, err := req.Options.settings(s.limits)
+	if err == nil && settings.args != nil {
+		// Options may clash with the server's flags
+		_, err = checkProbeFlags(slices.Concat(s.args, settings.args))
+	}
+	if err != nil {
+		http.Error(w, "options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job := newScanJob(targets, req.Options, settings)
+	select {
+	case s.queue <- job:
+	default:
+		http.Error(w, "too many queued scans", http.StatusServiceUnavailable)
+		return
+	}
+	s.scans.Add(job)
+	w.Header().Set("Location", "/scan/"+job.id)
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+func (s *scanServer) handleList(w http.ResponseWriter, r *http.Request) {
+	jobs := s.scans.List()
+	statuses := make([]scanStatus, 0, len(jobs))
+	for _, job := range slices.Backward(jobs) {
+		statuses = append(statuses, job.Status())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *scanServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	job := s.scans.Get(r.PathValue("id"))
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Status())
+}
+
+func (s *scanServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	job := s.scans.Get(r.PathValue("id"))
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+	job.Cancel()
+	s.scans.Remove(job.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *scanServer) handleResults(w http.ResponseWriter, r *http.Request) {
+	job := s.scans.Get(r.PathValue("id"))
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	offset, limit := 0, defaultResultsPage
+	var err error
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			http.Error(w, "bad offset", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxResultsPage {
+			http.Error(w, fmt.Sprintf("limit must be 1 to %d", maxResultsPage), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		// Reconnecting event sources resume after the last result they got
+		if id, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && id >= 0 {
+			offset = id + 1
+		}
+		s.streamResults(w, r, job, offset)
+		return
+	}
+
+	results, _, _ := job.Results(offset, limit)
+	page := scanResults{Results: results, Offset: offset, Next: offset + len(results), State: job.Status().State}
+	if page.Results == nil {
+		page.Results = []json.RawMessage{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// streamResults sends the results of job from offset on as server-sent
+// events, each with its index as ID, then a done event with the final
+// status
+func (s *scanServer) streamResults(w http.ResponseWriter, r *http.Request, job *scanJob, offset int) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	controller := http.NewResponseController(w)
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		results, finished, changed := job.Results(offset, maxResultsPage)
+		for _, result := range results {
+			fmt.Fprintf(w, "id: %d\nevent: result\ndata: %s\n\n", offset, result)
+			offset++
+		}
+		if len(results) == 0 && finished {
+			data, _ := json.Marshal(job.Status())
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+			controller.Flush()
+			return
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+		if len(results) > 0 {
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *scanServer) handleProbe(w http.ResponseWriter, r *http.Request) {
+	if s.refuseDraining(w) {
+		return
+	}
+	targets, err := readSubmittedTargets(http.MaxBytesReader(w, r.Body, maxSubmitSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Refused before waiting for the running scan, not after
+	if s.limits.targets > 0 && len(targets) > s.limits.targets {
+		http.Error(w, fmt.Sprintf("at most %d targets per scan", s.limits.targets), http.StatusBadRequest)
+		return
+	}
+
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	// Draining may have started while waiting for the running scan
+	if s.refuseDraining(w) {
+		return
+	}
+
+	var mu sync.Mutex
+	wrote := false
+	controller := http.NewResponseController(w)
+	err = Scan(r.Context(), Options{
+		Args:    s.args,
+		Targets: targets,
+		OnResult: func(result Result) {
+			data, err := json.Marshal(result)
+			if err != nil {
+				slog.Error("encoding JSON", "error", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !wrote {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				wrote = true
+			}
+			w.Write(append(withSchema(data), '\n'))
+			controller.Flush()
+		},
+	})
+	if err != nil {
+		slog.Error("scan failed", "error", err)
+		if !wrote {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !wrote {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+}
+
+// readSubmittedTargets reads the targets of a submission, one per line
+func readSubmittedTargets(body io.Reader) ([]string, error) {
+	var targets []string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			targets = append(targets, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no targets")
+	}
+	return targets, nil
+}
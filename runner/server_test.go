@@ -0,0 +1,614 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackruler/livedom/internal/testserver"
+	"golang.org/x/net/websocket"
+)
+
+func TestServerProbe(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+
+	server, err := newScanServer([]string{"-title"}, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	body := targets.URL + "/ok\n\n" + targets.URL + "/redirect\n"
+	resp, err := http.Post(api.URL+"/probe", "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("got %s (%s)", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	titles := make(map[string]any)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var result map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result["schema"] != schemaVersion {
+			t.Errorf("line without schema: %s", scanner.Text())
+		}
+		titles[result["url"].(string)] = result["title"]
+	}
+	if len(titles) != 2 || titles[targets.URL+"/ok"] != testserver.Title {
+		t.Errorf("titles = %q", titles)
+	}
+}
+
+func TestServerProbeErrors(t *testing.T) {
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	resp, err := http.Post(api.URL+"/probe", "text/plain", strings.NewReader("\n \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("empty submission got %s", resp.Status)
+	}
+
+	if _, err := newScanServer([]string{"-f", "hosts.txt"}, scanRetention{}); err == nil {
+		t.Error("a server reading -f started")
+	}
+	if _, err := newScanServer([]string{"-table"}, scanRetention{}); err == nil {
+		t.Error("a server with -table started")
+	}
+}
+
+// submitScan queues a scan of targets and returns its ID
+func submitScan(t *testing.T, api string, targets ...string) string {
+	t.Helper()
+	body, _ := json.Marshal(scanRequest{Targets: targets})
+	resp, err := http.Post(api+"/scan", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var status scanStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Location") != "/scan/"+status.ID {
+		t.Fatalf("submitting got %s, Location %q", resp.Status, resp.Header.Get("Location"))
+	}
+	return status.ID
+}
+
+// getJSON decodes the JSON response of a GET
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// waitForScan polls the status of a scan until it's finished
+func waitForScan(t *testing.T, api, id string) scanStatus {
+	t.Helper()
+	for range 100 {
+		var status scanStatus
+		getJSON(t, api+"/scan/"+id, &status)
+		if status.State != scanQueued && status.State != scanRunning {
+			return status
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("scan %s didn't finish", id)
+	return scanStatus{}
+}
+
+func TestServerScanPages(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	id := submitScan(t, api.URL, targets.URL+"/ok", targets.URL+"/gzip", " ", targets.URL+"/redirect")
+	status := waitForScan(t, api.URL, id)
+	if status.State != scanDone || status.Targets != 3 || status.Results != 3 || status.Progress.Done != 3 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	var urls []string
+	offset := 0
+	for page := 0; ; page++ {
+		var results scanResults
+		getJSON(t, fmt.Sprintf("%s/scan/%s/results?offset=%d&limit=2", api.URL, id, offset), &results)
+		if len(results.Results) == 0 {
+			break
+		}
+		for _, line := range results.Results {
+			var result Result
+			json.Unmarshal(line, &result)
+			urls = append(urls, result.URL)
+		}
+		offset = results.Next
+	}
+	if len(urls) != 3 {
+		t.Errorf("paged through %q", urls)
+	}
+
+	if code := getJSON(t, api.URL+"/scan/nope", nil); code != http.StatusNotFound {
+		t.Errorf("unknown scan got %d", code)
+	}
+	if code := getJSON(t, api.URL+"/scan/"+id+"/results?limit=0", nil); code != http.StatusBadRequest {
+		t.Errorf("limit=0 got %d", code)
+	}
+}
+
+func TestServerScanOptions(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer([]string{"-t", "10"}, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.limits.targets = 2
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	submit := func(body string) (*http.Response, scanStatus) {
+		resp, err := http.Post(api.URL+"/scan", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var status scanStatus
+		json.NewDecoder(resp.Body).Decode(&status)
+		return resp, status
+	}
+
+	resp, status := submit(`{"targets": ["` + targets.URL + `/ok", "` + targets.URL + `/missing"],
+		"options": {"threads": 5, "fields": ["url", "title"], "status_codes": [200]}}`)
+	if resp.StatusCode != http.StatusAccepted || status.Options == nil || status.Options.Threads != 5 {
+		t.Fatalf("got %s, %+v", resp.Status, status)
+	}
+	if status = waitForScan(t, api.URL, status.ID); status.State != scanDone || status.Results != 1 {
+		t.Fatalf("status %+v", status)
+	}
+	// Only the 200 is kept, with only the fields asked for; -fields turned
+	// on -title
+	var page scanResults
+	getJSON(t, api.URL+"/scan/"+status.ID+"/results", &page)
+	var result map[string]any
+	json.Unmarshal(page.Results[0], &result)
+	if len(result) != 3 || result["url"] != targets.URL+"/ok" || result["title"] != testserver.Title || result["schema"] != schemaVersion {
+		t.Errorf("result %v", result)
+	}
+
+	// Failed probes are kept past status_codes when asked for
+	resp, status = submit(`{"targets": ["` + targets.URL + `/missing", "http://127.0.0.1:1/"],
+		"options": {"status_codes": [200], "include_failed": true}}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("got %s", resp.Status)
+	}
+	if status = waitForScan(t, api.URL, status.ID); status.State != scanDone || status.Results != 1 {
+		t.Fatalf("status %+v", status)
+	}
+	getJSON(t, api.URL+"/scan/"+status.ID+"/results", &page)
+	json.Unmarshal(page.Results[0], &result)
+	if result["failed"] != "refused" {
+		t.Errorf("result %v", result)
+	}
+
+	for _, body := range []string{
+		`{"targets": ["a.example"], "options": {"threads": 11}}`,
+		`{"targets": ["a.example"], "options": {"threads": -1}}`,
+		`{"targets": ["a.example"], "options": {"fields": ["nope"]}}`,
+		`{"targets": ["a.example"], "options": {"status_codes": [42]}}`,
+		`{"targets": ["a.example"], "options": {"thread": 5}}`,
+		`{"targets": ["a.example", "b.example", "c.example"]}`,
+	} {
+		if resp, _ := submit(body); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s got %s", body, resp.Status)
+		}
+	}
+
+	// A /probe over -max-targets is refused without waiting for the
+	// running scan
+	server.scanMu.Lock()
+	defer server.scanMu.Unlock()
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err = client.Post(api.URL+"/probe", "text/plain", strings.NewReader("a.example\nb.example\nc.example\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("/probe got %s", resp.Status)
+	}
+}
+
+func TestServerScanStream(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	// The slow target keeps the scan running while the stream starts
+	id := submitScan(t, api.URL, targets.URL+"/ok", targets.URL+"/slow?delay=200ms")
+	req, _ := http.NewRequest("GET", api.URL+"/scan/"+id+"/results", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Content-Type %q", resp.Header.Get("Content-Type"))
+	}
+
+	var events []string
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, event)
+		}
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	if strings.Join(events, ",") != "result,result,done" || strings.Join(ids, ",") != "0,1" {
+		t.Errorf("events %q with ids %q", events, ids)
+	}
+
+	// Resuming after the first result only sends the second
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if strings.Count(string(data), "event: result") != 1 || !strings.Contains(string(data), "id: 1\n") {
+		t.Errorf("resumed stream:\n%s", data)
+	}
+}
+
+func TestServerWebSocket(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+	stream := "ws" + strings.TrimPrefix(api.URL, "http") + "/stream?scan="
+
+	id := submitScan(t, api.URL, targets.URL+"/ok", targets.URL+"/slow?delay=200ms")
+	ws, err := websocket.Dial(stream+id, "", api.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	var types []string
+	var indexes []int
+	for {
+		var message streamMessage
+		if err := websocket.JSON.Receive(ws, &message); err != nil {
+			break
+		}
+		types = append(types, message.Type)
+		switch message.Type {
+		case "result":
+			indexes = append(indexes, *message.Index)
+		case "done":
+			if message.Status.State != scanDone || message.Status.Results != 2 {
+				t.Errorf("done with %+v", message.Status)
+			}
+		}
+	}
+	if fmt.Sprint(indexes) != "[0 1]" || types[len(types)-1] != "done" || !slices.Contains(types, "progress") {
+		t.Errorf("got messages %q with indexes %v", types, indexes)
+	}
+
+	// Pages of other sites can't read the results
+	if _, err := websocket.Dial(stream+id, "", "http://example.com"); err == nil {
+		t.Error("cross-origin WebSocket accepted")
+	}
+	if code := getJSON(t, api.URL+"/stream?scan=nope", nil); code != http.StatusNotFound {
+		t.Errorf("unknown scan got %d", code)
+	}
+}
+
+func TestServerWebUI(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := httptest.NewServer(server.WebHandler())
+	defer web.Close()
+
+	resp, err := http.Get(web.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(page), "<title>livedom</title>") {
+		t.Fatalf("UI page %q:\n%.200s", resp.Header.Get("Content-Type"), page)
+	}
+
+	// The page lists the scans through the API served next to it
+	first := submitScan(t, web.URL, targets.URL+"/ok")
+	second := submitScan(t, web.URL, targets.URL+"/gzip")
+	waitForScan(t, web.URL, second)
+	var scans []scanStatus
+	getJSON(t, web.URL+"/scan", &scans)
+	if len(scans) != 2 || scans[0].ID != second || scans[1].ID != first {
+		t.Errorf("scans = %+v", scans)
+	}
+}
+
+func TestServerTokens(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.tokens, err = parseAPITokens("# CI\nci-token submit\nboard-token read; root-token admin\n"); err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	call := func(method, path, token string) int {
+		t.Helper()
+		body := strings.NewReader(`{"targets":["` + targets.URL + `/ok"]}`)
+		req, _ := http.NewRequest(method, api.URL+path, body)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"POST", "/scan", "", http.StatusUnauthorized},
+		{"POST", "/scan", "wrong", http.StatusUnauthorized},
+		{"POST", "/scan", "board-token", http.StatusForbidden},
+		{"POST", "/scan", "ci-token", http.StatusAccepted},
+		{"GET", "/scan", "ci-token", http.StatusForbidden},
+		{"GET", "/scan", "board-token", http.StatusOK},
+		{"GET", "/scan", "root-token", http.StatusOK},
+		{"GET", "/scan?access_token=board-token", "", http.StatusOK},
+		{"DELETE", "/scan/nope", "board-token", http.StatusForbidden},
+		{"DELETE", "/scan/nope", "root-token", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if got := call(test.method, test.path, test.token); got != test.want {
+			t.Errorf("%s %s with %q = %d, want %d", test.method, test.path, test.token, got, test.want)
+		}
+	}
+
+	for _, bad := range []string{"# nothing", "token", "token write", "token read\ntoken submit"} {
+		if _, err := parseAPITokens(bad); err == nil {
+			t.Errorf("tokens %q parsed", bad)
+		}
+	}
+}
+
+func TestServerDelete(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	// The slow scan holds the queued one back until it's cancelled
+	running := submitScan(t, api.URL, targets.URL+"/slow?delay=300ms")
+	queued := submitScan(t, api.URL, targets.URL+"/ok")
+	queuedJob := server.scans.Get(queued)
+	for _, id := range []string{queued, running} {
+		req, _ := http.NewRequest("DELETE", api.URL+"/scan/"+id, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("deleting got %s", resp.Status)
+		}
+	}
+	if status := queuedJob.Status(); status.State != scanCancelled || status.Started != nil {
+		t.Errorf("queued scan %+v", status)
+	}
+	if code := getJSON(t, api.URL+"/scan/"+running, nil); code != http.StatusNotFound {
+		t.Errorf("deleted scan got %d", code)
+	}
+}
+
+func TestServerDrain(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	status := func(method, path string) int {
+		req, _ := http.NewRequest(method, api.URL+path, strings.NewReader(`{"targets": ["`+targets.URL+`/ok"]}`))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := status("GET", "/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz before draining got %d", code)
+	}
+
+	running := submitScan(t, api.URL, targets.URL+"/slow?delay=300ms")
+	queued := submitScan(t, api.URL, targets.URL+"/ok")
+	for server.scans.Get(running).Status().State == scanQueued {
+		time.Sleep(10 * time.Millisecond)
+	}
+	drained := make(chan struct{})
+	go func() {
+		server.Drain(context.Background())
+		close(drained)
+	}()
+	for status("GET", "/readyz") != http.StatusServiceUnavailable {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Still alive, but taking no new work
+	if code := status("GET", "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz got %d", code)
+	}
+	for _, path := range []string{"/scan", "/probe"} {
+		if code := status("POST", path); code != http.StatusServiceUnavailable {
+			t.Errorf("POST %s got %d", path, code)
+		}
+	}
+
+	<-drained
+	if status := server.scans.Get(running).Status(); status.State != scanDone || status.Results != 1 {
+		t.Errorf("running scan %+v", status)
+	}
+	if status := server.scans.Get(queued).Status(); status.State != scanCancelled {
+		t.Errorf("queued scan %+v", status)
+	}
+}
+
+func TestServerDrainTimeout(t *testing.T) {
+	targets := testserver.New()
+	defer targets.Close()
+	server, err := newScanServer(nil, scanRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := httptest.NewServer(server.Handler())
+	defer api.Close()
+
+	id := submitScan(t, api.URL, targets.URL+"/slow?delay=1s")
+	for server.scans.Get(id).Status().State == scanQueued {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	server.Drain(ctx)
+	// Probes in flight still get their answer, the scan ends cancelled
+	if status := waitForScan(t, api.URL, id); status.State != scanCancelled {
+		t.Errorf("scan %+v", status)
+	}
+}
+
+func TestScanStoreRetention(t *testing.T) {
+	// Jobs by name: finished this long ago, or still running when negative
+	jobs := []struct {
+		name string
+		age  time.Duration
+	}{
+		{"oldest", 3 * time.Hour},
+		{"running", -1},
+		{"old", 2 * time.Hour},
+		{"recent", 10 * time.Minute},
+		{"newest", time.Minute},
+	}
+	tests := []struct {
+		name      string
+		retention scanRetention
+		kept      []string
+	}{
+		{"keep all", scanRetention{}, []string{"oldest", "running", "old", "recent", "newest", "added"}},
+		{"keep", scanRetention{keep: 2}, []string{"running", "recent", "newest", "added"}},
+		{"ttl", scanRetention{ttl: time.Hour}, []string{"running", "recent", "newest", "added"}},
+		{"keep and ttl", scanRetention{keep: 1, ttl: 150 * time.Minute}, []string{"running", "newest", "added"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newScanStore(tt.retention)
+			names := map[string]string{}
+			add := func(name string) *scanJob {
+				job := newScanJob(nil, nil, scanSettings{})
+				names[job.id] = name
+				store.Add(job)
+				return job
+			}
+			for _, j := range jobs {
+				job := add(j.name)
+				if j.age >= 0 {
+					job.Finish(scanDone, nil)
+					job.finished = time.Now().Add(-j.age)
+				} else {
+					job.Start()
+				}
+			}
+			add("added")
+
+			var kept []string
+			for _, job := range store.List() {
+				kept = append(kept, names[job.id])
+				if store.Get(job.id) != job {
+					t.Errorf("%s listed but not found", names[job.id])
+				}
+			}
+			if !slices.Equal(kept, tt.kept) {
+				t.Errorf("kept %q, want %q", kept, tt.kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+// speaksTLS does a quick TLS handshake with host:port to tell whether
+// HTTPS is worth a full probe. A refused or timed out connection, or a
+// reply that isn't TLS, means no; a handshake that fails on our offer
+// (an alert from the server) still means yes.
+func speaksTLS(host string, port int, timeout time.Duration, guard *addressGuard) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dialer := &tls.Dialer{
+		NetDialer: guardedDialer(0, guard),
+		Config:    &tls.Config{ServerName: host, InsecureSkipVerify: true},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err == nil {
+		conn.Close()
+		return true
+	}
+
+	var alert tls.AlertError
+	return errors.As(err, &alert)
+}
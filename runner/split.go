@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxSplitFiles is how many -split-output-by files are kept open at once;
+// splitting by apex can produce thousands of files
+const maxSplitFiles = 128
+
+// splitWriter writes each result to a file named after its apex domain,
+// status code or technology, e.g. out/403.txt
+type splitWriter struct {
+	mu      sync.Mutex
+	dir     string
+	by      string
+	open    map[string]*splitFile
+	created map[string]bool
+}
+
+type splitFile struct {
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func newSplitWriter(by, dir string) (*splitWriter, error) {
+	switch by {
+	case "apex", "status", "tech":
+	default:
+		return nil, fmt.Errorf("unknown split %q, expected apex, status or tech", by)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &splitWriter{
+		dir:     dir,
+		by:      by,
+		open:    make(map[string]*splitFile),
+		created: make(map[string]bool),
+	}, nil
+}
+
+// Write appends result to its file, as plain text or JSON with -json
+func (w *splitWriter) Write(result Result, config *Config) error {
+	var line string
+	if config.fields != nil {
+		if config.JSONOutput {
+			data, err := fieldJSON(result, config.fields)
+			if err != nil {
+				return err
+			}
+			line = string(withSchema(data))
+		} else {
+			line = fieldLine(fieldColumns(result, config), false)
+		}
+	} else if config.JSONOutput {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		line = string(withSchema(data))
+	} else {
+		line = plainLine(result.URL, resultColumns(result, config))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	f, err := w.file(splitFileName(w.splitKey(result)))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f.writer, line)
+	return err
+}
+
+// splitKey returns the group of a result
+func (w *splitWriter) splitKey(result Result) string {
+	switch w.by {
+	case "status":
+		if result.StatusCode == 0 {
+			return "none"
+		}
+		return strconv.Itoa(result.StatusCode)
+	case "tech":
+		// The generator is more specific than the web server
+		if result.Meta != nil && result.Meta.Generator != "" {
+			return strings.Fields(result.Meta.Generator)[0]
+		}
+		if product := serverProduct(result.Server); product != "" {
+			return product
+		}
+		return "unknown"
+	default:
+		host := extractDomain(result.URL)
+		if net.ParseIP(host) != nil {
+			return host
+		}
+		if apex := apexDomain(host); apex != "" {
+			return apex
+		}
+		return host
+	}
+}
+
+// file returns the open file for name, creating it on first use and
+// reopening it for appending if it was closed to stay under maxSplitFiles
+func (w *splitWriter) file(name string) (*splitFile, error) {
+	if f, ok := w.open[name]; ok {
+		return f, nil
+	}
+
+	if len(w.open) >= maxSplitFiles {
+		for other, f := range w.open {
+			f.writer.Flush()
+			f.file.Close()
+			delete(w.open, other)
+			break
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if w.created[name] {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(filepath.Join(w.dir, name), flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w.created[name] = true
+
+	f := &splitFile{file: file, writer: bufio.NewWriter(file)}
+	w.open[name] = f
+	return f, nil
+}
+
+func (w *splitWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var firstErr error
+	for name, f := range w.open {
+		if err := f.writer.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := f.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(w.open, name)
+	}
+	return firstErr
+}
+
+// splitFileName turns a group into a safe file name
+func splitFileName(key string) string {
+	return safeFileName(key) + ".txt"
+}
+
+// safeFileName lowercases key and replaces everything but letters,
+// digits, dots, dashes and underscores
+func safeFileName(key string) string {
+	key = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '_'
+		}
+	}, key)
+	key = strings.Trim(key, ".")
+	if key == "" {
+		key = "unknown"
+	}
+	return key
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitKey(t *testing.T) {
+	tests := []struct {
+		by     string
+		result Result
+		want   string
+	}{
+		{"status", Result{StatusCode: 403}, "403"},
+		{"status", Result{}, "none"},
+		{"apex", Result{URL: "https://a.b.example.co.uk:8443/x"}, "example.co.uk"},
+		{"apex", Result{URL: "http://192.0.2.1/"}, "192.0.2.1"},
+		{"tech", Result{Server: "nginx/1.25.3", Meta: &PageMeta{Generator: "WordPress 6.5"}}, "WordPress"},
+		{"tech", Result{Server: "Apache/2.4 (Debian)"}, "Apache"},
+		{"tech", Result{}, "unknown"},
+	}
+	for _, tt := range tests {
+		w := &splitWriter{by: tt.by}
+		if got := w.splitKey(tt.result); got != tt.want {
+			t.Errorf("split by %s of %+v = %q, want %q", tt.by, tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"Example.COM", "example.com"},
+		{"../../etc/passwd", "_.._etc_passwd"},
+		{"Microsoft-IIS/10.0", "microsoft-iis_10.0"},
+		{"...", "unknown"},
+		{"", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := safeFileName(tt.key); got != tt.want {
+			t.Errorf("safeFileName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSplitWriterReopens(t *testing.T) {
+	dir := t.TempDir()
+	w, err := newSplitWriter("status", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// More groups than files kept open, twice over, so files are closed
+	// and appended to again
+	config := &Config{}
+	for range 2 {
+		for status := 100; status < 100+maxSplitFiles+10; status++ {
+			if err := w.Write(Result{URL: fmt.Sprintf("https://a.example/%d", status), StatusCode: status}, config); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != maxSplitFiles+10 {
+		t.Fatalf("%d files: %v", len(entries), err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "100.txt"))
+	if err != nil || strings.Count(string(data), "https://a.example/100") != 2 {
+		t.Errorf("100.txt: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,187 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteTime is how SQLite columns hold times: UTC with a fixed width, so
+// they sort and compare as text
+const sqliteTime = "2006-01-02T15:04:05.000Z"
+
+// sqliteMigrations bring the tables of a SQLite -db up to date, in order;
+// the version of the schema is the number applied. Released migrations
+// never change, new ones are appended.
+var sqliteMigrations = []string{
+	// 1: runs and their results
+	`
+CREATE TABLE livedom_runs (
+	run_id   text PRIMARY KEY,
+	started  text NOT NULL,
+	finished text,
+	scanner  text NOT NULL,
+	args     text NOT NULL
+);
+CREATE INDEX livedom_runs_started ON livedom_runs (started);
+CREATE TABLE livedom_results (
+	run_id      text NOT NULL REFERENCES livedom_runs ON DELETE CASCADE,
+	host        text NOT NULL,
+	port        integer NOT NULL,
+	path        text NOT NULL,
+	url         text NOT NULL,
+	status_code integer,
+	title       text,
+	result      text NOT NULL,
+	seen        text NOT NULL,
+	PRIMARY KEY (host, port, path, run_id)
+);
+CREATE INDEX livedom_results_run_id ON livedom_results (run_id);
+`,
+}
+
+// sqliteStore keeps -db results in a SQLite file
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// openSQLite opens the SQLite file at path, creating it if missing.
+// Transactions take the write lock when they begin, so scanners sharing
+// the file wait for each other instead of failing halfway.
+func openSQLite(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", path+"?_txlock=immediate&_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// One writer at a time either way; one connection spares lock waits
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// migrate applies the migrations the database hasn't had yet, all in one
+// transaction
+func (s *sqliteStore) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS livedom_migrations (version integer PRIMARY KEY, applied text NOT NULL)"); err != nil {
+		return fmt.Errorf("creating the migrations table: %w", err)
+	}
+	var version int
+	if err := tx.QueryRowContext(ctx, "SELECT coalesce(max(version), 0) FROM livedom_migrations").Scan(&version); err != nil {
+		return fmt.Errorf("reading the schema version: %w", err)
+	}
+	if err := checkSchemaVersion(version, len(sqliteMigrations)); err != nil {
+		return err
+	}
+	if version == len(sqliteMigrations) {
+		return nil
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[i]); err != nil {
+			return fmt.Errorf("migrating the schema to version %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO livedom_migrations (version, applied) VALUES (?, ?)", i+1, time.Now().UTC().Format(sqliteTime)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("migrated the database schema", "version", len(sqliteMigrations))
+	return nil
+}
+
+func (s *sqliteStore) startRun(ctx context.Context, runID string, started time.Time, scanner string, args []byte) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO livedom_runs (run_id, started, scanner, args) VALUES (?, ?, ?, ?)
+		ON CONFLICT (run_id) DO UPDATE SET finished = NULL`, runID, started.UTC().Format(sqliteTime), scanner, string(args))
+	return err
+}
+
+// insert stores rows in one transaction, updating the ones the run
+// already has
+func (s *sqliteStore) insert(ctx context.Context, runID string, rows []dbRow) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO livedom_results (run_id, host, port, path, url, status_code, title, result, seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (host, port, path, run_id) DO UPDATE SET url = excluded.url, status_code = excluded.status_code,
+		title = excluded.title, result = excluded.result, seen = excluded.seen`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		var status any
+		if row.status != 0 {
+			status = row.status
+		}
+		if _, err := stmt.ExecContext(ctx, runID, row.host, row.port, row.path, row.url, status, row.title, string(row.result), row.seen.UTC().Format(sqliteTime)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) finishRun(ctx context.Context, runID string, finished time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE livedom_runs SET finished = ? WHERE run_id = ?", finished.UTC().Format(sqliteTime), runID)
+	return err
+}
+
+func (s *sqliteStore) prune(ctx context.Context, runID string, retention dbRetention) (int64, error) {
+	var pruned int64
+	if retention.keepRuns > 0 {
+		res, err := s.db.ExecContext(ctx, `DELETE FROM livedom_runs WHERE run_id <> ? AND run_id IN
+			(SELECT run_id FROM livedom_runs ORDER BY started DESC LIMIT -1 OFFSET ?)`, runID, retention.keepRuns)
+		if err != nil {
+			return pruned, err
+		}
+		n, _ := res.RowsAffected()
+		pruned += n
+	}
+	if retention.pruneOlder > 0 {
+		res, err := s.db.ExecContext(ctx, "DELETE FROM livedom_runs WHERE run_id <> ? AND started < ?", runID, time.Now().Add(-retention.pruneOlder).UTC().Format(sqliteTime))
+		if err != nil {
+			return pruned, err
+		}
+		n, _ := res.RowsAffected()
+		pruned += n
+	}
+	return pruned, nil
+}
+
+// rejected tells constraint and other statement errors from the file
+// being locked by another scanner for longer than the busy timeout
+func (s *sqliteStore) rejected(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return false
+	}
+	return true
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
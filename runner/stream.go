@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/net/websocket"
+)
+
+// streamMessage is a message of the /stream WebSocket: a result with its
+// index, new progress counts, or the final status of the scan
+type streamMessage struct {
+	Type     string          `json:"type"` // result, progress or done
+	Index    *int            `json:"index,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Progress *Progress       `json:"progress,omitempty"`
+	Status   *scanStatus     `json:"status,omitempty"`
+}
+
+// errCrossOrigin refuses WebSockets opened by pages of other sites
+var errCrossOrigin = errors.New("cross-origin WebSocket")
+
+// sameOrigin is the WebSocket handshake check: browsers send the origin of
+// the page, which must be the server itself. Other clients send none.
+func sameOrigin(config *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Host != req.Host {
+		return errCrossOrigin
+	}
+	config.Origin = origin
+	return nil
+}
+
+// handleStream serves GET /stream?scan={id}&offset=0, a WebSocket pushing
+// the results of a scan as they come in
+func (s *scanServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	job := s.scans.Get(r.URL.Query().Get("scan"))
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+	offset := 0
+	if value := r.URL.Query().Get("offset"); value != "" {
+		var err error
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			http.Error(w, "bad offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	websocket.Server{
+		Handshake: sameOrigin,
+		Handler: func(ws *websocket.Conn) {
+			streamSocket(ws, job, offset)
+		},
+	}.ServeHTTP(w, r)
+}
+
+// streamSocket sends the results of job from offset on, progress as it
+// changes and the final status, then closes the socket
+func streamSocket(ws *websocket.Conn, job *scanJob, offset int) {
+	defer ws.Close()
+
+	// Clients don't send anything, reading only notices them leave
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	var sent Progress
+	for {
+		results, finished, changed := job.Results(offset, maxResultsPage)
+		for _, result := range results {
+			index := offset
+			if websocket.JSON.Send(ws, streamMessage{Type: "result", Index: &index, Result: result}) != nil {
+				return
+			}
+			offset++
+		}
+
+		status := job.Status()
+		if status.Progress != sent {
+			sent = status.Progress
+			if websocket.JSON.Send(ws, streamMessage{Type: "progress", Progress: &sent}) != nil {
+				return
+			}
+		}
+		if len(results) == 0 && finished {
+			websocket.JSON.Send(ws, streamMessage{Type: "done", Status: &status})
+			return
+		}
+		if len(results) > 0 {
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-gone:
+			return
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package runner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// scanSummary counts what happened to the targets of a scan
+type scanSummary struct {
+	started time.Time
+
+	targets    atomic.Int64
+	results    atomic.Int64
+	filtered   atomic.Int64
+	failed     atomic.Int64
+	skipped    atomic.Int64 // over -max-per-apex
+	deadCached atomic.Int64 // didn't resolve in an earlier run, see -dead-cache
+	collapsed  atomic.Int64 // wildcard-backed, see -wildcard
+	nonHTTP    atomic.Int64 // no HTTP but resolvable or TCP open, see -include-nonhttp
+	done       atomic.Int64 // probed or dead-cached, for Progress
+
+	certExpired  atomic.Int64
+	certExpiring atomic.Int64
+
+	mu       sync.Mutex
+	errors   map[string]int64 // failures by errorKind
+	statuses map[int]int64    // results by status code
+}
+
+type summaryCounts struct {
+	Targets    int64 `json:"targets"`
+	Results    int64 `json:"results"`
+	Filtered   int64 `json:"filtered"`
+	Failed     int64 `json:"failed"`
+	Skipped    int64 `json:"skipped,omitempty"`
+	DeadCached int64 `json:"dead_cached,omitempty"`
+	Collapsed  int64 `json:"wildcard_collapsed,omitempty"`
+	NonHTTP    int64 `json:"nonhttp,omitempty"`
+
+	CertExpired  int64 `json:"cert_expired,omitempty"`
+	CertExpiring int64 `json:"cert_expiring,omitempty"`
+
+	Errors   map[string]int64 `json:"errors,omitempty"`
+	Statuses map[int]int64    `json:"status_codes,omitempty"`
+}
+
+func newScanSummary() *scanSummary {
+	return &scanSummary{started: time.Now(), errors: make(map[string]int64), statuses: make(map[int]int64)}
+}
+
+// Fail counts a target that didn't answer, by the kind of error
+func (s *scanSummary) Fail(err error) {
+	s.failed.Add(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[errorKind(err)]++
+}
+
+// NonHTTP counts a target that didn't answer HTTP but still exists. Its
+// error goes into the breakdown, it isn't counted as dead.
+func (s *scanSummary) NonHTTP(err error) {
+	s.nonHTTP.Add(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[errorKind(err)]++
+}
+
+// Status counts a result by its status code
+func (s *scanSummary) Status(code int) {
+	if code == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[code]++
+}
+
+func (s *scanSummary) Counts() summaryCounts {
+	s.mu.Lock()
+	errorCounts := make(map[string]int64, len(s.errors))
+	for kind, n := range s.errors {
+		errorCounts[kind] = n
+	}
+	statusCounts := make(map[int]int64, len(s.statuses))
+	for code, n := range s.statuses {
+		statusCounts[code] = n
+	}
+	s.mu.Unlock()
+
+	return summaryCounts{
+		Targets:    s.targets.Load(),
+		Results:    s.results.Load(),
+		Filtered:   s.filtered.Load(),
+		Failed:     s.failed.Load(),
+		Skipped:    s.skipped.Load(),
+		DeadCached: s.deadCached.Load(),
+		Collapsed:  s.collapsed.Load(),
+		NonHTTP:    s.nonHTTP.Load(),
+
+		CertExpired:  s.certExpired.Load(),
+		CertExpiring: s.certExpiring.Load(),
+
+		Errors:   errorCounts,
+		Statuses: statusCounts,
+	}
+}
+
+// Print writes the end of scan summary to stderr, e.g.
+//
+//	Scanned 1000 targets in 1m2s: 640 live, 350 dead, 10 filtered
+//	Errors: 200 timeout, 100 refused, 50 dns
+//	Requests: 2100 (33.9/s)
+func (s *scanSummary) Print(requests int64) {
+	counts := s.Counts()
+	elapsed := time.Since(s.started)
+
+	line := fmt.Sprintf("Scanned %d targets in %s: %d live, %d dead",
+		counts.Targets, elapsed.Round(time.Millisecond), counts.Results, counts.Failed)
+	if counts.NonHTTP > 0 {
+		line += fmt.Sprintf(", %d without HTTP", counts.NonHTTP)
+	}
+	if counts.Filtered > 0 {
+		line += fmt.Sprintf(", %d filtered", counts.Filtered)
+	}
+	if counts.Skipped > 0 {
+		line += fmt.Sprintf(", %d skipped by -max-per-apex", counts.Skipped)
+	}
+	if counts.DeadCached > 0 {
+		line += fmt.Sprintf(", %d skipped by -dead-cache", counts.DeadCached)
+	}
+	if counts.Collapsed > 0 {
+		line += fmt.Sprintf(", %d wildcard collapsed", counts.Collapsed)
+	}
+	fmt.Fprintln(os.Stderr, line)
+
+	if len(counts.Errors) > 0 {
+		kinds := make([]string, 0, len(counts.Errors))
+		for kind := range counts.Errors {
+			kinds = append(kinds, kind)
+		}
+		// Most common first
+		sort.Slice(kinds, func(i, j int) bool {
+			if counts.Errors[kinds[i]] != counts.Errors[kinds[j]] {
+				return counts.Errors[kinds[i]] > counts.Errors[kinds[j]]
+			}
+			return kinds[i] < kinds[j]
+		})
+
+		parts := make([]string, len(kinds))
+		for i, kind := range kinds {
+			parts[i] = fmt.Sprintf("%d %s", counts.Errors[kind], kind)
+		}
+		fmt.Fprintf(os.Stderr, "Errors: %s\n", strings.Join(parts, ", "))
+	}
+
+	if requests > 0 {
+		fmt.Fprintf(os.Stderr, "Requests: %d (%.1f/s)\n", requests, float64(requests)/elapsed.Seconds())
+	}
+}
+
+// errorKind classifies why a target didn't answer
+func errorKind(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+
+	switch {
+	case err == nil:
+		return "no records"
+	case errors.Is(err, fasthttp.ErrTimeout), errors.Is(err, fasthttp.ErrDialTimeout), errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, errAddressBlocked):
+		return "blocked"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, fasthttp.ErrConnectionClosed):
+		return "reset"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "unreachable"
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &recordErr), strings.Contains(err.Error(), "tls:"):
+		return "tls"
+	case strings.HasPrefix(err.Error(), "port "):
+		return "port closed"
+	default:
+		return "other"
+	}
+}
+
+// failureReason is errorKind as a single token, for FAILED:<reason> tags
+// that split on whitespace
+func failureReason(err error) string {
+	return strings.ReplaceAll(errorKind(err), " ", "-")
+}
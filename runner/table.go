@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// tableWriter collects results for -table and prints them as aligned
+// columns with a header once the scan is done
+type tableWriter struct {
+	mu      sync.Mutex
+	keyName string
+	names   []string
+	rows    []tableRow
+}
+
+type tableRow struct {
+	key     string
+	columns map[string]column
+}
+
+func newTableWriter(keyName string) *tableWriter {
+	return &tableWriter{keyName: keyName}
+}
+
+// Add buffers a result. Rows may have different columns (only non-HTTP
+// services have a banner), the table gets the union of them.
+func (t *tableWriter) Add(key string, columns []column) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	row := tableRow{key: key, columns: make(map[string]column, len(columns))}
+	for _, col := range columns {
+		if _, ok := row.columns[col.Name]; !ok && !t.hasColumn(col.Name) {
+			t.names = append(t.names, col.Name)
+		}
+		row.columns[col.Name] = col
+	}
+	t.rows = append(t.rows, row)
+}
+
+func (t *tableWriter) hasColumn(name string) bool {
+	for _, n := range t.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush prints the buffered rows sorted by key
+func (t *tableWriter) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.rows) == 0 {
+		return
+	}
+
+	// Results arrive in completion order, sort them for reading
+	sort.SliceStable(t.rows, func(i, j int) bool { return t.rows[i].key < t.rows[j].key })
+
+	widths := make([]int, len(t.names)+1)
+	widths[0] = displayWidth(t.keyName)
+	for i, name := range t.names {
+		widths[i+1] = displayWidth(name)
+	}
+	for _, row := range t.rows {
+		widths[0] = max(widths[0], displayWidth(row.key))
+		for i, name := range t.names {
+			widths[i+1] = max(widths[i+1], displayWidth(row.columns[name].Value))
+		}
+	}
+
+	bold := color.New(color.Bold).SprintFunc()
+	header := []string{pad(bold(strings.ToUpper(t.keyName)), t.keyName, widths[0])}
+	for i, name := range t.names {
+		header = append(header, pad(bold(strings.ToUpper(name)), name, widths[i+1]))
+	}
+	fmt.Fprintln(color.Output, strings.TrimRight(strings.Join(header, "  "), " "))
+
+	for _, row := range t.rows {
+		cells := []string{pad(row.key, row.key, widths[0])}
+		for i, name := range t.names {
+			col, ok := row.columns[name]
+			if !ok || col.Value == "" {
+				cells = append(cells, pad("-", "-", widths[i+1]))
+				continue
+			}
+			cells = append(cells, pad(col.Color(col.Value), col.Value, widths[i+1]))
+		}
+		fmt.Fprintln(color.Output, strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+}
+
+// pad right-pads a possibly colored cell to width, measured on its plain text
+func pad(cell, plain string, width int) string {
+	return cell + strings.Repeat(" ", max(width-displayWidth(plain), 0))
+}
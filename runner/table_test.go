@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestTableWriter(t *testing.T) {
+	type row struct {
+		key     string
+		columns []column
+	}
+	col := func(name, value string) column { return newColumn(name, value, color.FgGreen) }
+	tests := []struct {
+		name string
+		rows []row
+		want string
+	}{
+		{"aligned", []row{
+			{"https://b.example", []column{col("status", "200"), col("title", "Home")}},
+			{"https://a.example", []column{col("status", "404"), col("title", "Not Found")}},
+		}, "" +
+			"URL                STATUS  TITLE\n" +
+			"https://a.example  404     Not Found\n" +
+			"https://b.example  200     Home\n"},
+		// Columns only some rows have are filled with a dash in the others
+		{"union of columns", []row{
+			{"a.example:22", []column{col("banner", "SSH-2.0-OpenSSH_9.6")}},
+			{"a.example:443", []column{col("status", "200"), col("banner", "")}},
+		}, "" +
+			"URL            BANNER               STATUS\n" +
+			"a.example:22   SSH-2.0-OpenSSH_9.6  -\n" +
+			"a.example:443  -                    200\n"},
+		// Wide characters take two cells
+		{"wide characters", []row{
+			{"https://a.example", []column{col("title", "日本語"), col("status", "200")}},
+			{"https://b.example", []column{col("title", "abc"), col("status", "200")}},
+		}, "" +
+			"URL                TITLE   STATUS\n" +
+			"https://a.example  日本語  200\n" +
+			"https://b.example  abc     200\n"},
+		{"empty", nil, ""},
+	}
+
+	output, noColor := color.Output, color.NoColor
+	defer func() { color.Output, color.NoColor = output, noColor }()
+	color.NoColor = true
+	for _, tt := range tests {
+		var out bytes.Buffer
+		color.Output = &out
+		table := newTableWriter("url")
+		for _, r := range tt.rows {
+			table.Add(r.key, r.columns)
+		}
+		table.Flush()
+		if out.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, out.String(), strings.TrimSuffix(tt.want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Port states reported in TCP-only mode
+const (
+	portOpen     = "open"
+	portClosed   = "closed"
+	portFiltered = "filtered"
+)
+
+// bannerTimeout caps how long we wait for a service to greet us
+const bannerTimeout = 2 * time.Second
+
+// bannerPorts are common non-HTTP service ports. In probe mode targets on
+// these ports get a banner read instead of an HTTP request. Services that
+// wait for the client to speak first get a short probe to elicit a reply.
+var bannerPorts = map[int]string{
+	21:   "", // FTP
+	22:   "", // SSH
+	25:   "", // SMTP
+	3306: "", // MySQL
+	6379: "PING\r\n",
+}
+
+// PortResult holds the outcome of a TCP connect probe
+type PortResult struct {
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	State  string `json:"state"`
+	Banner string `json:"banner,omitempty"`
+
+	Passthrough []string          `json:"passthrough,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// parsePorts parses a comma-separated port list like "80,443,8000-8010"
+func parsePorts(s string) ([]int, error) {
+	var ports []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		start, end := part, part
+		if idx := strings.Index(part, "-"); idx != -1 {
+			start, end = part[:idx], part[idx+1:]
+		}
+
+		low, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		high, err := strconv.Atoi(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		if low < 1 || high > 65535 || low > high {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+
+		for port := low; port <= high; port++ {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// checkTCP attempts a TCP connection to each configured port of a target.
+// A port given in the input (host:8080) takes precedence over -ports.
+func checkTCP(target string, config *Config) []PortResult {
+	host := extractDomain(target)
+	if host == "" {
+		return nil
+	}
+
+	ports := config.ports
+	if port := extractPort(target); port != 0 {
+		ports = []int{port}
+	}
+
+	var results []PortResult
+	for _, port := range ports {
+		results = append(results, probePort(host, port, config.Timeout, config.guard))
+	}
+	return results
+}
+
+// extractPort returns the explicit port of a URL or host:port, or 0 if none
+func extractPort(target string) int {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	parsedURL, err := url.Parse(target)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(parsedURL.Port())
+	return port
+}
+
+func probePort(host string, port int, timeout time.Duration, guard *addressGuard) PortResult {
+	result := PortResult{Host: host, Port: port}
+
+	conn, err := guardedDialer(timeout, guard).Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		result.State = classifyDialError(err)
+		return result
+	}
+	defer conn.Close()
+
+	result.State = portOpen
+	if probe := bannerPorts[port]; probe != "" {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		conn.Write([]byte(probe))
+	}
+	result.Banner = readBanner(conn, timeout)
+	return result
+}
+
+// classifyDialError maps a dial failure to closed (actively refused) or
+// filtered (no answer, typically dropped by a firewall)
+func classifyDialError(err error) string {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return portClosed
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return portFiltered
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return portFiltered
+	}
+	return portClosed
+}
+
+// readBanner reads whatever the service sends first (SSH, FTP, SMTP...)
+func readBanner(conn net.Conn, timeout time.Duration) string {
+	if timeout > bannerTimeout {
+		timeout = bannerTimeout
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	buf := make([]byte, 512)
+	n, _ := conn.Read(buf)
+	return sanitizeBanner(buf[:n])
+}
+
+// sanitizeBanner makes a raw banner safe to print on a single line
+func sanitizeBanner(data []byte) string {
+	var b strings.Builder
+	for _, c := range strings.TrimSpace(string(data)) {
+		switch {
+		case c == '\r' || c == '\n' || c == '\t':
+			b.WriteRune(' ')
+		case c < 32 || c == 127 || c == '�':
+			b.WriteRune('.')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return truncateString(b.String(), 100)
+}
+
+func displayPortResult(result PortResult, config *Config) {
+	if config.JSONOutput {
+		displayJSON(result)
+		return
+	}
+
+	var stateColor color.Attribute
+	switch result.State {
+	case portOpen:
+		stateColor = color.FgGreen
+	case portClosed:
+		stateColor = color.FgRed
+	default:
+		stateColor = color.FgYellow
+	}
+
+	address := net.JoinHostPort(result.Host, strconv.Itoa(result.Port))
+	columns := []column{
+		newColumn("state", result.State, stateColor),
+		newColumn("banner", result.Banner, color.FgBlue),
+	}
+	columns = append(columns, passthroughColumns(result.Passthrough)...)
+	if config.table != nil {
+		config.table.Add(address, columns)
+		return
+	}
+	fmt.Fprintln(color.Output, formatLine(address, columns))
+}
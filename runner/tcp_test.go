@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{"80,443", []int{80, 443}, false},
+		{" 8000-8003 , 22,", []int{8000, 8001, 8002, 8003, 22}, false},
+		{"", nil, false},
+		{"http", nil, true},
+		{"0", nil, true},
+		{"65536", nil, true},
+		{"90-80", nil, true},
+		{"80-", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePorts(tt.value)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("parsePorts(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestExtractPort(t *testing.T) {
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"example.com:8080", 8080},
+		{"https://example.com:8443/path", 8443},
+		{"https://example.com/path", 0},
+		{"[2001:db8::1]:22", 22},
+		{"example.com", 0},
+	}
+	for _, tt := range tests {
+		if got := extractPort(tt.target); got != tt.want {
+			t.Errorf("extractPort(%q) = %d, want %d", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeBanner(t *testing.T) {
+	tests := []struct {
+		data, want string
+	}{
+		{"SSH-2.0-OpenSSH_9.6\r\n", "SSH-2.0-OpenSSH_9.6"},
+		{"220 mail ESMTP\r\n250 ready", "220 mail ESMTP  250 ready"},
+		{"\x00\x01J\x00\x00\x005.7.44", "..J...5.7.44"},
+		{strings.Repeat("a", 150), strings.Repeat("a", 97) + "..."},
+	}
+	for _, tt := range tests {
+		if got := sanitizeBanner([]byte(tt.data)); got != tt.want {
+			t.Errorf("sanitizeBanner(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestProbePort(t *testing.T) {
+	greeting, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer greeting.Close()
+	go func() {
+		for {
+			conn, err := greeting.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-test\r\n"))
+			conn.Close()
+		}
+	}()
+	// A port nothing listens on anymore
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	private, err := newAddressGuard(true, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := func(l net.Listener) int { return l.Addr().(*net.TCPAddr).Port }
+	tests := []struct {
+		name   string
+		port   int
+		guard  *addressGuard
+		state  string
+		banner string
+	}{
+		{"open", port(greeting), nil, portOpen, "SSH-2.0-test"},
+		{"closed", port(closed), nil, portClosed, ""},
+		// Refused by the guard before connecting
+		{"guarded", port(greeting), private, portClosed, ""},
+	}
+	for _, tt := range tests {
+		result := probePort("127.0.0.1", tt.port, time.Second, tt.guard)
+		if result.State != tt.state || result.Banner != tt.banner {
+			t.Errorf("%s: state %q, banner %q", tt.name, result.State, result.Banner)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// titleTextLen is how much visible text the text fallback takes
+const titleTextLen = 80
+
+const (
+	// maxTitleParseBytes caps how much of a document extractTitle reads
+	maxTitleParseBytes = 1024 * 1024
+	// maxTitleTokens caps how many tokens extractTitle looks at before
+	// giving up on finding a <title>
+	maxTitleTokens = 10000
+	// maxTitleTokenSize caps a single token, like a giant attribute or
+	// an unterminated comment
+	maxTitleTokenSize = 64 * 1024
+)
+
+// errTitleTokens is returned when a document has too many tokens before
+// its <title>
+var errTitleTokens = errors.New("too many tokens before the title")
+
+// extractTitle returns the text of the first <title> element. It tokenizes
+// instead of building the whole DOM and stops at </head>. Pathological HTML
+// costs each worker at most maxTitleTokens tokens of maxTitleParseBytes,
+// the same for a document however busy the machine is.
+func extractTitle(body io.Reader) (string, error) {
+	tokenizer := html.NewTokenizer(io.LimitReader(body, maxTitleParseBytes))
+	tokenizer.SetMaxBuf(maxTitleTokenSize)
+
+	for tokens := 0; ; tokens++ {
+		if tokens == maxTitleTokens {
+			return "", errTitleTokens
+		}
+
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return "", err
+			}
+			return "", nil
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "head" {
+				return "", nil
+			}
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); string(name) != "title" {
+				continue
+			}
+			// Title content is text up to </title>
+			var title strings.Builder
+			for tokenizer.Next() == html.TextToken {
+				title.Write(tokenizer.Text())
+			}
+			return strings.TrimSpace(title.String()), nil
+		}
+	}
+}
+
+// titleFallbacks are the -title-fallback sources, in their default order
+var titleFallbacks = []string{"og", "h1", "text"}
+
+// parseTitleFallback parses the comma-separated -title-fallback chain.
+// "none" turns the fallback off.
+func parseTitleFallback(s string) ([]string, error) {
+	var chain []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "" || name == "none":
+			continue
+		case !slices.Contains(titleFallbacks, name):
+			return nil, fmt.Errorf("unknown title fallback %q, use %s or none", name, strings.Join(titleFallbacks, ","))
+		}
+		chain = append(chain, name)
+	}
+	return chain, nil
+}
+
+// titleFallback names a page without a <title> from the first source of
+// chain it has: og:title, the first <h1> or the start of the visible text.
+// It returns the title and the source it came from.
+func titleFallback(body []byte, chain []string) (string, string) {
+	var og, h1, text strings.Builder
+	inH1, h1Done, skip := false, false, 0
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for done := false; !done; {
+		switch tokenType := tokenizer.Next(); tokenType {
+		case html.ErrorToken:
+			done = true
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "meta":
+				if hasAttr && og.Len() == 0 {
+					og.WriteString(ogTitle(tokenizer))
+				}
+			case "h1":
+				inH1 = !h1Done && tokenType == html.StartTagToken
+			case "script", "style", "noscript", "template", "title":
+				if tokenType == html.StartTagToken {
+					skip++
+				}
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "h1":
+				if inH1 {
+					inH1, h1Done = false, true
+				}
+			case "script", "style", "noscript", "template", "title":
+				if skip > 0 {
+					skip--
+				}
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			words := strings.Fields(string(tokenizer.Text()))
+			if len(words) == 0 {
+				continue
+			}
+			if inH1 {
+				appendWords(&h1, words)
+			}
+			if text.Len() < titleTextLen*4 {
+				appendWords(&text, words)
+			}
+		}
+	}
+
+	for _, source := range chain {
+		var title string
+		switch source {
+		case "og":
+			title = strings.TrimSpace(og.String())
+		case "h1":
+			title = h1.String()
+		case "text":
+			title = truncateRunes(text.String(), titleTextLen)
+		}
+		if title != "" {
+			return title, source
+		}
+	}
+	return "", ""
+}
+
+// ogTitle returns the content of a <meta property="og:title"> tag
+func ogTitle(tokenizer *html.Tokenizer) string {
+	var property, content string
+	for {
+		key, value, more := tokenizer.TagAttr()
+		switch string(key) {
+		case "property":
+			property = string(value)
+		case "content":
+			content = string(value)
+		}
+		if !more {
+			break
+		}
+	}
+	if strings.EqualFold(strings.TrimSpace(property), "og:title") {
+		return content
+	}
+	return ""
+}
+
+// appendWords adds words to b, separated by single spaces
+func appendWords(b *strings.Builder, words []string) {
+	for _, word := range words {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(word)
+	}
+}
+
+// truncateRunes cuts s to at most n runes
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n]))
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractTitle(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"<html><head><title> Login </title></head></html>", "Login"},
+		{"<title>a &amp; b</title>", "a & b"},
+		{"<head></head><body><svg><title>icon</title></svg></body>", ""},
+		{"<body>no title</body>", ""},
+	}
+	for _, tt := range tests {
+		if got, err := extractTitle(strings.NewReader(tt.html)); err != nil || got != tt.want {
+			t.Errorf("extractTitle(%q) = %q, %v, want %q", tt.html, got, err, tt.want)
+		}
+	}
+}
+
+func TestExtractTitleLimits(t *testing.T) {
+	// A title behind a long head is still found
+	head := "<head>" + strings.Repeat("<meta>", maxTitleTokens/2) + "<title>late</title></head>"
+	if got, err := extractTitle(strings.NewReader(head)); err != nil || got != "late" {
+		t.Errorf("title after %d tokens = %q, %v", maxTitleTokens/2, got, err)
+	}
+
+	// Pathological nesting gives up after maxTitleTokens, every time
+	nested := strings.Repeat("<div>", maxTitleTokens) + "<title>never</title>"
+	for range 3 {
+		if got, err := extractTitle(strings.NewReader(nested)); err != errTitleTokens || got != "" {
+			t.Errorf("deep nesting = %q, %v, want errTitleTokens", got, err)
+		}
+	}
+
+	// Nothing past maxTitleParseBytes is read
+	big := "<!--" + strings.Repeat("x", maxTitleParseBytes) + "--><title>past the limit</title>"
+	if got, _ := extractTitle(strings.NewReader(big)); got != "" {
+		t.Errorf("title past maxTitleParseBytes = %q", got)
+	}
+}
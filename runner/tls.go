@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fetchCertificates performs a TLS handshake with host:port and returns the
+// peer certificate chain (leaf first). Verification is skipped on purpose:
+// we want to see whatever the server presents, valid or not.
+func fetchCertificates(host string, port int, timeout time.Duration, guard *addressGuard) ([]*x509.Certificate, error) {
+	dialer := guardedDialer(timeout, guard)
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, strconv.Itoa(port)), &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates, nil
+}
+
+// certificateChain returns the chain an HTTPS URL presents, leaf first,
+// or nil
+func certificateChain(targetURL string, timeout time.Duration, guard *addressGuard) []*x509.Certificate {
+	port := extractPort(targetURL)
+	if port == 0 {
+		port = 443
+	}
+
+	certs, err := fetchCertificates(extractDomain(targetURL), port, timeout, guard)
+	if err != nil || len(certs) == 0 {
+		return nil
+	}
+	return certs
+}
+
+// inspectCertificate runs -cert-expiry-warn, -cert-mismatch, -extract-sans
+// and -dump-certs on the chain certURL presents. Its handshake doesn't
+// verify the chain, unlike the probe's, so expired and mismatched
+// certificates are seen even when they failed the probe. It returns the
+// leaf for -origin-hunt, or nil.
+func inspectCertificate(result *Result, certURL, domain string, config *Config) *x509.Certificate {
+	if certURL == "" || !(config.ExtractSANs || config.certExpiryWarn > 0 || config.CertMismatch || config.OriginHunt || config.DumpCerts != "") {
+		return nil
+	}
+	chain := certificateChain(certURL, config.Timeout, config.guard)
+	if chain == nil {
+		return nil
+	}
+	cert := chain[0]
+
+	if config.DumpCerts != "" {
+		path, err := dumpCertificates(config.DumpCerts, certURL, chain)
+		if err != nil {
+			slog.Warn("writing certificate chain", "url", certURL, "error", err)
+		}
+		result.CertFile = path
+	}
+
+	// Flag certificates that expired or expire within the window
+	if config.certExpiryWarn > 0 {
+		notAfter := cert.NotAfter
+		result.CertNotAfter = &notAfter
+		result.CertExpiry = certExpiryStatus(notAfter, config.certExpiryWarn)
+	}
+
+	// Default vhosts, shared infrastructure and dangling DNS present
+	// someone else's certificate
+	if config.CertMismatch && cert.VerifyHostname(domain) != nil {
+		result.CertMismatch = true
+		result.CertName = certificateName(cert)
+	}
+
+	// Harvest certificate SANs, feeding in-scope ones back as new targets
+	if config.ExtractSANs {
+		result.SANs = extractSANs(cert)
+		if config.SANFeedback {
+			for _, san := range result.SANs {
+				if san != domain && inScope(san, domain, config.scope) {
+					config.enqueue(san)
+				}
+			}
+		}
+	}
+	return cert
+}
+
+// extractSANs returns the distinct DNS names of a certificate
+func extractSANs(cert *x509.Certificate) []string {
+	seen := make(map[string]bool)
+	var sans []string
+	for _, name := range cert.DNSNames {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			sans = append(sans, name)
+		}
+	}
+	return sans
+}
+
+// certificateName is the name a certificate is for: its first SAN, or
+// the subject CN of certificates without any
+func certificateName(cert *x509.Certificate) string {
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	if len(cert.IPAddresses) > 0 {
+		return cert.IPAddresses[0].String()
+	}
+	return cert.Subject.CommonName
+}
+
+// parseDays parses a duration that may be given in days, like "30d", as
+// well as anything time.ParseDuration accepts
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// certExpiryStatus describes when a certificate expires relative to the
+// warning window: expired, expiring (within the window) or ok
+func certExpiryStatus(notAfter time.Time, window time.Duration) string {
+	switch remaining := time.Until(notAfter); {
+	case remaining <= 0:
+		return "expired"
+	case remaining <= window:
+		return "expiring"
+	default:
+		return "ok"
+	}
+}
+
+// formatCertExpiry renders the expiry column, e.g. "expiring in 12d"
+func formatCertExpiry(status string, notAfter *time.Time) string {
+	if notAfter == nil {
+		return ""
+	}
+	days := int(time.Until(*notAfter).Hours() / 24)
+	switch status {
+	case "expired":
+		return fmt.Sprintf("expired %dd ago", -days)
+	case "expiring":
+		return fmt.Sprintf("expiring in %dd", days)
+	default:
+		return fmt.Sprintf("ok %dd", days)
+	}
+}
@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hackruler/livedom/internal/testserver"
+)
+
+// tlsServer starts the test server with a self-signed certificate for
+// names, valid until notAfter
+func tlsServer(t *testing.T, notAfter time.Time, names ...string) *httptest.Server {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(testserver.Handler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCertChecksOnRejectedCertificates(t *testing.T) {
+	// Expired and issued for another name: the probe's handshake fails
+	server := tlsServer(t, time.Now().Add(-48*time.Hour), "other.example")
+	args := []string{"-cert-expiry-warn", "30d", "-cert-mismatch", "-extract-sans"}
+
+	t.Run("failed probe", func(t *testing.T) {
+		result := scanOne(t, append(args, "-include-failed"), server.URL+"/ok")
+		if result.Failed != "tls" {
+			t.Fatalf("got failure %q, want tls", result.Failed)
+		}
+		if result.CertExpiry != "expired" {
+			t.Errorf("cert_expiry = %q, want expired", result.CertExpiry)
+		}
+		if !result.CertMismatch || result.CertName != "other.example" {
+			t.Errorf("cert_mismatch = %v (%q), want true (other.example)", result.CertMismatch, result.CertName)
+		}
+		if len(result.SANs) != 1 || result.SANs[0] != "other.example" {
+			t.Errorf("sans = %v", result.SANs)
+		}
+	})
+
+	// HTTPS fails and HTTP answers (Go's 400 to plain HTTP on a TLS port),
+	// the certificate is still checked
+	t.Run("HTTP answered", func(t *testing.T) {
+		result := scanOne(t, args, strings.TrimPrefix(server.URL, "https://"))
+		if !strings.HasPrefix(result.URL, "http://") {
+			t.Fatalf("got %s, want the HTTP URL", result.URL)
+		}
+		if result.CertExpiry != "expired" || !result.CertMismatch {
+			t.Errorf("cert_expiry = %q, cert_mismatch = %v, want expired and true", result.CertExpiry, result.CertMismatch)
+		}
+	})
+}
+
+func TestCertMismatch(t *testing.T) {
+	// Valid for a year, but the SAN doesn't cover 127.0.0.1
+	server := tlsServer(t, time.Now().Add(365*24*time.Hour), "www.example.com")
+	result := scanOne(t, []string{"-cert-mismatch", "-cert-expiry-warn", "30d", "-include-failed"}, server.URL+"/ok")
+	if !result.CertMismatch || result.CertName != "www.example.com" {
+		t.Errorf("cert_mismatch = %v (%q), want true (www.example.com)", result.CertMismatch, result.CertName)
+	}
+	if result.CertExpiry != "ok" {
+		t.Errorf("cert_expiry = %q, want ok", result.CertExpiry)
+	}
+}
+
+func TestCertExpiryStatus(t *testing.T) {
+	window := 30 * 24 * time.Hour
+	tests := []struct {
+		notAfter time.Time
+		want     string
+	}{
+		{time.Now().Add(-time.Hour), "expired"},
+		{time.Now().Add(10 * 24 * time.Hour), "expiring"},
+		{time.Now().Add(90 * 24 * time.Hour), "ok"},
+	}
+	for _, tt := range tests {
+		if got := certExpiryStatus(tt.notAfter, window); got != tt.want {
+			t.Errorf("certExpiryStatus(%s) = %q, want %q", tt.notAfter, got, tt.want)
+		}
+	}
+}
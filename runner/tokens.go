@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Scopes of API tokens. admin covers the other two.
+const (
+	scopeSubmit = "submit"
+	scopeRead   = "read"
+	scopeAdmin  = "admin"
+)
+
+// apiTokens maps the SHA-256 of each API token to its scopes. Looking up
+// the hash doesn't tell how much of a guessed token was right.
+type apiTokens map[[sha256.Size]byte][]string
+
+// loadAPITokens reads the tokens of -tokens, or of $LIVEDOM_TOKENS without
+// it. It returns nil when neither is set.
+func loadAPITokens(path string) (apiTokens, error) {
+	text, source := os.Getenv("LIVEDOM_TOKENS"), "$LIVEDOM_TOKENS"
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text, source = string(data), path
+	}
+	if strings.TrimSpace(text) == "" {
+		if path != "" {
+			return nil, fmt.Errorf("%s: no tokens", path)
+		}
+		return nil, nil
+	}
+
+	tokens, err := parseAPITokens(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return tokens, nil
+}
+
+// parseAPITokens parses entries of a token and its comma-separated scopes,
+// one per line or separated by ";":
+//
+//	# CI submits scans, the dashboard reads them
+//	4f9c0d1e7a2b submit
+//	8a6e2c5d9b1f read
+//	0b7d3f8e1c4a submit,read,admin
+func parseAPITokens(text string) (apiTokens, error) {
+	tokens := make(apiTokens)
+	for _, entry := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ';' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		fields := strings.Fields(entry)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("want a token and its scopes, got %d fields", len(fields))
+		}
+		var scopes []string
+		for _, scope := range strings.Split(fields[1], ",") {
+			switch scope {
+			case scopeSubmit, scopeRead, scopeAdmin:
+				scopes = append(scopes, scope)
+			default:
+				return nil, fmt.Errorf("unknown scope %q, use submit, read or admin", scope)
+			}
+		}
+		key := sha256.Sum256([]byte(fields[0]))
+		if _, ok := tokens[key]; ok {
+			return nil, errors.New("a token is listed twice")
+		}
+		tokens[key] = scopes
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("no tokens")
+	}
+	return tokens, nil
+}
+
+// requestToken returns the bearer token of a request. Browsers can't set
+// headers on WebSockets, so they pass it as access_token.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// require lets requests through to handler when the server has no tokens,
+// or when they carry one with scope
+func (s *scanServer) require(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.tokens != nil {
+			scopes, ok := s.tokens[sha256.Sum256([]byte(requestToken(r)))]
+			switch {
+			case !ok:
+				w.Header().Set("WWW-Authenticate", `Bearer realm="livedom"`)
+				http.Error(w, "missing or unknown API token", http.StatusUnauthorized)
+				return
+			case !slices.Contains(scopes, scope) && !slices.Contains(scopes, scopeAdmin):
+				http.Error(w, "the API token lacks the "+scope+" scope", http.StatusForbidden)
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// topValues counts titles, servers and technologies across results for
+// the -top-n summary
+type topValues struct {
+	mu      sync.Mutex
+	titles  map[string]int64
+	servers map[string]int64
+	techs   map[string]int64
+}
+
+func newTopValues() *topValues {
+	return &topValues{
+		titles:  make(map[string]int64),
+		servers: make(map[string]int64),
+		techs:   make(map[string]int64),
+	}
+}
+
+// Add counts the values of a result, each technology once per result
+func (t *topValues) Add(result Result) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if result.Title != "" {
+		t.titles[result.Title]++
+	}
+	if result.Server != "" {
+		t.servers[result.Server]++
+	}
+	for _, tech := range resultTechnologies(result) {
+		t.techs[tech]++
+	}
+}
+
+// resultTechnologies names what a result runs on: the generator of the
+// page, the web server product and the bot protection in front of it
+func resultTechnologies(result Result) []string {
+	var techs []string
+	if result.Meta != nil && result.Meta.Generator != "" {
+		techs = append(techs, strings.Fields(result.Meta.Generator)[0])
+	}
+	if product := serverProduct(result.Server); product != "" {
+		techs = append(techs, product)
+	}
+	if result.ProtectedBy != "" {
+		techs = append(techs, result.ProtectedBy)
+	}
+	return techs
+}
+
+// serverProduct returns the product of a Server header without its
+// version, e.g. "nginx" for "nginx/1.18.0 (Ubuntu)"
+func serverProduct(server string) string {
+	fields := strings.Fields(server)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.SplitN(fields[0], "/", 2)[0]
+}
+
+// topCount is one line of the -top-n summary
+type topCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// top returns the n most common values, ties in alphabetical order
+func top(counts map[string]int64, n int) []topCount {
+	values := make([]topCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, topCount{value, count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > n {
+		values = values[:n]
+	}
+	return values
+}
+
+// Print writes the n most common titles, servers and technologies to
+// stderr, or one JSON object with -json
+func (t *topValues) Print(n int, jsonOutput bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sections := []struct {
+		name   string
+		counts map[string]int64
+	}{
+		{"titles", t.titles},
+		{"servers", t.servers},
+		{"technologies", t.techs},
+	}
+
+	if jsonOutput {
+		report := make(map[string][]topCount, len(sections))
+		for _, section := range sections {
+			report[section.name] = top(section.counts, n)
+		}
+		data, _ := json.Marshal(map[string]any{"top": report})
+		fmt.Fprintln(os.Stderr, string(data))
+		return
+	}
+
+	for _, section := range sections {
+		fmt.Fprintf(os.Stderr, "Top %s:\n", section.name)
+		values := top(section.counts, n)
+		if len(values) == 0 {
+			fmt.Fprintln(os.Stderr, "  none")
+		}
+		for _, value := range values {
+			fmt.Fprintf(os.Stderr, "  %7d  %s\n", value.Count, value.Value)
+		}
+	}
+}
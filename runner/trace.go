@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxTraceBody is how much of each body -trace prints
+const maxTraceBody = 2048
+
+// tracer dumps the requests and responses of matching hosts to stderr
+type tracer struct {
+	all     bool
+	domains []string
+	mu      sync.Mutex
+}
+
+// newTracer parses -trace: domains whose hosts and subdomains are traced,
+// or "*" for every host
+func newTracer(s string) *tracer {
+	t := &tracer{}
+	for _, domain := range strings.Split(s, ",") {
+		if strings.TrimSpace(domain) == "*" {
+			t.all = true
+		}
+	}
+	t.domains = parseScope(s)
+	return t
+}
+
+// Matches reports whether probes of host are traced. A nil tracer traces
+// nothing.
+func (t *tracer) Matches(host string) bool {
+	if t == nil {
+		return false
+	}
+	if t.all {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, domain := range t.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dump writes one exchange, or the error that ended it, in curl -v style
+func (t *tracer) Dump(req *fasthttp.Request, resp *fasthttp.Response, err error, elapsed time.Duration) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "* %s %s\n", req.Header.Method(), req.URI())
+	writePrefixed(&out, "> ", req.Header.Header())
+	writeBody(&out, "> ", req.Body())
+
+	if err != nil {
+		fmt.Fprintf(&out, "* error after %s: %v\n", elapsed.Round(time.Millisecond), err)
+	} else {
+		writePrefixed(&out, "< ", resp.Header.Header())
+		writeBody(&out, "< ", resp.Body())
+		fmt.Fprintf(&out, "* %d in %s\n", resp.StatusCode(), elapsed.Round(time.Millisecond))
+	}
+	out.WriteString("\n")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	os.Stderr.Write(out.Bytes())
+}
+
+// writePrefixed writes every line of a header block with prefix
+func writePrefixed(out *bytes.Buffer, prefix string, block []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(block))
+	for scanner.Scan() {
+		out.WriteString(prefix + scanner.Text() + "\n")
+	}
+}
+
+func writeBody(out *bytes.Buffer, prefix string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	if isBinary(body) {
+		fmt.Fprintf(out, "%s[%d bytes of binary data]\n", prefix, len(body))
+		return
+	}
+
+	truncated := len(body) > maxTraceBody
+	if truncated {
+		body = body[:maxTraceBody]
+	}
+	writePrefixed(out, prefix, body)
+	if truncated {
+		fmt.Fprintf(out, "%s[truncated to %d bytes]\n", prefix, maxTraceBody)
+	}
+}
+
+// isBinary reports whether body doesn't look like text
+func isBinary(body []byte) bool {
+	if len(body) > maxTraceBody {
+		// Leave room for a rune cut in half
+		body = body[:maxTraceBody-utf8.UTFMax]
+	}
+	return bytes.IndexByte(body, 0) != -1 || !utf8.Valid(body)
+}
@@ -0,0 +1,232 @@
+package runner
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// quicMinInitialSize is the size servers drop smaller Initial packets
+// below (RFC 9000 section 14.1)
+const quicMinInitialSize = 1200
+
+// quicGreaseVersion is a reserved version no server supports, so any QUIC
+// server answers with a Version Negotiation packet (RFC 9000 section 15)
+const quicGreaseVersion = 0x1a2a3a4a
+
+// UDPResult holds the outcome of the -udp-probe checks on one port
+type UDPResult struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	State    string   `json:"state"`
+	Protocol string   `json:"protocol,omitempty"` // quic or dtls
+	Versions []string `json:"versions,omitempty"`
+
+	Passthrough []string          `json:"passthrough,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// checkUDP looks for QUIC and then DTLS on each -udp-ports port of a
+// target. A port given in the input takes precedence, like in -tcp-only.
+func checkUDP(target string, config *Config) []UDPResult {
+	host := extractDomain(target)
+	if host == "" {
+		return nil
+	}
+
+	ports := config.udpPorts
+	if port := extractPort(target); port != 0 {
+		ports = []int{port}
+	}
+
+	var results []UDPResult
+	for _, port := range ports {
+		result := UDPResult{Host: host, Port: port, State: portFiltered}
+		address := net.JoinHostPort(host, strconv.Itoa(port))
+		for _, probe := range []func(string, time.Duration, *addressGuard) (string, []string, error){probeQUIC, probeDTLS} {
+			protocol, versions, err := probe(address, config.Timeout, config.guard)
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				result.State = portClosed
+				break
+			}
+			if protocol != "" {
+				result.State, result.Protocol, result.Versions = portOpen, protocol, versions
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// exchangeDatagram sends a datagram and returns the first one that comes back.
+// An ICMP port unreachable shows up as ECONNREFUSED.
+func exchangeDatagram(address string, packet []byte, timeout time.Duration, guard *addressGuard) ([]byte, error) {
+	conn, err := guardedDialer(timeout, guard).Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// probeQUIC sends an Initial packet with an unsupported version. QUIC
+// servers answer with the versions they do support, without any
+// handshake.
+func probeQUIC(address string, timeout time.Duration, guard *addressGuard) (string, []string, error) {
+	packet := make([]byte, quicMinInitialSize)
+	packet[0] = 0xc0 // long header, fixed bit, Initial
+	binary.BigEndian.PutUint32(packet[1:5], quicGreaseVersion)
+	packet[5] = 8 // destination connection ID
+	rand.Read(packet[6:14])
+	packet[14] = 8 // source connection ID
+	rand.Read(packet[15:23])
+	// The rest is padding, as far as a server that can't parse it knows
+
+	resp, err := exchangeDatagram(address, packet, timeout, guard)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Version Negotiation: long header with version 0, then both
+	// connection IDs and the supported versions
+	if len(resp) < 7 || resp[0]&0x80 == 0 || binary.BigEndian.Uint32(resp[1:5]) != 0 {
+		return "", nil, nil
+	}
+	offset := 5
+	for range 2 {
+		if offset >= len(resp) {
+			return "", nil, nil
+		}
+		offset += 1 + int(resp[offset])
+	}
+	var versions []string
+	for ; offset+4 <= len(resp); offset += 4 {
+		if version := binary.BigEndian.Uint32(resp[offset:]); version&0x0f0f0f0f != 0x0a0a0a0a {
+			versions = append(versions, quicVersionName(version))
+		}
+	}
+	return "quic", versions, nil
+}
+
+// quicVersionName names a QUIC version like the IETF documents do
+func quicVersionName(version uint32) string {
+	switch {
+	case version == 0x00000001:
+		return "v1"
+	case version == 0x6b3343cf:
+		return "v2"
+	case version&0xffffff00 == 0xff000000:
+		return fmt.Sprintf("draft-%d", version&0xff)
+	default:
+		return fmt.Sprintf("0x%08x", version)
+	}
+}
+
+// dtlsClientHello is a DTLS 1.2 ClientHello offering common ECDHE and RSA
+// suites. Servers answer it with a HelloVerifyRequest, a ServerHello or
+// at least an alert.
+var dtlsClientHello = func() []byte {
+	var body []byte
+	body = append(body, 0xfe, 0xfd) // DTLS 1.2
+	random := make([]byte, 32)
+	rand.Read(random)
+	body = append(body, random...)
+	body = append(body, 0, 0) // session ID, cookie
+	suites := []uint16{0xc02b, 0xc02f, 0xc009, 0xc013, 0x009c, 0x002f, 0x00ff}
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(suites)))
+	for _, suite := range suites {
+		body = binary.BigEndian.AppendUint16(body, suite)
+	}
+	body = append(body, 1, 0) // null compression
+	extensions := []byte{
+		0x00, 0x0a, 0x00, 0x06, 0x00, 0x04, 0x00, 0x1d, 0x00, 0x17, // supported groups: x25519, P-256
+		0x00, 0x0b, 0x00, 0x02, 0x01, 0x00, // EC point formats: uncompressed
+		0x00, 0x0d, 0x00, 0x06, 0x00, 0x04, 0x04, 0x03, 0x04, 0x01, // signature algorithms
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(extensions)))
+	body = append(body, extensions...)
+
+	// Handshake header: type, length, message sequence, one fragment
+	length := []byte{byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake := []byte{1}
+	handshake = append(handshake, length...)
+	handshake = append(handshake, 0, 0, 0, 0, 0)
+	handshake = append(handshake, length...)
+	handshake = append(handshake, body...)
+
+	// Record header: handshake, DTLS 1.0 for compatibility, epoch 0,
+	// sequence number 0
+	record := []byte{22, 0xfe, 0xff, 0, 0, 0, 0, 0, 0, 0, 0}
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}()
+
+// probeDTLS sends a ClientHello and checks whether a DTLS record comes back
+func probeDTLS(address string, timeout time.Duration, guard *addressGuard) (string, []string, error) {
+	resp, err := exchangeDatagram(address, dtlsClientHello, timeout, guard)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(resp) < 13 || (resp[0] != 21 && resp[0] != 22) || resp[1] != 0xfe {
+		return "", nil, nil
+	}
+	switch resp[2] {
+	case 0xff:
+		return "dtls", []string{"1.0"}, nil
+	case 0xfd:
+		return "dtls", []string{"1.2"}, nil
+	case 0xfc:
+		return "dtls", []string{"1.3"}, nil
+	}
+	return "dtls", nil, nil
+}
+
+// displayUDPResult prints a -udp-probe result
+func displayUDPResult(result UDPResult, config *Config) {
+	if config.JSONOutput {
+		displayJSON(result)
+		return
+	}
+
+	var stateColor color.Attribute
+	switch result.State {
+	case portOpen:
+		stateColor = color.FgGreen
+	case portClosed:
+		stateColor = color.FgRed
+	default:
+		stateColor = color.FgYellow
+	}
+
+	address := "udp://" + net.JoinHostPort(result.Host, strconv.Itoa(result.Port))
+	columns := []column{
+		newColumn("state", result.State, stateColor),
+		newColumn("protocol", result.Protocol, color.FgCyan),
+		newColumn("versions", strings.Join(result.Versions, ","), color.FgBlue),
+	}
+	columns = append(columns, passthroughColumns(result.Passthrough)...)
+	if config.table != nil {
+		config.table.Add(address, columns)
+		return
+	}
+	fmt.Fprintln(color.Output, formatLine(address, columns))
+}
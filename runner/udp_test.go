@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestQUICVersionName(t *testing.T) {
+	tests := []struct {
+		version uint32
+		want    string
+	}{
+		{0x00000001, "v1"},
+		{0x6b3343cf, "v2"},
+		{0xff00001d, "draft-29"},
+		{0x51303530, "0x51303530"},
+	}
+	for _, tt := range tests {
+		if got := quicVersionName(tt.version); got != tt.want {
+			t.Errorf("quicVersionName(%#x) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+// udpResponder answers every datagram with reply
+func udpResponder(t *testing.T, reply []byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(reply, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestUDPProbes(t *testing.T) {
+	// Version Negotiation: long header, version 0, both connection IDs,
+	// then the versions with a GREASE one mixed in
+	negotiation := []byte{0xc0, 0, 0, 0, 0, 8}
+	negotiation = append(negotiation, make([]byte, 8)...)
+	negotiation = append(negotiation, 8)
+	negotiation = append(negotiation, make([]byte, 8)...)
+	for _, version := range []uint32{0x00000001, 0x0a1a2a3a, 0x6b3343cf} {
+		negotiation = binary.BigEndian.AppendUint32(negotiation, version)
+	}
+	helloVerify := []byte{22, 0xfe, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 3, 0, 0}
+	alert12 := []byte{21, 0xfe, 0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 2, 40}
+
+	tests := []struct {
+		name     string
+		probe    func(string, time.Duration, *addressGuard) (string, []string, error)
+		reply    []byte
+		protocol string
+		versions []string
+	}{
+		{"quic", probeQUIC, negotiation, "quic", []string{"v1", "v2"}},
+		{"quic garbage", probeQUIC, []byte("hello"), "", nil},
+		{"dtls 1.0 record", probeDTLS, helloVerify, "dtls", []string{"1.0"}},
+		{"dtls 1.2 alert", probeDTLS, alert12, "dtls", []string{"1.2"}},
+		{"dtls garbage", probeDTLS, negotiation, "", nil},
+	}
+	for _, tt := range tests {
+		protocol, versions, err := tt.probe(udpResponder(t, tt.reply), time.Second, nil)
+		if err != nil || protocol != tt.protocol || !slices.Equal(versions, tt.versions) {
+			t.Errorf("%s: got %q %q, %v", tt.name, protocol, versions, err)
+		}
+	}
+
+	// The guard applies to datagrams too
+	private, err := newAddressGuard(true, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := probeQUIC(udpResponder(t, negotiation), time.Second, private); err == nil {
+		t.Error("probed a private address past the guard")
+	}
+}
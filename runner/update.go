@@ -0,0 +1,311 @@
+package runner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+// Builds from go install fall back to the module version in the build info.
+var version = "dev"
+
+const releasesURL = "https://api.github.com/repos/hackruler/livedom/releases/latest"
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func currentVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
+
+func printVersion() {
+	fmt.Printf("livedom %s\n", currentVersion())
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				fmt.Printf("  commit:     %s\n", setting.Value)
+			case "vcs.time":
+				fmt.Printf("  built:      %s\n", setting.Value)
+			case "vcs.modified":
+				if setting.Value == "true" {
+					fmt.Printf("  modified:   true\n")
+				}
+			}
+		}
+	}
+	fmt.Printf("  go version: %s\n", runtime.Version())
+	fmt.Printf("  platform:   %s/%s\n", runtime.GOOS, runtime.GOARCH)
+}
+
+func updateTool() {
+	fmt.Println(color.New(color.FgCyan).Sprint("Updating livedom to the latest version..."))
+
+	updated, err := updateFromRelease()
+	if err == nil {
+		if updated {
+			fmt.Println(color.New(color.FgGreen).Sprint("✓ Successfully updated livedom!"))
+		}
+		return
+	}
+
+	// No usable release binary, fall back to building with the Go toolchain
+	if _, lookErr := exec.LookPath("go"); lookErr != nil {
+		fmt.Println(color.New(color.FgRed).Sprint("Error updating livedom:"), err)
+		os.Exit(1)
+	}
+	fmt.Println(color.New(color.FgYellow).Sprintf("Release update failed (%v), falling back to go install", err))
+	updateWithGo()
+}
+
+func updateWithGo() {
+	// First update the module to latest
+	getCmd := exec.Command("go", "get", "-u", "github.com/hackruler/livedom@latest")
+	getCmd.Stdout = os.Stdout
+	getCmd.Stderr = os.Stderr
+	getCmd.Run() // Run get, ignore errors
+
+	// Then install the latest version
+	cmd := exec.Command("go", "install", "github.com/hackruler/livedom@latest")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		fmt.Println(color.New(color.FgRed).Sprint("Error updating livedom:"), err)
+		os.Exit(1)
+	}
+
+	fmt.Println(color.New(color.FgGreen).Sprint("✓ Successfully updated livedom!"))
+}
+
+// updateFromRelease downloads the latest GitHub release binary for this
+// platform, verifies it against the release checksums and replaces the
+// running executable with it. It returns false
+// without error when already on the latest version.
+func updateFromRelease() (bool, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	resp, err := client.Get(releasesURL)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("GitHub API returned %s", resp.Status)
+	}
+
+	var release githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return false, err
+	}
+
+	if release.TagName != "" && release.TagName == currentVersion() {
+		fmt.Println(color.New(color.FgGreen).Sprintf("✓ livedom %s is already the latest version", release.TagName))
+		return false, nil
+	}
+
+	assetName, assetURL := releaseAsset(release, runtime.GOOS, runtime.GOARCH)
+	if assetURL == "" {
+		return false, fmt.Errorf("no release asset for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	_, checksumsURL := checksumsAsset(release)
+	if checksumsURL == "" {
+		return false, fmt.Errorf("release %s has no checksums file", release.TagName)
+	}
+
+	checksums, err := download(client, checksumsURL)
+	if err != nil {
+		return false, fmt.Errorf("downloading checksums: %w", err)
+	}
+	fmt.Printf("Downloading %s (%s)\n", assetName, release.TagName)
+	data, err := download(client, assetURL)
+	if err != nil {
+		return false, err
+	}
+	// Nothing is replaced unless the download matches the release checksums
+	if err := verifyChecksum(assetName, data, checksums); err != nil {
+		return false, err
+	}
+
+	binary, err := extractBinary(assetName, data)
+	if err != nil {
+		return false, err
+	}
+
+	if err := replaceExecutable(binary); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseAsset returns the name and URL of the release asset built for
+// goos/goarch, e.g. livedom_1.2.3_linux_amd64.tar.gz. The platform must be
+// the exact name suffix, so arm doesn't pick up arm64 assets.
+func releaseAsset(release githubRelease, goos, goarch string) (string, string) {
+	suffix := "_" + goos + "_" + goarch
+	for _, asset := range release.Assets {
+		name := strings.ToLower(asset.Name)
+		for _, ext := range []string{".tar.gz", ".tgz", ".zip", ".exe"} {
+			name = strings.TrimSuffix(name, ext)
+		}
+		if strings.HasSuffix(name, suffix) {
+			return asset.Name, asset.BrowserDownloadURL
+		}
+	}
+	return "", ""
+}
+
+// checksumsAsset returns the name and URL of the release's SHA-256
+// checksums file, e.g. livedom_1.2.3_checksums.txt
+func checksumsAsset(release githubRelease) (string, string) {
+	for _, asset := range release.Assets {
+		if strings.HasSuffix(strings.ToLower(asset.Name), "checksums.txt") {
+			return asset.Name, asset.BrowserDownloadURL
+		}
+	}
+	return "", ""
+}
+
+// verifyChecksum checks data against the SHA-256 listed for name in a
+// checksums file of "<hex sum>  <file name>" lines
+func verifyChecksum(name string, data, checksums []byte) error {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("no checksum listed for %s", name)
+}
+
+// download returns the body of a successful GET
+func download(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// extractBinary returns the livedom executable from a release asset, which
+// may be a .tar.gz, a .zip, or the bare binary.
+func extractBinary(name string, data []byte) ([]byte, error) {
+	isBinary := func(path string) bool {
+		base := filepath.Base(path)
+		return base == "livedom" || base == "livedom.exe"
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(gz)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if header.Typeflag == tar.TypeReg && isBinary(header.Name) {
+				return io.ReadAll(tr)
+			}
+		}
+		return nil, fmt.Errorf("livedom binary not found in %s", name)
+
+	case strings.HasSuffix(name, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range zr.File {
+			if !isBinary(file.Name) {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+		return nil, fmt.Errorf("livedom binary not found in %s", name)
+
+	default:
+		return data, nil
+	}
+}
+
+// replaceExecutable atomically swaps the running binary for the new one.
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+
+	// Write next to the old binary so the rename stays on one filesystem
+	tmp := exe + ".new"
+	if err := os.WriteFile(tmp, binary, 0755); err != nil {
+		return err
+	}
+
+	// Windows can't overwrite a running executable, but it can rename it
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		os.Rename(old, exe)
+		return err
+	}
+	os.Remove(old)
+	return nil
+}
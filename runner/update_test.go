@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func testRelease(names ...string) githubRelease {
+	var release githubRelease
+	for _, name := range names {
+		release.Assets = append(release.Assets, struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		}{name, "https://example.com/" + name})
+	}
+	return release
+}
+
+func TestReleaseAsset(t *testing.T) {
+	release := testRelease(
+		"livedom_1.2.3_checksums.txt",
+		"livedom_1.2.3_linux_arm64.tar.gz",
+		"livedom_1.2.3_linux_arm.tar.gz",
+		"livedom_1.2.3_linux_amd64.tar.gz",
+		"livedom_1.2.3_darwin_amd64.zip",
+		"livedom_1.2.3_windows_amd64.exe",
+	)
+	tests := []struct {
+		goos, goarch string
+		want         string
+	}{
+		{"linux", "arm", "livedom_1.2.3_linux_arm.tar.gz"},
+		{"linux", "arm64", "livedom_1.2.3_linux_arm64.tar.gz"},
+		{"linux", "amd64", "livedom_1.2.3_linux_amd64.tar.gz"},
+		{"darwin", "amd64", "livedom_1.2.3_darwin_amd64.zip"},
+		{"windows", "amd64", "livedom_1.2.3_windows_amd64.exe"},
+		{"darwin", "arm64", ""},
+		{"linux", "386", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := releaseAsset(release, tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("releaseAsset(%s/%s) = %q, want %q", tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+
+	// arm must not fall back to the arm64 build
+	if got, _ := releaseAsset(testRelease("livedom_1.2.3_linux_arm64.tar.gz"), "linux", "arm"); got != "" {
+		t.Errorf("linux/arm picked %q", got)
+	}
+
+	if got, _ := checksumsAsset(release); got != "livedom_1.2.3_checksums.txt" {
+		t.Errorf("checksumsAsset = %q", got)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("release binary")
+	sum := sha256.Sum256(data)
+	checksums := []byte("0000  livedom_1.2.3_linux_arm64.tar.gz\n" +
+		hex.EncodeToString(sum[:]) + "  livedom_1.2.3_linux_amd64.tar.gz\n")
+
+	if err := verifyChecksum("livedom_1.2.3_linux_amd64.tar.gz", data, checksums); err != nil {
+		t.Errorf("matching checksum: %v", err)
+	}
+	if err := verifyChecksum("livedom_1.2.3_linux_amd64.tar.gz", []byte("tampered"), checksums); err == nil {
+		t.Error("tampered download passed verification")
+	}
+	if err := verifyChecksum("livedom_1.2.3_linux_386.tar.gz", data, checksums); err == nil {
+		t.Error("asset without a listed checksum passed verification")
+	}
+}
@@ -0,0 +1,270 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// uploadAttempts is how often an object is tried before it's given up,
+// waiting uploadBackoff longer after each failure
+const uploadAttempts = 3
+
+var uploadBackoff = time.Second
+
+// objectStore is a bucket -upload puts files into
+type objectStore interface {
+	PutFile(ctx context.Context, key, path, contentType string) error
+	Close() error
+}
+
+// uploader sends the results of a scan to object storage as JSON Lines,
+// in parts of a set size as the scan goes or whole when it's done, and
+// the output files after them
+type uploader struct {
+	store  objectStore
+	where  string // s3://bucket/prefix/run-id/, for messages
+	prefix string // of every key
+	chunk  int    // results per part, 0 for one object
+	files  []string
+
+	mu      sync.Mutex
+	part    *os.File
+	writer  *bufio.Writer
+	lines   int
+	parts   int
+	pending chan uploadPart
+	done    chan struct{}
+
+	uploaded int
+	lost     int   // results that never made it into a part
+	partErr  error // why, the last time
+	errs     []error
+}
+
+type uploadPart struct {
+	key, path string
+}
+
+// newUploader parses an s3:// or gs:// URL and sets up the upload of the
+// run's results and files under its prefix
+func newUploader(rawURL, runID string, chunk int, files []string) (*uploader, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an s3://bucket/prefix/ or gs://bucket/prefix/ URL", rawURL)
+	}
+	var store objectStore
+	if u.Scheme == "gs" {
+		store, err = newGCSStore(context.Background(), u.Host)
+	} else {
+		store, err = newS3Store(context.Background(), u.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := runID + "/"
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		prefix = path + "/" + prefix
+	}
+	up := &uploader{
+		store:   store,
+		where:   u.Scheme + "://" + u.Host + "/" + prefix,
+		prefix:  prefix,
+		chunk:   chunk,
+		files:   files,
+		pending: make(chan uploadPart, 4),
+		done:    make(chan struct{}),
+	}
+	if err := up.startPart(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	go up.loop()
+	return up, nil
+}
+
+// startPart opens a temporary file for the next results
+func (up *uploader) startPart() error {
+	part, err := os.CreateTemp("", "livedom-results-*.jsonl")
+	if err != nil {
+		return err
+	}
+	up.part, up.writer, up.lines = part, bufio.NewWriter(part), 0
+	return nil
+}
+
+// Write adds result to the current part, handing the part over for
+// upload once it holds -upload-chunk results. If the next part couldn't
+// be started, e.g. with the disk full, each Write tries again and counts
+// the result as lost when that fails too.
+func (up *uploader) Write(result Result) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	line := withSchema(data)
+
+	up.mu.Lock()
+	if up.part == nil {
+		if err := up.startPart(); err != nil {
+			up.lost++
+			up.partErr = err
+			up.mu.Unlock()
+			return
+		}
+	}
+	up.writer.Write(append(line, '\n'))
+	up.lines++
+	part, ok := uploadPart{}, false
+	if up.chunk > 0 && up.lines >= up.chunk {
+		part, ok = up.endPart()
+		if err := up.startPart(); err != nil {
+			slog.Warn("starting the next -upload part, will retry", "error", err)
+		}
+	}
+	up.mu.Unlock()
+
+	// Sent unlocked: a full queue waits on loop, which takes up.mu
+	if ok {
+		up.pending <- part
+	}
+}
+
+// endPart closes the current part and names its object. The caller holds
+// up.mu.
+func (up *uploader) endPart() (uploadPart, bool) {
+	part := up.part
+	up.part = nil
+	if err := errors.Join(up.writer.Flush(), part.Close()); err != nil {
+		up.lost += up.lines
+		up.partErr = err
+		os.Remove(part.Name())
+		return uploadPart{}, false
+	}
+	key := up.prefix + "results.jsonl"
+	if up.chunk > 0 {
+		up.parts++
+		key = fmt.Sprintf("%sresults-%05d.jsonl", up.prefix, up.parts)
+	}
+	return uploadPart{key, part.Name()}, true
+}
+
+// loop uploads parts one after another as they fill
+func (up *uploader) loop() {
+	defer close(up.done)
+	for part := range up.pending {
+		up.put(part.key, part.path)
+		os.Remove(part.path)
+	}
+}
+
+// put uploads the file at path, retrying with backoff
+func (up *uploader) put(key, path string) {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	switch filepath.Ext(path) {
+	case ".jsonl":
+		contentType = "application/x-ndjson"
+	case ".har":
+		contentType = "application/json"
+	case "":
+		contentType = "text/plain; charset=utf-8"
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	var err error
+	for attempt := range uploadAttempts {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * uploadBackoff)
+		}
+		if err = up.store.PutFile(context.Background(), key, path, contentType); err == nil {
+			break
+		}
+		slog.Debug("upload failed", "key", key, "attempt", attempt+1, "error", err)
+	}
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	if err != nil {
+		up.errs = append(up.errs, fmt.Errorf("uploading %s: %w", key, err))
+		return
+	}
+	up.uploaded++
+}
+
+// Close uploads the last results, waits for the parts still going and
+// uploads the output files. It runs after they're closed.
+func (up *uploader) Close() error {
+	up.mu.Lock()
+	// The last part goes up even if empty, so a scan with no results
+	// still leaves a results object
+	part, ok := uploadPart{}, false
+	if up.part != nil && (up.lines > 0 || up.parts == 0) {
+		part, ok = up.endPart()
+	} else if up.part != nil {
+		up.part.Close()
+		os.Remove(up.part.Name())
+		up.part = nil
+	}
+	up.mu.Unlock()
+	if ok {
+		up.pending <- part
+	}
+	close(up.pending)
+	<-up.done
+
+	for _, path := range up.files {
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // e.g. -split-output-dir with no results
+		}
+		if err != nil {
+			up.errs = append(up.errs, err)
+			continue
+		}
+		if !info.IsDir() {
+			up.put(up.prefix+filepath.Base(path), path)
+			continue
+		}
+		// Directories keep their name and layout under the prefix
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(filepath.Dir(filepath.Clean(path)), file)
+			if err != nil {
+				return err
+			}
+			up.put(up.prefix+filepath.ToSlash(rel), file)
+			return nil
+		})
+		if err != nil {
+			up.errs = append(up.errs, err)
+		}
+	}
+
+	if err := up.store.Close(); err != nil {
+		up.errs = append(up.errs, err)
+	}
+	if up.lost > 0 {
+		up.errs = append(up.errs, fmt.Errorf("%d results couldn't be uploaded: %w", up.lost, up.partErr))
+	}
+	slog.Info("uploaded scan outputs", "objects", up.uploaded, "to", up.where)
+	return errors.Join(up.errs...)
+}
@@ -0,0 +1,233 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hackruler/livedom/internal/testserver"
+)
+
+// fakeBucket stores the objects uploaded to it by key, with the bucket
+// as the first path element
+type fakeBucket struct {
+	*httptest.Server
+	status int // answered instead of storing, if set
+
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (b *fakeBucket) store(key, body string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[key] = body
+}
+
+func (b *fakeBucket) Objects() map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return maps.Clone(b.objects)
+}
+
+// isolateCloudConfig keeps the SDKs away from the config files and
+// metadata servers of the machine running the tests
+func isolateCloudConfig(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	t.Setenv("AWS_CONFIG_FILE", missing)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", missing)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_ENDPOINT_URL_S3", "")
+}
+
+// newFakeS3 serves PUT Object like S3 with path-style addressing
+func newFakeS3(t *testing.T) *fakeBucket {
+	s3 := &fakeBucket{objects: map[string]string{}}
+	s3.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") {
+			t.Errorf("Authorization %q", auth)
+		}
+		if s3.status != 0 {
+			w.WriteHeader(s3.status)
+			io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		s3.store(r.URL.Path, string(body))
+	}))
+	t.Cleanup(s3.Close)
+
+	isolateCloudConfig(t)
+	t.Setenv("AWS_ENDPOINT_URL", s3.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	return s3
+}
+
+// newFakeGCS serves the multipart uploads of the Cloud Storage JSON API
+// to the client of STORAGE_EMULATOR_HOST
+func newFakeGCS(t *testing.T) *fakeBucket {
+	gcs := &fakeBucket{objects: map[string]string{}}
+	gcs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket, ok := strings.CutPrefix(r.URL.Path, "/upload/storage/v1/b/")
+		bucket, _, _ = strings.Cut(bucket, "/")
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if !ok || r.URL.Query().Get("uploadType") != "multipart" || err != nil {
+			t.Errorf("%s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		parts := multipart.NewReader(r.Body, params["boundary"])
+		var object struct {
+			Name        string `json:"name"`
+			ContentType string `json:"contentType"`
+		}
+		meta, err := parts.NextPart()
+		if err == nil {
+			err = json.NewDecoder(meta).Decode(&object)
+		}
+		media, err2 := parts.NextPart()
+		if err != nil || err2 != nil {
+			t.Errorf("upload of %s: %v %v", r.URL, err, err2)
+			return
+		}
+		body, _ := io.ReadAll(media)
+		gcs.store("/"+bucket+"/"+object.Name, string(body))
+		json.NewEncoder(w).Encode(map[string]string{"bucket": bucket, "name": object.Name, "contentType": object.ContentType})
+	}))
+	t.Cleanup(gcs.Close)
+
+	isolateCloudConfig(t)
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(gcs.URL, "http://"))
+	return gcs
+}
+
+func TestUpload(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+	s3 := newFakeS3(t)
+	dir := t.TempDir()
+
+	err := Scan(context.Background(), Options{
+		Args: []string{"-upload", "s3://results/team a/", "-upload-chunk", "1", "-run-id", "nightly",
+			"-nuclei-targets", filepath.Join(dir, "nuclei.txt"), "-manifest", filepath.Join(dir, "scan-manifest.json"),
+			"-split-output-by", "status", "-split-output-dir", filepath.Join(dir, "split")},
+		Targets: []string{server.URL + "/ok", server.URL + "/missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := s3.Objects()
+	// Path-style keys under the prefix and run ID, one part per result
+	for _, key := range []string{"results-00001.jsonl", "results-00002.jsonl", "nuclei.txt", "scan-manifest.json", "split/200.txt", "split/404.txt"} {
+		if _, ok := objects["/results/team a/nightly/"+key]; !ok {
+			t.Errorf("no %s in %q", key, slices.Sorted(maps.Keys(objects)))
+		}
+	}
+	if len(objects) != 6 {
+		t.Errorf("objects %q", slices.Sorted(maps.Keys(objects)))
+	}
+	first := objects["/results/team a/nightly/results-00001.jsonl"]
+	if !strings.HasPrefix(first, `{"schema":`) || strings.Count(first, "\n") != 1 {
+		t.Errorf("part %q", first)
+	}
+	// The manifest went up after it was written
+	if !strings.Contains(objects["/results/team a/nightly/scan-manifest.json"], `"results"`) {
+		t.Errorf("manifest %q", objects["/results/team a/nightly/scan-manifest.json"])
+	}
+}
+
+func TestUploadWhole(t *testing.T) {
+	server := testserver.New()
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		bucket func(t *testing.T) *fakeBucket
+		url    string
+	}{
+		{"s3", newFakeS3, "s3://results"},
+		{"gcs", newFakeGCS, "gs://results"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := tt.bucket(t)
+			err := Scan(context.Background(), Options{
+				Args:    []string{"-upload", tt.url, "-run-id", "once"},
+				Targets: []string{server.URL + "/ok", server.URL + "/gzip"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			objects := bucket.Objects()
+			if results := objects["/results/once/results.jsonl"]; len(objects) != 1 || strings.Count(results, "\n") != 2 {
+				t.Errorf("objects %q", objects)
+			}
+		})
+	}
+}
+
+func TestUploadDenied(t *testing.T) {
+	s3 := newFakeS3(t)
+	s3.status = http.StatusForbidden
+	uploadBackoff = 0
+	defer func() { uploadBackoff = time.Second }()
+
+	up, err := newUploader("s3://results/", "run", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	up.Write(Result{URL: "https://example.com"})
+	if err := up.Close(); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("got %v", err)
+	}
+
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	if _, err := newUploader("s3://results/", "run", 0, nil); err == nil {
+		t.Error("missing credentials accepted")
+	}
+}
+
+func TestUploadPartFailure(t *testing.T) {
+	s3 := newFakeS3(t)
+	up, err := newUploader("s3://results/", "run", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parts can't be started while the temporary directory is gone
+	tmp := os.Getenv("TMPDIR")
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	for range 3 {
+		up.Write(Result{URL: "https://example.com"})
+	}
+	// and the next result goes in a new part once it's back
+	t.Setenv("TMPDIR", tmp)
+	up.Write(Result{URL: "https://example.org"})
+
+	err = up.Close()
+	if err == nil || !strings.Contains(err.Error(), "2 results couldn't be uploaded") {
+		t.Errorf("got %v", err)
+	}
+	objects := s3.Objects()
+	if len(objects) != 2 || !strings.Contains(objects["/results/run/results-00002.jsonl"], "example.org") {
+		t.Errorf("objects %q", objects)
+	}
+}
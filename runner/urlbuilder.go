@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// targetAddr is an input split into the parts probe URLs are built from
+type targetAddr struct {
+	Scheme string // "http", "https", or "" when the input had none
+	Host   string // lowercase hostname or IP, IPv6 without brackets
+	Port   int    // 0 when the input had none
+	Path   string // path and query, escaped; "" when the input had none
+}
+
+// parseTargetAddr splits a bare host, host:port or http(s) URL into a
+// targetAddr. Userinfo and fragments are dropped, IPv6 addresses may come
+// with or without brackets, and anything that can't become a valid URL is
+// an error instead of a mangled request.
+func parseTargetAddr(input string) (targetAddr, error) {
+	var addr targetAddr
+	rest := strings.TrimSpace(input)
+
+	if i := strings.Index(rest, "://"); i >= 0 {
+		addr.Scheme = strings.ToLower(rest[:i])
+		if addr.Scheme != "http" && addr.Scheme != "https" {
+			return targetAddr{}, fmt.Errorf("unsupported scheme %q in %q", rest[:i], input)
+		}
+		rest = rest[i+3:]
+	} else {
+		rest = strings.TrimPrefix(rest, "//")
+	}
+
+	// Fragments never reach the server
+	if i := strings.IndexByte(rest, '#'); i >= 0 {
+		rest = rest[:i]
+	}
+
+	authority := rest
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		authority, addr.Path = rest[:i], escapePath(rest[i:])
+		if addr.Path[0] == '?' {
+			addr.Path = "/" + addr.Path
+		}
+	}
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		authority = authority[i+1:]
+	}
+
+	host, port, err := splitAuthority(authority)
+	if err != nil {
+		return targetAddr{}, fmt.Errorf("invalid target %q: %w", input, err)
+	}
+	addr.Host, addr.Port = host, port
+	return addr, nil
+}
+
+// splitAuthority splits host[:port], [ipv6][:port] or a bare IPv6 address
+// and validates both parts
+func splitAuthority(authority string) (string, int, error) {
+	if authority == "" {
+		return "", 0, fmt.Errorf("missing host")
+	}
+
+	var host, port string
+	switch {
+	case authority[0] == '[':
+		end := strings.IndexByte(authority, ']')
+		if end < 0 {
+			return "", 0, fmt.Errorf("unclosed IPv6 bracket")
+		}
+		host, port = authority[1:end], authority[end+1:]
+		if port != "" && port[0] != ':' {
+			return "", 0, fmt.Errorf("unexpected %q after IPv6 address", port)
+		}
+		port = strings.TrimPrefix(port, ":")
+		// Zones are escaped in URLs, fe80::1%25eth0
+		ip, err := parseIPv6(strings.Replace(host, "%25", "%", 1))
+		if err != nil {
+			return "", 0, err
+		}
+		host = ip
+	case strings.Count(authority, ":") > 1:
+		// Unbracketed IPv6 can't carry a port
+		ip, err := parseIPv6(authority)
+		if err != nil {
+			return "", 0, err
+		}
+		host = ip
+	default:
+		host, port, _ = strings.Cut(authority, ":")
+		host = strings.ToLower(host)
+		if host == "" {
+			return "", 0, fmt.Errorf("missing host")
+		}
+		if i := strings.IndexFunc(host, invalidHostRune); i >= 0 {
+			return "", 0, fmt.Errorf("invalid character %q in host", host[i])
+		}
+	}
+
+	if port == "" {
+		return host, 0, nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 || port[0] == '+' {
+		return "", 0, fmt.Errorf("invalid port %q", port)
+	}
+	return host, n, nil
+}
+
+// parseIPv6 validates an IPv6 address, with an optional zone, and returns
+// it in canonical form
+func parseIPv6(s string) (string, error) {
+	ip, err := netip.ParseAddr(s)
+	if err != nil || !ip.Is6() || strings.IndexFunc(ip.Zone(), invalidHostRune) >= 0 {
+		return "", fmt.Errorf("invalid IPv6 address %q", s)
+	}
+	return ip.String(), nil
+}
+
+// invalidHostRune reports characters that can't appear in a hostname
+// without breaking the URL or the request line
+func invalidHostRune(r rune) bool {
+	return r <= ' ' || r == 0x7f || strings.ContainsRune(`[]@\%"<>^{|}`+"`", r)
+}
+
+// escapePath percent-encodes the bytes of a path that would break the
+// request line, and % signs that don't start an escape; everything else is
+// sent as the input had it
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c <= ' ' || c >= 0x7f || c == '"' || c == '<' || c == '>' || c == '\\' ||
+			c == '%' && (i+2 >= len(path) || !isHex(path[i+1]) || !isHex(path[i+2])) {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// URL builds the URL of the target under scheme
+func (a targetAddr) URL(scheme string) string {
+	host := a.Host
+	if strings.Contains(host, ":") {
+		host = "[" + strings.Replace(host, "%", "%25", 1) + "]"
+	}
+	if a.Port != 0 {
+		host += ":" + strconv.Itoa(a.Port)
+	}
+	return scheme + "://" + host + a.Path
+}
+
+// URLs returns the URLs to probe: the input's own scheme, or HTTPS then
+// HTTP for bare hosts
+func (a targetAddr) URLs() []string {
+	if a.Scheme != "" {
+		return []string{a.URL(a.Scheme)}
+	}
+	return []string{a.URL("https"), a.URL("http")}
+}
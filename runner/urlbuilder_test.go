@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTargetURLs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"example.com", []string{"https://example.com", "http://example.com"}},
+		{"  Example.COM\t", []string{"https://example.com", "http://example.com"}},
+		{"example.com:8080", []string{"https://example.com:8080", "http://example.com:8080"}},
+		{"example.com:", []string{"https://example.com", "http://example.com"}},
+		{"example.com/", []string{"https://example.com/", "http://example.com/"}},
+		{"example.com/admin/", []string{"https://example.com/admin/", "http://example.com/admin/"}},
+		{"example.com?q=1", []string{"https://example.com/?q=1", "http://example.com/?q=1"}},
+		{"//example.com/x", []string{"https://example.com/x", "http://example.com/x"}},
+		{"user:pass@example.com", []string{"https://example.com", "http://example.com"}},
+		{"a@b@example.com:81", []string{"https://example.com:81", "http://example.com:81"}},
+		{"http://example.com", []string{"http://example.com"}},
+		{"HTTPS://example.com:8443/a b", []string{"https://example.com:8443/a%20b"}},
+		{"https://user@example.com/p?x=1#frag", []string{"https://example.com/p?x=1"}},
+		{"https://example.com/@user", []string{"https://example.com/@user"}},
+		{"::1", []string{"https://[::1]", "http://[::1]"}},
+		{"2001:DB8::1", []string{"https://[2001:db8::1]", "http://[2001:db8::1]"}},
+		{"[::1]:8080", []string{"https://[::1]:8080", "http://[::1]:8080"}},
+		{"http://[fe80::1%25eth0]/", []string{"http://[fe80::1%25eth0]/"}},
+		{"192.0.2.1:443", []string{"https://192.0.2.1:443", "http://192.0.2.1:443"}},
+	}
+	for _, tt := range tests {
+		addr, err := parseTargetAddr(tt.input)
+		if err != nil {
+			t.Errorf("parseTargetAddr(%q): %v", tt.input, err)
+			continue
+		}
+		if got := addr.URLs(); !slices.Equal(got, tt.want) {
+			t.Errorf("parseTargetAddr(%q).URLs() = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTargetAddrInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"   ",
+		"ftp://example.com",
+		"https://",
+		"user@",
+		":8080",
+		"example.com:http",
+		"example.com:0",
+		"example.com:65536",
+		"example.com:+80",
+		"[::1",
+		"[::1]8080",
+		"[192.0.2.1]",
+		"1:2:3",
+		"exa mple.com",
+		"exa\x00mple.com",
+		"example.com%2f",
+	} {
+		if addr, err := parseTargetAddr(input); err == nil {
+			t.Errorf("parseTargetAddr(%q) = %+v, want an error", input, addr)
+		}
+	}
+}
+
+func FuzzTargetAddr(f *testing.F) {
+	for _, seed := range []string{
+		"example.com", "example.com:8080/a/", "https://u:p@[::1]:443/x?y#z",
+		"2001:db8::1", "http://[fe80::1%25eth0]/", "//x/%zz", "a b/\x7f\"<>",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		addr, err := parseTargetAddr(input)
+		if err != nil {
+			return
+		}
+		for _, built := range addr.URLs() {
+			if strings.ContainsAny(built, " \t\r\n\x00") {
+				t.Fatalf("%q built %q, which breaks the request line", input, built)
+			}
+			parsed, err := url.Parse(built)
+			if err != nil {
+				t.Fatalf("%q built unparseable %q: %v", input, built, err)
+			}
+			if parsed.Hostname() != addr.Host || parsed.User != nil {
+				t.Fatalf("%q built %q, host %q, want %q", input, built, parsed.Hostname(), addr.Host)
+			}
+			// The built URL is stable
+			again, err := parseTargetAddr(built)
+			if err != nil || again.URL(again.Scheme) != built {
+				t.Fatalf("%q built %q, which rebuilds as %q (%v)", input, built, again.URL(again.Scheme), err)
+			}
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// validateConfig checks flag values and combinations that can't work,
+// returning one message per problem that says how to fix it. set holds
+// the flags given on the command line.
+func validateConfig(config *Config, set map[string]bool) []string {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if config.Threads < 1 {
+		add("-t must be at least 1, got %d", config.Threads)
+	}
+	if config.Timeout <= 0 {
+		add("-timeout must be positive, e.g. -timeout 5s, got %s", config.Timeout)
+	}
+	if config.MaxConnsPerHost < 1 {
+		add("-max-conns-per-host must be at least 1, got %d", config.MaxConnsPerHost)
+	}
+	if config.TitleLen < 0 {
+		add("-title-len must be 0 (no limit) or more, got %d", config.TitleLen)
+	}
+	if config.TopN < 0 {
+		add("-top-n must be 0 (off) or more, got %d", config.TopN)
+	}
+	if config.MaxPerApex < 0 {
+		add("-max-per-apex must be 0 (no limit) or more, got %d", config.MaxPerApex)
+	}
+	if config.KeepRuns < 0 {
+		add("-keep-runs must be 0 (keep all) or more, got %d", config.KeepRuns)
+	}
+	if config.ASNRateLimit < 0 {
+		add("-asn-rate-limit must be 0 (no limit) or more, got %d", config.ASNRateLimit)
+	}
+	if config.MaxConnWait < 0 || config.IdleConnTimeout < 0 || config.OOBWait < 0 || config.TLSSniff < 0 || config.DeadCacheTTL < 0 {
+		add("-max-conn-wait, -idle-conn-timeout, -oob-wait, -tls-sniff and -dead-cache-ttl can't be negative")
+	}
+
+	if config.DNSOnly && config.TCPOnly {
+		add("-dns-only and -tcp-only are separate modes, pick one")
+	}
+	if config.UDPProbe && (config.DNSOnly || config.TCPOnly) {
+		add("-udp-probe is a mode of its own, it can't be combined with -dns-only or -tcp-only")
+	}
+
+	// HEAD responses have no body to look at
+	if strings.EqualFold(config.Method, "HEAD") && config.RawRequest == "" {
+		for _, name := range []string{"all", "hash", "title", "dom-hash", "secrets", "js", "js-endpoints", "lang", "meta", "body-redirect", "fingerprint-db", "filter-hash-file", "filter-default-hashes", "render"} {
+			if set[name] {
+				add("-%s needs the response body, which -method HEAD doesn't get: drop -method HEAD or -%s", name, name)
+			}
+		}
+	}
+
+	// Chrome sends its own requests, which can't carry the probe's headers,
+	// login session or ClientHello
+	if config.Render {
+		for _, name := range []string{"H", "login-script", "tls-impersonate"} {
+			if set[name] {
+				add("-render can't apply -%s to Chrome's requests, so rendered pages would differ from probed ones: drop -render or -%s", name, name)
+			}
+		}
+	}
+
+	// Flags that only tune another feature
+	requires := []struct{ flag, needs string }{
+		{"redact-secrets", "secrets"},
+		{"nuclei-mc", "nuclei-targets"},
+		{"hsts-preload-file", "hsts"},
+		{"render-chrome", "render"},
+		{"render-no-sandbox", "render"},
+		{"max-per-apex-seed", "max-per-apex"},
+		{"interactsh-token", "interactsh-server"},
+		{"oob-wait", "interactsh-server"},
+		{"split-output-dir", "split-output-by"},
+		{"ports", "tcp-only"},
+		{"compare-regions", "via"},
+		{"dead-cache-ttl", "dead-cache"},
+		{"udp-ports", "udp-probe"},
+		{"keep-runs", "db"},
+		{"prune-older", "db"},
+		{"upload-chunk", "upload"},
+	}
+	for _, r := range requires {
+		if set[r.flag] && !set[r.needs] {
+			add("-%s has no effect without -%s", r.flag, r.needs)
+		}
+	}
+
+	if set["allow-ips"] && !config.DisallowPrivate && !config.DisallowMetadata {
+		add("-allow-ips has no effect without -disallow-private or -disallow-metadata")
+	}
+
+	if set["run-id"] && !set["db"] && !set["upload"] {
+		add("-run-id has no effect without -db or -upload")
+	}
+	if config.UploadChunk < 0 {
+		add("-upload-chunk must be 0 (one object) or more, got %d", config.UploadChunk)
+	}
+	if config.Upload != "" && !strings.HasPrefix(config.Upload, "s3://") && !strings.HasPrefix(config.Upload, "gs://") {
+		add("-upload takes an s3:// or gs:// URL")
+	}
+
+	if strings.Contains(config.DB, "://") && !strings.HasPrefix(config.DB, "postgres://") && !strings.HasPrefix(config.DB, "postgresql://") {
+		add("-db takes a SQLite file or a postgres:// URL, other databases aren't supported")
+	}
+
+	if config.Table && config.JSONOutput {
+		add("-table and -json are different output formats, pick one")
+	}
+
+	return problems
+}
+
+// configProblems are the problems validateConfig finds with the flags of
+// a command
+type configProblems struct {
+	command  string
+	problems []string
+}
+
+func (p *configProblems) Error() string {
+	return strings.Join(p.problems, "; ")
+}
+
+// Print writes the problems to stderr, pointing to the flag list
+func (p *configProblems) Print() {
+	for _, problem := range p.problems {
+		fmt.Fprintln(os.Stderr, "Error:", problem)
+	}
+	fmt.Fprintf(os.Stderr, "Run 'livedom %s -h' for the list of flags.\n", p.command)
+}
+
+// flagInfo describes a flag for -list-flags-json
+type flagInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"` // bool, int, string, duration, or value for repeatable flags
+	Default string `json:"default"`
+	Usage   string `json:"usage"`
+}
+
+// printFlagsJSON writes the flags of fs as a JSON array, for tools that
+// wrap livedom and build its command line
+func printFlagsJSON(fs *flag.FlagSet) {
+	flags := []flagInfo{}
+	fs.VisitAll(func(f *flag.Flag) {
+		typeName, usage := flag.UnquoteUsage(f)
+		if typeName == "" {
+			typeName = "bool"
+		}
+		flags = append(flags, flagInfo{Name: f.Name, Type: typeName, Default: f.DefValue, Usage: usage})
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(flags)
+}
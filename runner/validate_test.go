@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+// problems returns what validateConfig finds in config, with the flags in
+// set given on the command line
+func problems(set map[string]bool, config *Config) string {
+	if config.Threads == 0 {
+		config.Threads, config.Timeout, config.MaxConnsPerHost = 1, 1, 1
+	}
+	return strings.Join(validateConfig(config, set), "\n")
+}
+
+func TestValidateRender(t *testing.T) {
+	for _, name := range []string{"H", "login-script", "tls-impersonate"} {
+		got := problems(map[string]bool{"render": true, name: true}, &Config{Render: true})
+		if !strings.Contains(got, "-render can't apply -"+name) {
+			t.Errorf("-render with -%s: got %q", name, got)
+		}
+	}
+	if got := problems(map[string]bool{"render": true, "disallow-private": true, "via": true}, &Config{Render: true, DisallowPrivate: true}); got != "" {
+		t.Errorf("-render with -disallow-private and -via: got %q", got)
+	}
+}
+
+func TestValidateHEAD(t *testing.T) {
+	for _, method := range []string{"HEAD", "head", "Head"} {
+		got := problems(map[string]bool{"method": true, "title": true}, &Config{Method: method})
+		if !strings.Contains(got, "-title needs the response body") {
+			t.Errorf("-method %s -title: got %q", method, got)
+		}
+	}
+	if got := problems(map[string]bool{"method": true, "title": true}, &Config{Method: "get"}); got != "" {
+		t.Errorf("-method get -title: got %q", got)
+	}
+}
@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpproxy"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// viaProxy is an egress proxy from -via, named after the region it
+// stands for
+type viaProxy struct {
+	name   string
+	dial   fasthttp.DialFunc
+	client *fasthttp.Client // for -compare-regions
+}
+
+// proxyPool spreads probe connections over the -via proxies
+type proxyPool struct {
+	proxies []*viaProxy
+	next    atomic.Uint64
+}
+
+// parseVia parses comma-separated "[name=]proxy-url" entries. Proxies are
+// http:// (CONNECT) or socks5://, and are named by host:port unless given
+// a name.
+func parseVia(s string, timeout time.Duration) (*proxyPool, error) {
+	pool := &proxyPool{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, raw, named := strings.Cut(entry, "=")
+		if !named {
+			raw = entry
+		}
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		proxyURL, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !named {
+			name = proxyURL.Host
+		}
+
+		dialer := fasthttpproxy.Dialer{
+			Config:         httpproxy.Config{HTTPProxy: raw, HTTPSProxy: raw},
+			Timeout:        timeout,
+			ConnectTimeout: timeout,
+			DialDualStack:  true,
+		}
+		dial, err := dialer.GetDialFunc(false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry, err)
+		}
+		pool.proxies = append(pool.proxies, &viaProxy{name: name, dial: dial})
+	}
+	if len(pool.proxies) == 0 {
+		return nil, fmt.Errorf("no proxies in %q", s)
+	}
+	return pool, nil
+}
+
+// Dial connects to addr through the next proxy in turn. The proxy
+// resolves the name, so GeoDNS answers for the proxy's region.
+func (p *proxyPool) Dial(addr string, _ time.Duration) (net.Conn, error) {
+	proxy := p.proxies[(p.next.Add(1)-1)%uint64(len(p.proxies))]
+	return proxy.dial(addr)
+}
+
+// dialTarget opens the client's connections to targets, through the -via
+// proxies when given and past the -disallow-private guard otherwise
+func (c *Config) dialTarget(addr string, timeout time.Duration) (net.Conn, error) {
+	if c.via != nil {
+		return c.via.Dial(addr, timeout)
+	}
+	return dialHappyEyeballs(addr, timeout, c.guard)
+}
+
+// RegionResult is what a target served through one -via proxy
+type RegionResult struct {
+	Region     string `json:"region"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Hash       string `json:"hash,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// compareRegions requests targetURL through every -via proxy and reports
+// whether the status or body differs between them
+func compareRegions(target Target, targetURL string, config *Config) ([]RegionResult, bool) {
+	results := make([]RegionResult, 0, len(config.via.proxies))
+	for _, proxy := range config.via.proxies {
+		region := RegionResult{Region: proxy.name}
+		status, hash, err := proxy.fetch(target, targetURL, config)
+		if err != nil {
+			region.Error = errorKind(err)
+		} else {
+			region.StatusCode, region.Hash = status, hash
+		}
+		results = append(results, region)
+	}
+
+	for _, region := range results[1:] {
+		if region.StatusCode != results[0].StatusCode || region.Hash != results[0].Hash || region.Error != results[0].Error {
+			return results, true
+		}
+	}
+	return results, false
+}
+
+// fetch sends the probe for targetURL through the proxy, like
+// fetchFromIP does to an origin
+func (p *viaProxy) fetch(target Target, targetURL string, config *Config) (int, string, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(targetURL)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	applyTargetSettings(req, target, config)
+	err := p.client.Do(req, resp)
+	config.stats.recordRequest(err)
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.StatusCode(), bodyHash(resp.Body()), nil
+}
+
+// newRegionClients gives each proxy a client of its own for
+// -compare-regions. Certificates aren't checked: regions are compared by
+// content, and a region serving a different certificate shouldn't make
+// it look down.
+func newRegionClients(pool *proxyPool, config *Config) {
+	for _, proxy := range pool.proxies {
+		proxy.client = &fasthttp.Client{
+			Dial:                          proxy.dial,
+			ReadTimeout:                   config.Timeout,
+			WriteTimeout:                  config.Timeout,
+			MaxIdemponentCallAttempts:     1,
+			DisableHeaderNamesNormalizing: true,
+			DisablePathNormalizing:        true,
+			TLSConfig:                     &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+}
+
+// formatRegions is the text column of differing regions, e.g.
+// "eu=200/3f2a9c1b,us=403/8d0e4a77"
+func formatRegions(regions []RegionResult) string {
+	parts := make([]string, len(regions))
+	for i, region := range regions {
+		switch {
+		case region.Error != "":
+			parts[i] = region.Region + "=" + region.Error
+		case len(region.Hash) > 8:
+			parts[i] = fmt.Sprintf("%s=%d/%s", region.Region, region.StatusCode, region.Hash[:8])
+		default:
+			parts[i] = fmt.Sprintf("%s=%d", region.Region, region.StatusCode)
+		}
+	}
+	return strings.Join(parts, ",")
+}
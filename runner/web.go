@@ -0,0 +1,25 @@
+package runner
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// webPage is the single-page UI of -web. It submits scans and reads their
+// results through the API.
+//
+//go:embed data/web.html
+var webPage []byte
+
+// WebHandler serves the web UI at / and the API next to it, so the page
+// calls the API from its own origin
+func (s *scanServer) WebHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", s.Handler())
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+		w.Write(webPage)
+	})
+	return mux
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// envLine matches a KEY=value line of a dotenv file
+var envLine = regexp.MustCompile(`(?m)^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// gitHead matches a .git/HEAD file: a symbolic ref or a detached commit
+var gitHead = regexp.MustCompile(`^(ref: refs/|[0-9a-f]{40}\s*$)`)
+
+// wellKnownFiles are the files -well-known always checks, with a check of
+// the content where a catch-all page would otherwise look like a hit
+var wellKnownFiles = []struct {
+	path  string
+	valid func(body []byte) bool
+}{
+	{"/.well-known/security.txt", func(body []byte) bool { return bytes.Contains(bytes.ToLower(body), []byte("contact:")) }},
+	{"/.git/HEAD", gitHead.Match},
+	{"/.env", envLine.Match},
+}
+
+// WellKnownFile is a file -well-known found on a host
+type WellKnownFile struct {
+	Path       string `json:"path"`
+	StatusCode int    `json:"status_code"`
+	Size       int    `json:"size"`
+}
+
+// parseWellKnownPaths parses the comma-separated -well-known-paths list,
+// adding the leading slash where it's missing
+func parseWellKnownPaths(s string) []string {
+	var paths []string
+	for _, path := range strings.Split(s, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// checkWellKnown requests the well-known files and the -well-known-paths
+// on the host of targetURL and returns the ones that exist
+func checkWellKnown(client *fasthttp.Client, target Target, targetURL string, config *Config) []WellKnownFile {
+	base, err := url.Parse(targetURL)
+	if err != nil {
+		return nil
+	}
+
+	var found []WellKnownFile
+	for _, file := range wellKnownFiles {
+		if f, ok := fetchWellKnown(client, target, base, file.path, file.valid, config); ok {
+			found = append(found, f)
+		}
+	}
+	for _, path := range config.wellKnownPaths {
+		if f, ok := fetchWellKnown(client, target, base, path, nil, config); ok {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// fetchWellKnown requests one path. A 2xx answer means the file exists,
+// as long as valid, if given, accepts its content.
+func fetchWellKnown(client *fasthttp.Client, target Target, base *url.URL, path string, valid func([]byte) bool, config *Config) (WellKnownFile, bool) {
+	req := newFollowUpRequest(base.ResolveReference(&url.URL{Path: path}).String(), target, config)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	err := doFollowUp(client, req, resp, config)
+	if err != nil {
+		return WellKnownFile{}, false
+	}
+
+	status := resp.StatusCode()
+	if status < 200 || status >= 300 {
+		return WellKnownFile{}, false
+	}
+	body := resp.Body()
+	if valid != nil && !valid(body) {
+		return WellKnownFile{}, false
+	}
+	return WellKnownFile{Path: path, StatusCode: status, Size: len(body)}, true
+}
+
+// formatWellKnown renders found files for the well-known column as
+// path(status,size)
+func formatWellKnown(files []WellKnownFile) string {
+	parts := make([]string, len(files))
+	for i, file := range files {
+		parts[i] = fmt.Sprintf("%s(%d,%d)", file.Path, file.StatusCode, file.Size)
+	}
+	return "well-known:" + strings.Join(parts, ",")
+}
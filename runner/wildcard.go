@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wildcardDetector finds hosts that only resolve because their parent
+// domain has a wildcard record. Each parent is tested once, by resolving
+// a random label under it.
+type wildcardDetector struct {
+	timeout    time.Duration
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	mu         sync.Mutex
+	zones      map[string]*wildcardZone
+}
+
+// wildcardZone is what a random name under a parent domain resolves to
+type wildcardZone struct {
+	once           sync.Once
+	addresses      map[string]bool // nil if the parent has no wildcard
+	mu             sync.Mutex
+	representative string // first wildcard-backed host, which is probed
+}
+
+func newWildcardDetector(timeout time.Duration) *wildcardDetector {
+	return &wildcardDetector{
+		timeout:    timeout,
+		lookupHost: net.DefaultResolver.LookupHost,
+		zones:      make(map[string]*wildcardZone),
+	}
+}
+
+// Check reports whether host resolves only through a wildcard, and if so
+// whether it is the one host of its wildcard that gets probed. IPs,
+// apex domains and hosts that don't resolve are never wildcard-backed.
+func (d *wildcardDetector) Check(host string) (wildcard, representative bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return false, false
+	}
+	_, parent, ok := strings.Cut(host, ".")
+	apex := apexDomain(host)
+	if !ok || apex == "" || host == apex {
+		return false, false
+	}
+
+	zone := d.zone(parent)
+	if zone.addresses == nil {
+		return false, false
+	}
+
+	// A name with its own records resolves elsewhere than the wildcard
+	wildcard = false
+	for _, address := range d.lookup(host) {
+		if zone.addresses[address] {
+			wildcard = true
+			break
+		}
+	}
+	if !wildcard {
+		return false, false
+	}
+
+	zone.mu.Lock()
+	defer zone.mu.Unlock()
+	if zone.representative == "" {
+		zone.representative = host
+	}
+	return true, zone.representative == host
+}
+
+// zone returns the wildcard test of parent, running it on first use
+func (d *wildcardDetector) zone(parent string) *wildcardZone {
+	d.mu.Lock()
+	zone, ok := d.zones[parent]
+	if !ok {
+		zone = &wildcardZone{}
+		d.zones[parent] = zone
+	}
+	d.mu.Unlock()
+
+	zone.once.Do(func() {
+		addresses := d.lookup("livedom-wildcard-" + randomToken(12) + "." + parent)
+		if len(addresses) == 0 {
+			return
+		}
+		zone.addresses = make(map[string]bool, len(addresses))
+		for _, address := range addresses {
+			zone.addresses[address] = true
+		}
+	})
+	return zone
+}
+
+func (d *wildcardDetector) lookup(host string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	addresses, _ := d.lookupHost(ctx, host)
+	return addresses
+}
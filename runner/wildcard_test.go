@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWildcardDetector(t *testing.T) {
+	// wild.example.com has a wildcard, plain.example.com doesn't
+	var zoneLookups int
+	d := newWildcardDetector(time.Second)
+	d.lookupHost = func(_ context.Context, host string) ([]string, error) {
+		if strings.HasPrefix(host, "livedom-wildcard-") {
+			zoneLookups++
+		}
+		switch {
+		case host == "own.wild.example.com":
+			return []string{"198.51.100.1"}, nil
+		case strings.HasSuffix(host, ".wild.example.com"):
+			return []string{"192.0.2.1"}, nil
+		case host == "www.plain.example.com":
+			return []string{"192.0.2.2"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	// In order, the first wildcard-backed host of a zone is its representative
+	tests := []struct {
+		host           string
+		wildcard       bool
+		representative bool
+	}{
+		{"192.0.2.1", false, false},
+		{"example.com", false, false},
+		{"localhost", false, false},
+		{"www.plain.example.com", false, false},
+		{"a.wild.example.com", true, true},
+		{"B.wild.example.com.", true, false},
+		{"a.wild.example.com", true, true},
+		{"own.wild.example.com", false, false},
+	}
+	for _, tt := range tests {
+		wildcard, representative := d.Check(tt.host)
+		if wildcard != tt.wildcard || representative != tt.representative {
+			t.Errorf("Check(%q) = %v, %v, want %v, %v", tt.host, wildcard, representative, tt.wildcard, tt.representative)
+		}
+	}
+	if zoneLookups != 2 {
+		t.Errorf("tested zones %d times, want once per parent", zoneLookups)
+	}
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// uniqueLineWriter writes lines to a file, skipping lines already written.
+// It is safe for concurrent use by workers.
+type uniqueLineWriter struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	seen   map[string]struct{}
+}
+
+func newUniqueLineWriter(path string) (*uniqueLineWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &uniqueLineWriter{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		seen:   make(map[string]struct{}),
+	}, nil
+}
+
+// WriteLine writes line unless it was written before
+func (w *uniqueLineWriter) WriteLine(line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.seen[line]; ok {
+		return
+	}
+	w.seen[line] = struct{}{}
+	fmt.Fprintln(w.writer, line)
+}
+
+func (w *uniqueLineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}